@@ -1,88 +1,263 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"strings"
+	"time"
+	// Base des fuseaux horaires embarquée, pour les images sans tzdata
+	_ "time/tzdata"
 
 	"beautiful-minds/backend/project/config"
+	"beautiful-minds/backend/project/internal/antispam"
 	"beautiful-minds/backend/project/internal/database"
+	"beautiful-minds/backend/project/internal/encryption"
 	"beautiful-minds/backend/project/internal/handlers"
+	"beautiful-minds/backend/project/internal/jobs"
+	"beautiful-minds/backend/project/internal/logging"
+	"beautiful-minds/backend/project/internal/mailer"
+	"beautiful-minds/backend/project/internal/metrics"
 	"beautiful-minds/backend/project/internal/middleware"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/openapi"
 	"beautiful-minds/backend/project/internal/repository"
+	"beautiful-minds/backend/project/internal/storage"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	// Charger les variables d'environnement
-	if err := godotenv.Load(); err != nil {
-		log.Println("Pas de fichier .env trouvé")
+	// Charger les variables d'environnement ; .env.local, non versionné, porte
+	// les secrets de développement et prime sur .env
+	godotenv.Load(".env.local")
+	envErr := godotenv.Load()
+
+	// Charger la configuration (défauts < fichier < environnement < options)
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	// Charger la configuration
-	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Configurer le logger structuré
+	slog.SetDefault(logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level))
+	if cfg.File != "" {
+		slog.Info("Configuration chargée", "file", cfg.File)
+	}
+	if envErr != nil {
+		slog.Info("Pas de fichier .env trouvé")
+	}
 
 	// Connexion à la base de données
 	db, err := database.Connect(cfg)
 	if err != nil {
-		log.Fatal("Erreur connexion DB:", err)
+		slog.Error("Erreur connexion DB", "error", err)
+		os.Exit(1)
 	}
 	defer db.Close()
 
-	log.Println("✅ Connexion à PostgreSQL réussie")
+	slog.Info("Connexion à PostgreSQL réussie", "host", cfg.Database.Host, "database", cfg.Database.Name)
+
+	// Appliquer les migrations du schéma
+	if err := database.Migrate(db); err != nil {
+		slog.Error("Erreur migration DB", "error", err)
+		os.Exit(1)
+	}
+
+	// Exposer les statistiques du pool de connexions
+	if err := metrics.RegisterDB(db, cfg.Database.Name); err != nil {
+		slog.Warn("Impossible d'enregistrer les métriques DB", "error", err)
+	}
+
+	// Trousseau de clés du chiffrement des données sensibles (déjà validé)
+	keys, err := encryption.NewKeyring(cfg.Encryption.Keys, cfg.Encryption.CurrentKeyID, cfg.Encryption.BlindIndexKey)
+	if err != nil {
+		slog.Error("Initialisation du chiffrement", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialiser les repositories
-	memberRepo := repository.NewMemberRepository(db)
+	memberRepo := repository.NewMemberRepository(db, keys)
 	eventRepo := repository.NewEventRepository(db)
 	announcementRepo := repository.NewAnnouncementRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	privacyRepo := repository.NewPrivacyRepository(db)
+	categoryRepo := repository.NewCategoryRepository(db)
+	occurrenceRepo := repository.NewOccurrenceRepository(db)
+	venueRepo := repository.NewVenueRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	speakerRepo := repository.NewSpeakerRepository(db)
+	guestRepo := repository.NewGuestRepository(db)
+	surveyRepo := repository.NewSurveyRepository(db)
+	mediaRepo := repository.NewMediaRepository(db)
+	revisionRepo := repository.NewRevisionRepository(db)
+
+	// Chiffrer les valeurs encore en clair et rechiffrer celles d'une ancienne clé
+	go func() {
+		n, err := memberRepo.Reencrypt()
+		if err != nil {
+			slog.Error("Rechiffrement des données membres échoué", "error", err, "rewritten", n)
+			return
+		}
+		if n > 0 {
+			slog.Info("Données membres rechiffrées", "rewritten", n, "key_id", cfg.Encryption.CurrentKeyID)
+		}
+	}()
+
+	// Envoi des emails (journalisés seulement si SMTP n'est pas configuré)
+	mail := mailer.New(&cfg.SMTP)
+
+	// Protection anti-spam du formulaire d'inscription public
+	spamGuard, err := antispam.NewGuard(&cfg.AntiSpam)
+	if err != nil {
+		slog.Error("Initialisation de l'anti-spam", "error", err)
+		os.Exit(1)
+	}
+	if cfg.AntiSpam.Enabled && cfg.AntiSpam.FormSecret == "" {
+		slog.Warn("anti_spam.form_secret non défini : secret aléatoire, les formulaires ouverts expirent au redémarrage")
+	}
+
+	// Stockage des images envoyées, servies par l'API en local
+	store, err := storage.New(&cfg.Media, strings.TrimSuffix(cfg.Server.PublicURL, "/")+"/api/v1/media/files")
+	if err != nil {
+		slog.Error("Initialisation du stockage des médias", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialiser les handlers
-	memberHandler := handlers.NewMemberHandler(memberRepo)
-	eventHandler := handlers.NewEventHandler(eventRepo)
-	announcementHandler := handlers.NewAnnouncementHandler(announcementRepo)
+	eventDefaults := models.EventDefaults{Timezone: cfg.Events.DefaultTimezone, Duration: cfg.Events.DefaultDuration}
+	privacyHandler := handlers.NewPrivacyHandler(memberRepo, eventRepo, sessionRepo, surveyRepo, guestRepo, privacyRepo,
+		auditRepo, mail, cfg.Server.PublicURL, cfg.Privacy.TokenTTL)
+	guestHandler := handlers.NewGuestHandler(guestRepo, eventRepo, occurrenceRepo, memberRepo, auditRepo,
+		mail, cfg.Server.PublicURL, cfg.Events.GuestTokenTTL)
+	surveyHandler := handlers.NewSurveyHandler(surveyRepo, eventRepo, memberRepo, auditRepo,
+		mail, cfg.Server.PublicURL, cfg.Events.SurveyTokenTTL)
+	mediaHandler := handlers.NewMediaHandler(mediaRepo, eventRepo, auditRepo, store,
+		cfg.Media.MaxUploadSize, cfg.Media.MaxPixels, cfg.Media.ThumbnailSize, cfg.Media.CacheMaxAge)
+	eventHandler := handlers.NewEventHandler(eventRepo, occurrenceRepo, auditRepo, revisionRepo,
+		cfg.Events.OccurrenceHorizon, eventDefaults)
+	h := &apiHandlers{
+		members:       handlers.NewMemberHandler(memberRepo, auditRepo),
+		events:        eventHandler,
+		announcements: handlers.NewAnnouncementHandler(announcementRepo, auditRepo, revisionRepo),
+		trash:         handlers.NewTrashHandler(memberRepo, eventRepo, announcementRepo),
+		audit:         handlers.NewAuditHandler(auditRepo),
+		privacy:       privacyHandler,
+		categories:    handlers.NewCategoryHandler(categoryRepo, auditRepo),
+		occurrences:   handlers.NewOccurrenceHandler(eventRepo, occurrenceRepo, auditRepo, cfg.Events.OccurrenceHorizon),
+		calendar:      handlers.NewCalendarHandler(eventRepo, occurrenceRepo, cfg.Server.PublicURL),
+		venues:        handlers.NewVenueHandler(venueRepo, auditRepo),
+		sessions:      handlers.NewSessionHandler(sessionRepo, eventRepo, auditRepo),
+		speakers:      handlers.NewSpeakerHandler(speakerRepo, auditRepo),
+		guests:        guestHandler,
+		surveys:       surveyHandler,
+		media:         mediaHandler,
+		forms:         handlers.NewFormHandler(spamGuard),
+		spam:          spamGuard,
+		docs:          cfg.IsDevelopment(),
+	}
+
+	// Purger périodiquement la corbeille
+	jobs.StartTrashPurge(context.Background(), cfg.Trash.PurgeInterval, cfg.Trash.Retention, map[string]jobs.Purger{
+		"members":       memberRepo,
+		"events":        eventRepo,
+		"announcements": announcementRepo,
+	})
+
+	// Envoyer par email les annonces publiées à leur public
+	jobs.StartAnnouncementNotifications(context.Background(), cfg.Announcements.NotifyInterval,
+		announcementRepo, memberRepo, mail)
 
 	// Créer le routeur
 	router := mux.NewRouter()
 
-	// Middleware CORS
-	router.Use(middleware.CORS)
-
-	// Routes API
-	api := router.PathPrefix("/api").Subrouter()
-
-	// Routes membres
-	api.HandleFunc("/members", memberHandler.GetAll).Methods("GET")
-	api.HandleFunc("/members/search", memberHandler.Search).Methods("GET")
-	api.HandleFunc("/members", memberHandler.Create).Methods("POST")
-	api.HandleFunc("/members/{id}", memberHandler.GetByID).Methods("GET")
-	api.HandleFunc("/members/{id}", memberHandler.Update).Methods("PUT")
-	api.HandleFunc("/members/{id}", memberHandler.Delete).Methods("DELETE")
-
-	// Routes événements
-	api.HandleFunc("/events", eventHandler.GetAll).Methods("GET")
-	api.HandleFunc("/events", eventHandler.Create).Methods("POST")
-	api.HandleFunc("/events/{id}", eventHandler.GetByID).Methods("GET")
-	api.HandleFunc("/events/{id}", eventHandler.Update).Methods("PUT")
-	api.HandleFunc("/events/{id}", eventHandler.Delete).Methods("DELETE")
-	api.HandleFunc("/events/{id}/register", eventHandler.RegisterMember).Methods("POST")
-
-	// Routes annonces
-	api.HandleFunc("/announcements", announcementHandler.GetAll).Methods("GET")
-	api.HandleFunc("/announcements", announcementHandler.Create).Methods("POST")
-	api.HandleFunc("/announcements/{id}", announcementHandler.GetByID).Methods("GET")
-	api.HandleFunc("/announcements/{id}", announcementHandler.Update).Methods("PUT")
-	api.HandleFunc("/announcements/{id}", announcementHandler.Delete).Methods("DELETE")
+	// Journaliser aussi les routes inconnues
+	router.NotFoundHandler = middleware.AccessLog(http.NotFoundHandler())
+
+	// Middleware de journalisation des accès
+	router.Use(middleware.AccessLog)
+
+	// Middleware métriques
+	router.Use(middleware.Metrics)
+
+	// Rôle de l'appelant (accès en clair aux données sensibles)
+	router.Use(middleware.Roles(cfg.Auth.AdminToken))
+
+	// Endpoint Prometheus
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	// Routes API versionnées ; /api/v1 doit précéder l'alias /api
+	apiV1 := router.PathPrefix("/api/v1").Subrouter()
+
+	// Anciennes routes /api, conservées comme alias dépréciés de /api/v1
+	legacy := router.PathPrefix("/api").Subrouter()
+	sunset, _ := time.Parse(time.DateOnly, cfg.Server.LegacyAPISunset)
+	legacy.Use(middleware.Deprecated("/api", "/api/v1", sunset))
+
+	// Limitation de débit par IP et par route, avant la validation ; les
+	// budgets sont partagés entre /api et /api/v1
+	rateLimit := middleware.RateLimit(&cfg.RateLimit)
+	apiV1.Use(rateLimit)
+	legacy.Use(rateLimit)
+
+	// Validation des requêtes contre la spécification en développement
+	if cfg.IsDevelopment() {
+		spec, err := openapi.Load()
+		if err != nil {
+			slog.Error("Chargement de la spécification OpenAPI", "error", err)
+			os.Exit(1)
+		}
+		validator, err := openapi.Validator(spec)
+		if err != nil {
+			slog.Error("Initialisation de la validation OpenAPI", "error", err)
+			os.Exit(1)
+		}
+		apiV1.Use(validator)
+		legacy.Use(validator)
+	}
+
+	registerV1(apiV1, h)
+	registerV1(legacy, h)
 
 	// Démarrer le serveur
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// CORS enveloppe le routeur pour répondre aux requêtes preflight ; l'IP
+	// du client est résolue avant tout le reste (journaux, limitation de débit)
+	clientAddress := middleware.ClientAddress(cfg.Server.ClientIPHeader)
+	server := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           middleware.RequestID(clientAddress(middleware.CORS(cfg)(router))),
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
 	}
 
-	log.Printf("🚀 Serveur démarré sur le port %s", port)
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatal(err)
+	slog.Info("Serveur démarré", "port", cfg.Server.Port)
+	if err := server.ListenAndServe(); err != nil {
+		slog.Error("Arrêt du serveur", "error", err)
+		os.Exit(1)
 	}
 }
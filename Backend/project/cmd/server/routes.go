@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+
+	"beautiful-minds/backend/project/internal/antispam"
+	"beautiful-minds/backend/project/internal/handlers"
+	"beautiful-minds/backend/project/internal/openapi"
+
+	"github.com/gorilla/mux"
+)
+
+// apiHandlers groups the handlers shared by the API versions
+type apiHandlers struct {
+	members       *handlers.MemberHandler
+	events        *handlers.EventHandler
+	announcements *handlers.AnnouncementHandler
+	trash         *handlers.TrashHandler
+	audit         *handlers.AuditHandler
+	privacy       *handlers.PrivacyHandler
+	categories    *handlers.CategoryHandler
+	occurrences   *handlers.OccurrenceHandler
+	calendar      *handlers.CalendarHandler
+	venues        *handlers.VenueHandler
+	sessions      *handlers.SessionHandler
+	speakers      *handlers.SpeakerHandler
+	guests        *handlers.GuestHandler
+	surveys       *handlers.SurveyHandler
+	media         *handlers.MediaHandler
+	forms         *handlers.FormHandler
+	spam          *antispam.Guard
+	// docs mounts the interactive documentation, whose assets come from a
+	// CDN; development only
+	docs bool
+}
+
+// registerV1 mounts the v1 routes on r. A breaking change to a payload goes
+// into a registerV2 mounted under /api/v2, reusing the unchanged handlers.
+func registerV1(r *mux.Router, h *apiHandlers) {
+	// Spécification OpenAPI et documentation interactive
+	r.HandleFunc("/openapi.json", openapi.SpecHandler).Methods("GET")
+	if h.docs {
+		r.HandleFunc("/docs", openapi.DocsHandler).Methods("GET")
+	}
+
+	// Jeton anti-spam du formulaire d'inscription public
+	r.HandleFunc("/forms/token", h.forms.Token).Methods("GET")
+
+	// Routes membres
+	r.HandleFunc("/members", h.members.GetAll).Methods("GET")
+	r.HandleFunc("/members/search", h.members.Search).Methods("GET")
+	r.Handle("/members", h.spam.Protect(http.HandlerFunc(h.members.Create))).Methods("POST")
+	r.HandleFunc("/members/{id}", h.members.GetByID).Methods("GET")
+	r.HandleFunc("/members/{id}", h.members.Update).Methods("PUT")
+	r.HandleFunc("/members/{id}", h.members.Patch).Methods("PATCH")
+	r.HandleFunc("/members/{id}", h.members.Delete).Methods("DELETE")
+	r.HandleFunc("/members/{id}/restore", h.members.Restore).Methods("POST")
+
+	// Routes événements
+	r.HandleFunc("/events", h.events.GetAll).Methods("GET")
+	r.HandleFunc("/events", h.events.Create).Methods("POST")
+	r.HandleFunc("/events.ics", h.calendar.Feed).Methods("GET")
+	r.HandleFunc("/events/{id}", h.events.GetByID).Methods("GET")
+	r.HandleFunc("/events/{id}", h.events.Update).Methods("PUT")
+	r.HandleFunc("/events/{id}", h.events.Patch).Methods("PATCH")
+	r.HandleFunc("/events/{id}", h.events.Delete).Methods("DELETE")
+	r.HandleFunc("/events/{id}/restore", h.events.Restore).Methods("POST")
+	r.Handle("/events/{id}/register", h.spam.Protect(http.HandlerFunc(h.events.RegisterMember))).Methods("POST")
+	r.HandleFunc("/events/{id}/attendance", h.events.Attendance).Methods("POST")
+	r.HandleFunc("/events/{id}/calendar.ics", h.calendar.Event).Methods("GET")
+	r.HandleFunc("/events/{id}/image", h.media.SetEventImage).Methods("POST")
+	r.HandleFunc("/events/{id}/image", h.media.RemoveEventImage).Methods("DELETE")
+	r.HandleFunc("/events/{id}/revisions", h.events.Revisions).Methods("GET")
+	r.HandleFunc("/events/{id}/revisions/diff", h.events.RevisionDiff).Methods("GET")
+	r.HandleFunc("/events/{id}/revisions/{version}", h.events.Revision).Methods("GET")
+	r.HandleFunc("/events/{id}/revisions/{version}/rollback", h.events.Rollback).Methods("POST")
+
+	// Inscriptions des invités (non-membres)
+	r.Handle("/events/{id}/guests", h.spam.Protect(http.HandlerFunc(h.guests.Register))).Methods("POST")
+	r.HandleFunc("/events/{id}/guests", h.guests.ListByEvent).Methods("GET")
+	r.HandleFunc("/events/{id}/guests/{registrationId}", h.guests.CancelRegistration).Methods("DELETE")
+	r.HandleFunc("/guest-registrations/{token}/confirm", h.guests.Confirm).Methods("GET")
+	r.HandleFunc("/guests", h.guests.GetAll).Methods("GET")
+	r.HandleFunc("/guests/{id}", h.guests.GetByID).Methods("GET")
+	r.HandleFunc("/guests/{id}", h.guests.Delete).Methods("DELETE")
+	r.HandleFunc("/guests/{id}/convert", h.guests.Convert).Methods("POST")
+
+	// Occurrences des événements récurrents
+	r.HandleFunc("/events/{id}/occurrences", h.occurrences.List).Methods("GET")
+	r.HandleFunc("/events/{id}/occurrences/{start}", h.occurrences.Update).Methods("PUT")
+	r.HandleFunc("/events/{id}/occurrences/{start}", h.occurrences.Cancel).Methods("DELETE")
+
+	// Programme des événements et intervenants
+	r.HandleFunc("/events/{id}/agenda", h.sessions.Agenda).Methods("GET")
+	r.HandleFunc("/events/{id}/sessions", h.sessions.Create).Methods("POST")
+	r.HandleFunc("/events/{id}/sessions/{sessionId}", h.sessions.GetByID).Methods("GET")
+	r.HandleFunc("/events/{id}/sessions/{sessionId}", h.sessions.Update).Methods("PUT")
+	r.HandleFunc("/events/{id}/sessions/{sessionId}", h.sessions.Delete).Methods("DELETE")
+	r.HandleFunc("/events/{id}/sessions/{sessionId}/register", h.sessions.Register).Methods("POST")
+	r.HandleFunc("/events/{id}/sessions/{sessionId}/register", h.sessions.Unregister).Methods("DELETE")
+	r.HandleFunc("/speakers", h.speakers.GetAll).Methods("GET")
+	r.HandleFunc("/speakers", h.speakers.Create).Methods("POST")
+	r.HandleFunc("/speakers/{id}", h.speakers.GetByID).Methods("GET")
+	r.HandleFunc("/speakers/{id}", h.speakers.Update).Methods("PUT")
+	r.HandleFunc("/speakers/{id}", h.speakers.Delete).Methods("DELETE")
+
+	// Questionnaires de satisfaction
+	r.HandleFunc("/events/{id}/survey", h.surveys.Get).Methods("GET")
+	r.HandleFunc("/events/{id}/survey", h.surveys.Save).Methods("PUT")
+	r.HandleFunc("/events/{id}/survey", h.surveys.Delete).Methods("DELETE")
+	r.HandleFunc("/events/{id}/survey/invitations", h.surveys.Invite).Methods("POST")
+	r.HandleFunc("/events/{id}/survey/responses", h.surveys.Respond).Methods("POST")
+	r.HandleFunc("/events/{id}/survey/responses.csv", h.surveys.Export).Methods("GET")
+	r.HandleFunc("/events/{id}/survey/results", h.surveys.Results).Methods("GET")
+
+	// Images envoyées et leurs miniatures
+	r.HandleFunc("/media", h.media.GetAll).Methods("GET")
+	r.HandleFunc("/media", h.media.Upload).Methods("POST")
+	r.HandleFunc("/media/files/{key}", h.media.Serve).Methods("GET")
+	r.HandleFunc("/media/{id}", h.media.GetByID).Methods("GET")
+	r.HandleFunc("/media/{id}", h.media.Delete).Methods("DELETE")
+
+	// Catégories et étiquettes des événements
+	r.HandleFunc("/categories", h.categories.GetAll).Methods("GET")
+	r.HandleFunc("/categories", h.categories.Create).Methods("POST")
+	r.HandleFunc("/categories/{id}", h.categories.GetByID).Methods("GET")
+	r.HandleFunc("/categories/{id}", h.categories.Update).Methods("PUT")
+	r.HandleFunc("/categories/{id}", h.categories.Delete).Methods("DELETE")
+	r.HandleFunc("/tags", h.events.Tags).Methods("GET")
+
+	// Salles et réservations
+	r.HandleFunc("/venues", h.venues.GetAll).Methods("GET")
+	r.HandleFunc("/venues", h.venues.Create).Methods("POST")
+	r.HandleFunc("/venues/{id}", h.venues.GetByID).Methods("GET")
+	r.HandleFunc("/venues/{id}", h.venues.Update).Methods("PUT")
+	r.HandleFunc("/venues/{id}", h.venues.Delete).Methods("DELETE")
+	r.HandleFunc("/venues/{id}/bookings", h.venues.Bookings).Methods("GET")
+
+	// Routes annonces
+	r.HandleFunc("/announcements", h.announcements.GetAll).Methods("GET")
+	r.HandleFunc("/announcements", h.announcements.Create).Methods("POST")
+	r.HandleFunc("/announcements/{id}", h.announcements.GetByID).Methods("GET")
+	r.HandleFunc("/announcements/{id}", h.announcements.Update).Methods("PUT")
+	r.HandleFunc("/announcements/{id}", h.announcements.Patch).Methods("PATCH")
+	r.HandleFunc("/announcements/{id}", h.announcements.Delete).Methods("DELETE")
+	r.HandleFunc("/announcements/{id}/restore", h.announcements.Restore).Methods("POST")
+	r.HandleFunc("/announcements/{id}/revisions", h.announcements.Revisions).Methods("GET")
+	r.HandleFunc("/announcements/{id}/revisions/diff", h.announcements.RevisionDiff).Methods("GET")
+	r.HandleFunc("/announcements/{id}/revisions/{version}", h.announcements.Revision).Methods("GET")
+	r.HandleFunc("/announcements/{id}/revisions/{version}/rollback", h.announcements.Rollback).Methods("POST")
+
+	// Corbeille
+	r.HandleFunc("/trash", h.trash.GetAll).Methods("GET")
+
+	// Journal d'audit
+	r.HandleFunc("/audit", h.audit.List).Methods("GET")
+
+	// Données personnelles (RGPD)
+	r.HandleFunc("/privacy-requests", h.privacy.Request).Methods("POST")
+	r.HandleFunc("/privacy-requests", h.privacy.List).Methods("GET")
+	r.HandleFunc("/privacy-requests/{token}/export", h.privacy.ExportByToken).Methods("GET")
+	r.HandleFunc("/privacy-requests/{token}/erase", h.privacy.EraseByToken).Methods("GET", "POST")
+	r.HandleFunc("/members/{id}/export", h.privacy.ExportMember).Methods("GET")
+	r.HandleFunc("/members/{id}/erase", h.privacy.EraseMember).Methods("POST")
+}
@@ -1,30 +1,217 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
+// Config holds every setting of the backend. Values are resolved in this
+// order, each source overriding the previous one: `default` tags, the YAML
+// config file, environment variables (`env` tags) and command-line flags
+// (`flag` tags). Fields tagged `secret` are redacted by Redacted.
 type Config struct {
-	DBHost     string
-	DBPort     string
-	DBUser     string
-	DBPassword string
-	DBName     string
-	Port       string
-}
-
-func Load() *Config {
-	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", ""),
-		DBName:     getEnv("DB_NAME", "club_scientific"),
-		Port:       getEnv("PORT", "8080"),
-	}
+	Server        ServerConfig        `yaml:"server"`
+	Database      DatabaseConfig      `yaml:"database"`
+	Log           LogConfig           `yaml:"log"`
+	CORS          CORSConfig          `yaml:"cors"`
+	Auth          AuthConfig          `yaml:"auth"`
+	SMTP          SMTPConfig          `yaml:"smtp"`
+	Trash         TrashConfig         `yaml:"trash"`
+	Privacy       PrivacyConfig       `yaml:"privacy"`
+	Encryption    EncryptionConfig    `yaml:"encryption"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	AntiSpam      AntiSpamConfig      `yaml:"anti_spam"`
+	Events        EventsConfig        `yaml:"events"`
+	Media         MediaConfig         `yaml:"media"`
+	Announcements AnnouncementsConfig `yaml:"announcements"`
+
+	// File is the config file that was loaded, if any
+	File string `yaml:"-"`
+	// PrintConfig asks main to print the resolved configuration and exit
+	PrintConfig bool `yaml:"-"`
+}
+
+type ServerConfig struct {
+	// Environment is "development" or "production"; an unset environment
+	// must not enable the development tooling
+	Environment string `yaml:"environment" env:"APP_ENV" flag:"env" default:"production"`
+	Port        string `yaml:"port" env:"PORT" flag:"port" default:"8080"`
+	// PublicURL is the externally reachable base URL used in emailed links
+	PublicURL         string        `yaml:"public_url" env:"PUBLIC_URL" flag:"public-url" default:"http://localhost:8080"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" flag:"http-read-timeout" default:"15s"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" flag:"http-read-header-timeout" default:"5s"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" flag:"http-write-timeout" default:"30s"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" flag:"http-idle-timeout" default:"120s"`
+	// LegacyAPISunset is the date (YYYY-MM-DD) after which the unversioned /api routes go away
+	LegacyAPISunset string `yaml:"legacy_api_sunset" env:"LEGACY_API_SUNSET" flag:"legacy-api-sunset" default:"2027-06-30"`
+	// ClientIPHeader is the header carrying the client address set by a
+	// reverse proxy (X-Real-IP, X-Forwarded-For); empty to use the remote
+	// address. Only set it when the proxy overwrites that header.
+	ClientIPHeader string `yaml:"client_ip_header" env:"CLIENT_IP_HEADER" flag:"client-ip-header"`
+}
+
+type DatabaseConfig struct {
+	// URL takes precedence over the individual connection fields when set
+	URL             string        `yaml:"url" env:"DATABASE_URL" flag:"database-url" secret:"true"`
+	Host            string        `yaml:"host" env:"DB_HOST" flag:"db-host" default:"localhost"`
+	Port            string        `yaml:"port" env:"DB_PORT" flag:"db-port" default:"5432"`
+	User            string        `yaml:"user" env:"DB_USER" flag:"db-user" default:"postgres"`
+	Password        string        `yaml:"password" env:"DB_PASSWORD" flag:"db-password" secret:"true"`
+	Name            string        `yaml:"name" env:"DB_NAME" flag:"db-name" default:"club_scientific"`
+	SSLMode         string        `yaml:"sslmode" env:"DB_SSLMODE" flag:"db-sslmode" default:"disable"`
+	SSLRootCert     string        `yaml:"sslrootcert" env:"DB_SSLROOTCERT" flag:"db-sslrootcert"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" flag:"db-connect-timeout" default:"5s"`
+	ConnectRetries  int           `yaml:"connect_retries" env:"DB_CONNECT_RETRIES" flag:"db-connect-retries" default:"10"`
+	RetryMaxDelay   time.Duration `yaml:"retry_max_delay" env:"DB_RETRY_MAX_DELAY" flag:"db-retry-max-delay" default:"10s"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" default:"5"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" flag:"db-conn-max-idle-time" default:"5m"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" default:"info"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" default:"text"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" flag:"cors-allowed-origins" default:"http://localhost:3000"`
+	AllowedMethods   []string `yaml:"allowed_methods" env:"CORS_ALLOWED_METHODS" flag:"cors-allowed-methods" default:"GET,POST,PUT,PATCH,DELETE,OPTIONS"`
+	AllowedHeaders   []string `yaml:"allowed_headers" env:"CORS_ALLOWED_HEADERS" flag:"cors-allowed-headers" default:"Content-Type,Authorization,X-Request-ID,If-Match,If-None-Match,X-Form-Token"`
+	ExposedHeaders   []string `yaml:"exposed_headers" env:"CORS_EXPOSED_HEADERS" flag:"cors-exposed-headers" default:"X-Request-ID,ETag,Retry-After"`
+	AllowCredentials bool     `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" flag:"cors-allow-credentials" default:"false"`
+	MaxAge           int      `yaml:"max_age" env:"CORS_MAX_AGE" flag:"cors-max-age" default:"600"`
+}
+
+type AuthConfig struct {
+	// AdminToken is the bearer token granting the admin role
+	AdminToken string `yaml:"admin_token" env:"AUTH_ADMIN_TOKEN" flag:"auth-admin-token" secret:"true"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" env:"SMTP_HOST" flag:"smtp-host"`
+	Port     int    `yaml:"port" env:"SMTP_PORT" flag:"smtp-port" default:"587"`
+	Username string `yaml:"username" env:"SMTP_USERNAME" flag:"smtp-username"`
+	Password string `yaml:"password" env:"SMTP_PASSWORD" flag:"smtp-password" secret:"true"`
+	From     string `yaml:"from" env:"SMTP_FROM" flag:"smtp-from"`
+}
+
+type TrashConfig struct {
+	// Retention is how long deleted items stay restorable before being purged
+	Retention     time.Duration `yaml:"retention" env:"TRASH_RETENTION" flag:"trash-retention" default:"720h"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"TRASH_PURGE_INTERVAL" flag:"trash-purge-interval" default:"1h"`
+}
+
+type PrivacyConfig struct {
+	// TokenTTL is how long the emailed export/erasure links stay valid
+	TokenTTL time.Duration `yaml:"token_ttl" env:"PRIVACY_TOKEN_TTL" flag:"privacy-token-ttl" default:"24h"`
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+type EncryptionConfig struct {
+	// Keys are the master keys as "id:base64" (32 bytes each). Retired keys
+	// stay listed until every value has been rewrapped under CurrentKeyID.
+	Keys         []string `yaml:"keys" env:"ENCRYPTION_KEYS" flag:"encryption-keys" secret:"true"`
+	CurrentKeyID string   `yaml:"current_key_id" env:"ENCRYPTION_CURRENT_KEY_ID" flag:"encryption-current-key-id"`
+	// BlindIndexKey (base64) keys the hash used to look up student IDs;
+	// changing it breaks lookups on existing rows
+	BlindIndexKey string `yaml:"blind_index_key" env:"ENCRYPTION_BLIND_INDEX_KEY" flag:"encryption-blind-index-key" secret:"true"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED" flag:"rate-limit-enabled" default:"true"`
+	// Requests per Period is the default budget of each client IP, shared by
+	// all API routes; bursts of up to Requests calls are allowed
+	Requests int           `yaml:"requests" env:"RATE_LIMIT_REQUESTS" flag:"rate-limit-requests" default:"300"`
+	Period   time.Duration `yaml:"period" env:"RATE_LIMIT_PERIOD" flag:"rate-limit-period" default:"1m"`
+	// Routes adds stricter per-IP budgets to single routes, written
+	// "METHOD /route=requests/period" without the /api or /api/v1 prefix
+	Routes []string `yaml:"routes" env:"RATE_LIMIT_ROUTES" flag:"rate-limit-routes" default:"POST /members=5/1h,POST /events/{id}/register=20/1h"`
+}
+
+// RouteLimit is a parsed entry of RateLimitConfig.Routes
+type RouteLimit struct {
+	Method   string
+	Route    string
+	Requests int
+	Period   time.Duration
+}
+
+// RouteLimits parses the per-route budgets
+func (c *RateLimitConfig) RouteLimits() ([]RouteLimit, error) {
+	var limits []RouteLimit
+	for _, entry := range c.Routes {
+		target, budget, ok := strings.Cut(entry, "=")
+		method, route, ok2 := strings.Cut(strings.TrimSpace(target), " ")
+		requests, period, ok3 := strings.Cut(budget, "/")
+		if !ok || !ok2 || !ok3 || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("%q: format attendu \"METHODE /route=requêtes/période\"", entry)
+		}
+
+		limit := RouteLimit{Method: strings.ToUpper(method), Route: strings.TrimSpace(route)}
+		var err error
+		if limit.Requests, err = strconv.Atoi(requests); err != nil || limit.Requests <= 0 {
+			return nil, fmt.Errorf("%q: nombre de requêtes invalide", entry)
+		}
+		if limit.Period, err = time.ParseDuration(period); err != nil || limit.Period <= 0 {
+			return nil, fmt.Errorf("%q: période invalide", entry)
+		}
+		limits = append(limits, limit)
 	}
-	return defaultValue
-}
\ No newline at end of file
+	return limits, nil
+}
+
+type AntiSpamConfig struct {
+	// Enabled requires a signed form token on the public registration form
+	Enabled bool `yaml:"enabled" env:"ANTI_SPAM_ENABLED" flag:"anti-spam-enabled" default:"true"`
+	// FormSecret signs the form tokens; a random one is generated at startup
+	// when empty, which invalidates pending forms on restart
+	FormSecret string `yaml:"form_secret" env:"ANTI_SPAM_FORM_SECRET" flag:"anti-spam-form-secret" secret:"true"`
+	// MinFillTime rejects forms submitted faster than a human could fill them
+	MinFillTime time.Duration `yaml:"min_fill_time" env:"ANTI_SPAM_MIN_FILL_TIME" flag:"anti-spam-min-fill-time" default:"3s"`
+	TokenTTL    time.Duration `yaml:"token_ttl" env:"ANTI_SPAM_TOKEN_TTL" flag:"anti-spam-token-ttl" default:"2h"`
+}
+
+type EventsConfig struct {
+	// OccurrenceHorizon is how far ahead recurring events are expanded in
+	// listings
+	OccurrenceHorizon time.Duration `yaml:"occurrence_horizon" env:"EVENTS_OCCURRENCE_HORIZON" flag:"events-occurrence-horizon" default:"2160h"`
+	// DefaultTimezone (IANA) applies to events created without a timezone
+	// and to the local date-times they carry
+	DefaultTimezone string `yaml:"default_timezone" env:"EVENTS_DEFAULT_TIMEZONE" flag:"events-default-timezone" default:"Europe/Paris"`
+	// DefaultDuration sets the end of events created without one
+	DefaultDuration time.Duration `yaml:"default_duration" env:"EVENTS_DEFAULT_DURATION" flag:"events-default-duration" default:"2h"`
+	// GuestTokenTTL is how long the link confirming a guest registration
+	// stays valid
+	GuestTokenTTL time.Duration `yaml:"guest_token_ttl" env:"EVENTS_GUEST_TOKEN_TTL" flag:"events-guest-token-ttl" default:"48h"`
+	// SurveyTokenTTL is how long the link answering a survey stays valid
+	SurveyTokenTTL time.Duration `yaml:"survey_token_ttl" env:"EVENTS_SURVEY_TOKEN_TTL" flag:"events-survey-token-ttl" default:"336h"`
+}
+
+type MediaConfig struct {
+	// Driver selects where uploaded images are stored; only "local" for now
+	Driver string `yaml:"driver" env:"MEDIA_DRIVER" flag:"media-driver" default:"local"`
+	// Dir is the directory of the local driver
+	Dir string `yaml:"dir" env:"MEDIA_DIR" flag:"media-dir" default:"uploads"`
+	// MaxUploadSize is the largest accepted image, in bytes
+	MaxUploadSize int `yaml:"max_upload_size" env:"MEDIA_MAX_UPLOAD_SIZE" flag:"media-max-upload-size" default:"5242880"`
+	// MaxPixels rejects images whose decoding would take too much memory
+	MaxPixels int `yaml:"max_pixels" env:"MEDIA_MAX_PIXELS" flag:"media-max-pixels" default:"25000000"`
+	// ThumbnailSize bounds the width and height of thumbnails, in pixels
+	ThumbnailSize int `yaml:"thumbnail_size" env:"MEDIA_THUMBNAIL_SIZE" flag:"media-thumbnail-size" default:"400"`
+	// CacheMaxAge is how long clients may cache served images; their URL
+	// changes with their content
+	CacheMaxAge time.Duration `yaml:"cache_max_age" env:"MEDIA_CACHE_MAX_AGE" flag:"media-cache-max-age" default:"8760h"`
+}
+
+type AnnouncementsConfig struct {
+	// NotifyInterval is how often announcements newly published with
+	// notify are emailed to their audience
+	NotifyInterval time.Duration `yaml:"notify_interval" env:"ANNOUNCEMENTS_NOTIFY_INTERVAL" flag:"announcements-notify-interval" default:"5m"`
+}
+
+// IsDevelopment reports whether the server runs in development mode
+func (c *Config) IsDevelopment() bool {
+	return c.Server.Environment == "development"
+}
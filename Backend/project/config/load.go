@@ -0,0 +1,213 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const redactedValue = "********"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setting is a leaf field of Config together with its source tags
+type setting struct {
+	path   string
+	env    string
+	flag   string
+	def    string
+	secret bool
+	value  reflect.Value
+}
+
+// Load resolves the configuration from defaults, the config file (given by
+// --config or CONFIG_FILE), the environment and the command-line args.
+func Load(args []string) (*Config, error) {
+	cfg := &Config{}
+	settings := collect(reflect.ValueOf(cfg).Elem(), "")
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&cfg.File, "config", os.Getenv("CONFIG_FILE"), "chemin du fichier de configuration YAML")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "afficher la configuration résolue (secrets masqués) et quitter")
+
+	flagValues := make(map[string]*flagValue)
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		fv := &flagValue{isBool: s.value.Kind() == reflect.Bool}
+		flagValues[s.flag] = fv
+		fs.Var(fv, s.flag, fmt.Sprintf("%s (env %s)", s.path, s.env))
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// 1. Valeurs par défaut
+	for _, s := range settings {
+		if s.def == "" {
+			continue
+		}
+		if err := setValue(s.value, s.def); err != nil {
+			return nil, fmt.Errorf("défaut invalide pour %s: %w", s.path, err)
+		}
+	}
+
+	// 2. Fichier de configuration
+	if cfg.File != "" {
+		data, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("lecture de %s: %w", cfg.File, err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("analyse de %s: %w", cfg.File, err)
+		}
+	}
+
+	// 3. Variables d'environnement
+	for _, s := range settings {
+		raw, ok := os.LookupEnv(s.env)
+		if s.env == "" || !ok || raw == "" {
+			continue
+		}
+		if err := setValue(s.value, raw); err != nil {
+			return nil, fmt.Errorf("variable %s invalide: %w", s.env, err)
+		}
+	}
+
+	// 4. Options de la ligne de commande
+	for _, s := range settings {
+		fv, ok := flagValues[s.flag]
+		if !ok || !fv.set {
+			continue
+		}
+		if err := setValue(s.value, fv.raw); err != nil {
+			return nil, fmt.Errorf("option --%s invalide: %w", s.flag, err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Redacted returns a copy of the configuration with secrets masked
+func (c *Config) Redacted() *Config {
+	out := *c
+	for _, s := range collect(reflect.ValueOf(&out).Elem(), "") {
+		if !s.secret {
+			continue
+		}
+		switch s.value.Kind() {
+		case reflect.Slice:
+			// A fresh slice, so the original configuration is left intact
+			masked := make([]string, s.value.Len())
+			for i := range masked {
+				masked[i] = redactedValue
+			}
+			s.value.Set(reflect.ValueOf(masked))
+		default:
+			if s.value.String() != "" {
+				s.value.SetString(redactedValue)
+			}
+		}
+	}
+	return &out
+}
+
+// Print writes the configuration as YAML with secrets masked
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(c.Redacted())
+}
+
+// collect walks the Config struct and returns its leaf settings
+func collect(v reflect.Value, prefix string) []setting {
+	var settings []setting
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		path := prefix + name
+		if f.Type.Kind() == reflect.Struct && f.Type != durationType {
+			settings = append(settings, collect(v.Field(i), path+".")...)
+			continue
+		}
+		settings = append(settings, setting{
+			path:   path,
+			env:    f.Tag.Get("env"),
+			flag:   f.Tag.Get("flag"),
+			def:    f.Tag.Get("default"),
+			secret: f.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return settings
+}
+
+// setValue parses raw into the field according to its type
+func setValue(v reflect.Value, raw string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var values []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		v.Set(reflect.ValueOf(values))
+	default:
+		return fmt.Errorf("type non supporté %s", v.Type())
+	}
+	return nil
+}
+
+// flagValue records the raw value of a flag and whether it was given
+type flagValue struct {
+	raw    string
+	set    bool
+	isBool bool
+}
+
+func (f *flagValue) String() string { return f.raw }
+
+func (f *flagValue) Set(s string) error {
+	f.raw, f.set = s, true
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool { return f.isBool }
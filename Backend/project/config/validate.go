@@ -0,0 +1,223 @@
+package config
+
+import (
+	"beautiful-minds/backend/project/internal/encryption"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var validSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// ValidationError lists every problem found in the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	b.WriteString("configuration invalide:")
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// Validate checks the configuration and reports all problems at once
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Serveur
+	if !oneOf(c.Server.Environment, "development", "production") {
+		add("server.environment: %q doit être development ou production", c.Server.Environment)
+	}
+	if !validPort(c.Server.Port) {
+		add("server.port: %q n'est pas un port valide", c.Server.Port)
+	}
+	if c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 ||
+		c.Server.ReadHeaderTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		add("server: les délais HTTP doivent être positifs")
+	}
+
+	if u, err := url.Parse(c.Server.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		add("server.public_url: %q doit être une URL absolue", c.Server.PublicURL)
+	}
+	if _, err := time.Parse(time.DateOnly, c.Server.LegacyAPISunset); err != nil {
+		add("server.legacy_api_sunset: %q doit être une date AAAA-MM-JJ", c.Server.LegacyAPISunset)
+	}
+	if strings.ContainsAny(c.Server.ClientIPHeader, " \t:") {
+		add("server.client_ip_header: %q n'est pas un nom d'en-tête", c.Server.ClientIPHeader)
+	}
+
+	// Base de données
+	if c.Database.URL != "" {
+		u, err := url.Parse(c.Database.URL)
+		if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+			add("database.url doit être une URL postgres:// valide")
+		}
+	} else {
+		if c.Database.Host == "" {
+			add("database.host est obligatoire")
+		}
+		if !validPort(c.Database.Port) {
+			add("database.port: %q n'est pas un port valide", c.Database.Port)
+		}
+		if c.Database.User == "" {
+			add("database.user est obligatoire")
+		}
+		if c.Database.Name == "" {
+			add("database.name est obligatoire")
+		}
+	}
+	if !oneOf(c.Database.SSLMode, validSSLModes...) {
+		add("database.sslmode: %q doit être l'une de %s", c.Database.SSLMode, strings.Join(validSSLModes, ", "))
+	}
+	if c.Database.SSLRootCert != "" {
+		if _, err := os.Stat(c.Database.SSLRootCert); err != nil {
+			add("database.sslrootcert: %v", err)
+		}
+	}
+	if c.Database.ConnectTimeout <= 0 || c.Database.RetryMaxDelay <= 0 {
+		add("database: connect_timeout et retry_max_delay doivent être positifs")
+	}
+	if c.Database.ConnectRetries < 0 {
+		add("database.connect_retries ne peut pas être négatif")
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		add("database: les tailles de pool ne peuvent pas être négatives")
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("database.max_idle_conns (%d) dépasse database.max_open_conns (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	}
+	if c.Database.ConnMaxLifetime < 0 || c.Database.ConnMaxIdleTime < 0 {
+		add("database: conn_max_lifetime et conn_max_idle_time ne peuvent pas être négatifs")
+	}
+
+	// Journalisation
+	if !oneOf(strings.ToLower(c.Log.Level), "debug", "info", "warn", "warning", "error") {
+		add("log.level: %q doit être debug, info, warn ou error", c.Log.Level)
+	}
+	if !oneOf(strings.ToLower(c.Log.Format), "json", "text") {
+		add("log.format: %q doit être json ou text", c.Log.Format)
+	}
+
+	// CORS
+	if len(c.CORS.AllowedOrigins) == 0 {
+		add("cors.allowed_origins ne peut pas être vide")
+	}
+	if c.CORS.AllowCredentials && oneOf("*", c.CORS.AllowedOrigins...) {
+		add("cors: allow_credentials est incompatible avec l'origine \"*\"")
+	}
+	if c.CORS.MaxAge < 0 {
+		add("cors.max_age ne peut pas être négatif")
+	}
+
+	// SMTP
+	if c.SMTP.Host != "" {
+		if c.SMTP.Port <= 0 || c.SMTP.Port > 65535 {
+			add("smtp.port: %d n'est pas un port valide", c.SMTP.Port)
+		}
+		if c.SMTP.From == "" {
+			add("smtp.from est obligatoire quand smtp.host est défini")
+		}
+	}
+
+	// Corbeille
+	if c.Trash.Retention <= 0 || c.Trash.PurgeInterval <= 0 {
+		add("trash: retention et purge_interval doivent être positifs")
+	}
+
+	// RGPD
+	if c.Privacy.TokenTTL <= 0 {
+		add("privacy.token_ttl doit être positif")
+	}
+
+	// Chiffrement
+	if len(c.Encryption.Keys) == 0 || c.Encryption.CurrentKeyID == "" || c.Encryption.BlindIndexKey == "" {
+		add("encryption: keys, current_key_id et blind_index_key sont obligatoires")
+	} else if _, err := encryption.NewKeyring(c.Encryption.Keys, c.Encryption.CurrentKeyID, c.Encryption.BlindIndexKey); err != nil {
+		add("encryption: %v", err)
+	}
+
+	// Événements
+	if c.Events.OccurrenceHorizon <= 0 {
+		add("events.occurrence_horizon doit être positif")
+	}
+	if _, err := time.LoadLocation(c.Events.DefaultTimezone); err != nil || c.Events.DefaultTimezone == "Local" {
+		add("events.default_timezone: fuseau IANA inconnu %q", c.Events.DefaultTimezone)
+	}
+	if c.Events.DefaultDuration <= 0 {
+		add("events.default_duration doit être positif")
+	}
+	if c.Events.GuestTokenTTL <= 0 {
+		add("events.guest_token_ttl doit être positif")
+	}
+	if c.Events.SurveyTokenTTL <= 0 {
+		add("events.survey_token_ttl doit être positif")
+	}
+
+	// Médias
+	if c.Media.Driver != "local" {
+		add("media.driver: %q doit être local", c.Media.Driver)
+	}
+	if c.Media.Dir == "" {
+		add("media.dir est obligatoire")
+	}
+	if c.Media.MaxUploadSize <= 0 || c.Media.MaxPixels <= 0 || c.Media.ThumbnailSize <= 0 {
+		add("media: max_upload_size, max_pixels et thumbnail_size doivent être positifs")
+	}
+	if c.Media.CacheMaxAge < 0 {
+		add("media.cache_max_age ne peut pas être négatif")
+	}
+
+	// Annonces
+	if c.Announcements.NotifyInterval <= 0 {
+		add("announcements.notify_interval doit être positif")
+	}
+
+	// Limitation de débit
+	if c.RateLimit.Enabled {
+		if c.RateLimit.Requests <= 0 || c.RateLimit.Period <= 0 {
+			add("rate_limit: requests et period doivent être positifs")
+		}
+		if _, err := c.RateLimit.RouteLimits(); err != nil {
+			add("rate_limit.routes: %v", err)
+		}
+	}
+
+	// Anti-spam
+	if c.AntiSpam.Enabled {
+		if c.AntiSpam.MinFillTime < 0 || c.AntiSpam.TokenTTL <= c.AntiSpam.MinFillTime {
+			add("anti_spam: token_ttl doit être supérieur à min_fill_time")
+		}
+		if c.AntiSpam.FormSecret != "" && len(c.AntiSpam.FormSecret) < 32 {
+			add("anti_spam.form_secret doit contenir au moins 32 caractères")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func oneOf(v string, values ...string) bool {
+	for _, x := range values {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
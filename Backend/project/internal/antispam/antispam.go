@@ -0,0 +1,266 @@
+package antispam
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"beautiful-minds/backend/project/config"
+	"beautiful-minds/backend/project/internal/logging"
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/middleware"
+)
+
+// TokenHeader carries the form token on protected submissions
+const TokenHeader = "X-Form-Token"
+
+// HoneypotField is a form field hidden from humans; bots filling every input
+// give themselves away by setting it
+const HoneypotField = "website"
+
+// maxBody bounds the body read to look for the honeypot
+const maxBody = 1 << 20
+
+var (
+	ErrMissingToken = errors.New("jeton de formulaire manquant")
+	ErrInvalidToken = errors.New("jeton de formulaire invalide")
+	ErrExpiredToken = errors.New("jeton de formulaire expiré")
+	ErrReusedToken  = errors.New("jeton de formulaire déjà utilisé")
+	ErrTooFast      = errors.New("formulaire soumis trop rapidement")
+)
+
+// Guard issues and checks signed form tokens. A token records when the form
+// was served: it must be submitted after MinFillTime, before TokenTTL, and
+// only once.
+type Guard struct {
+	enabled     bool
+	secret      []byte
+	minFillTime time.Duration
+	ttl         time.Duration
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewGuard builds a guard from the configuration, generating a signing
+// secret when none is configured
+func NewGuard(cfg *config.AntiSpamConfig) (*Guard, error) {
+	secret := []byte(cfg.FormSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Guard{
+		enabled:     cfg.Enabled,
+		secret:      secret,
+		minFillTime: cfg.MinFillTime,
+		ttl:         cfg.TokenTTL,
+		used:        make(map[string]time.Time),
+	}, nil
+}
+
+// Issue returns a new form token and its expiry
+func (g *Guard) Issue() (string, time.Time, error) {
+	now := time.Now()
+
+	payload := make([]byte, 8+16)
+	binary.BigEndian.PutUint64(payload, uint64(now.Unix()))
+	if _, err := rand.Read(payload[8:]); err != nil {
+		return "", time.Time{}, err
+	}
+
+	token := encode(payload) + "." + encode(g.sign(payload))
+	return token, now.Add(g.ttl), nil
+}
+
+// Verify checks a token and reserves it, returning its nonce. A rejected
+// submission can give the token back with Release.
+func (g *Guard) Verify(token string) (string, error) {
+	if token == "" {
+		return "", ErrMissingToken
+	}
+
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	payload, err1 := base64.RawURLEncoding.DecodeString(encodedPayload)
+	sig, err2 := base64.RawURLEncoding.DecodeString(encodedSig)
+	if !ok || err1 != nil || err2 != nil || len(payload) != 24 || !hmac.Equal(sig, g.sign(payload)) {
+		return "", ErrInvalidToken
+	}
+
+	now := time.Now()
+	issuedAt := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	switch {
+	case now.Sub(issuedAt) < g.minFillTime:
+		return "", ErrTooFast
+	case now.Sub(issuedAt) > g.ttl:
+		return "", ErrExpiredToken
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// Tokens past their TTL are rejected anyway, so they can be forgotten
+	for nonce, expiresAt := range g.used {
+		if now.After(expiresAt) {
+			delete(g.used, nonce)
+		}
+	}
+
+	nonce := string(payload[8:])
+	if _, seen := g.used[nonce]; seen {
+		return "", ErrReusedToken
+	}
+	g.used[nonce] = issuedAt.Add(g.ttl)
+
+	return nonce, nil
+}
+
+// Release makes a reserved token usable again
+func (g *Guard) Release(nonce string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.used, nonce)
+}
+
+// Protect rejects submissions without a valid form token or with the
+// honeypot filled. Administrators are trusted and skip the checks.
+func (g *Guard) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.enabled || middleware.HasRole(r, middleware.RoleAdmin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil {
+			http.Error(w, "Données invalides", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxBody {
+			http.Error(w, "Données trop volumineuses", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if honeypotFilled(r.Header.Get("Content-Type"), body) {
+			// Same answer as a bad token so bots learn nothing
+			reject(w, r, "honeypot", ErrInvalidToken)
+			return
+		}
+
+		nonce, err := g.Verify(r.Header.Get(TokenHeader))
+		if err != nil {
+			reject(w, r, reason(err), err)
+			return
+		}
+
+		// A submission the user can correct and resend (invalid data,
+		// duplicate email...) keeps the token. Any other failure spends it,
+		// so that a server error cannot be used to replay a token.
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if correctable(rec.status) {
+			g.Release(nonce)
+		}
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// correctable reports whether a handler status is a validation failure the
+// user can fix
+func correctable(status int) bool {
+	switch status {
+	case http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+// honeypotFilled looks for the honeypot in a JSON, URL-encoded or multipart
+// body
+func honeypotFilled(contentType string, body []byte) bool {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+
+	var value string
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return false
+		}
+		value = values.Get(HoneypotField)
+	case "multipart/form-data":
+		form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(maxBody)
+		if err != nil {
+			return false
+		}
+		defer form.RemoveAll()
+		if values := form.Value[HoneypotField]; len(values) > 0 {
+			value = values[0]
+		}
+	default:
+		var fields map[string]any
+		if json.Unmarshal(body, &fields) != nil {
+			return false
+		}
+		value, _ = fields[HoneypotField].(string)
+	}
+	return strings.TrimSpace(value) != ""
+}
+
+func reject(w http.ResponseWriter, r *http.Request, reason string, err error) {
+	metrics.SpamRejected.WithLabelValues(reason).Inc()
+	logging.FromContext(r.Context()).Warn("soumission de formulaire rejetée",
+		"reason", reason, "ip", middleware.ClientIP(r))
+	http.Error(w, err.Error()+", veuillez recharger le formulaire", http.StatusBadRequest)
+}
+
+func reason(err error) string {
+	switch err {
+	case ErrMissingToken:
+		return "missing_token"
+	case ErrExpiredToken:
+		return "expired_token"
+	case ErrReusedToken:
+		return "reused_token"
+	case ErrTooFast:
+		return "too_fast"
+	default:
+		return "invalid_token"
+	}
+}
+
+func (g *Guard) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,174 @@
+package antispam
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"beautiful-minds/backend/project/config"
+)
+
+func newTestGuard(t *testing.T, minFillTime, ttl time.Duration) *Guard {
+	t.Helper()
+	g, err := NewGuard(&config.AntiSpamConfig{Enabled: true, MinFillTime: minFillTime, TokenTTL: ttl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func issue(t *testing.T, g *Guard) string {
+	t.Helper()
+	token, _, err := g.Issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// tamper changes the first character of the token's signature
+func tamper(token string) string {
+	payload, sig, _ := strings.Cut(token, ".")
+	first := "A"
+	if sig[0] == 'A' {
+		first = "B"
+	}
+	return payload + "." + first + sig[1:]
+}
+
+func TestVerify(t *testing.T) {
+	g := newTestGuard(t, 0, time.Hour)
+	token := issue(t, g)
+
+	nonce, err := g.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := g.Verify(token); err != ErrReusedToken {
+		t.Fatalf("second Verify: got %v, want ErrReusedToken", err)
+	}
+
+	g.Release(nonce)
+	if _, err := g.Verify(token); err != nil {
+		t.Fatalf("Verify after Release: %v", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	g := newTestGuard(t, 0, time.Hour)
+	other := newTestGuard(t, 0, time.Hour)
+
+	tests := []struct {
+		name  string
+		guard *Guard
+		token string
+		want  error
+	}{
+		{"missing", g, "", ErrMissingToken},
+		{"malformed", g, "not-a-token", ErrInvalidToken},
+		{"foreign signature", g, issue(t, other), ErrInvalidToken},
+		{"tampered", g, tamper(issue(t, g)), ErrInvalidToken},
+		{"too fast", newTestGuard(t, time.Hour, 2*time.Hour), "", nil},
+		{"expired", newTestGuard(t, 0, -time.Second), "", nil},
+	}
+	tests[4].token, tests[4].want = issue(t, tests[4].guard), ErrTooFast
+	tests[5].token, tests[5].want = issue(t, tests[5].guard), ErrExpiredToken
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.guard.Verify(tt.token); err != tt.want {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHoneypotFilled(t *testing.T) {
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	mw.WriteField("first_name", "Ada")
+	mw.WriteField(HoneypotField, "http://spam.example")
+	mw.Close()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"json empty", "application/json", `{"first_name":"Ada","website":""}`, false},
+		{"json filled", "application/json", `{"website":"http://spam.example"}`, true},
+		{"json blank", "application/json", `{"website":"  "}`, false},
+		{"json not a string", "application/json", `{"website":42}`, false},
+		{"no content type", "", `{"website":"x"}`, true},
+		{"form filled", "application/x-www-form-urlencoded", "first_name=Ada&website=x", true},
+		{"form empty", "application/x-www-form-urlencoded", "first_name=Ada&website=", false},
+		{"multipart filled", mw.FormDataContentType(), multipartBody.String(), true},
+		{"not parseable", "application/json", "{", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := honeypotFilled(tt.contentType, []byte(tt.body)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtect(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		tokenReusable bool
+	}{
+		{"success spends the token", http.StatusCreated, false},
+		{"validation failure keeps it", http.StatusBadRequest, true},
+		{"conflict keeps it", http.StatusConflict, true},
+		{"not found spends it", http.StatusNotFound, false},
+		{"server error spends it", http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTestGuard(t, 0, time.Hour)
+			token := issue(t, g)
+
+			handler := g.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/members", strings.NewReader(`{}`))
+			req.Header.Set(TokenHeader, token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d", rec.Code, tt.status)
+			}
+			_, err := g.Verify(token)
+			if reusable := err == nil; reusable != tt.tokenReusable {
+				t.Errorf("token reusable %v, want %v (Verify: %v)", reusable, tt.tokenReusable, err)
+			}
+		})
+	}
+}
+
+func TestProtectRejectsHoneypot(t *testing.T) {
+	g := newTestGuard(t, 0, time.Hour)
+	called := false
+	handler := g.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/members", strings.NewReader("website=x"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set(TokenHeader, issue(t, g))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || called {
+		t.Errorf("status %d, handler called %v; want 400 without calling the handler", rec.Code, called)
+	}
+}
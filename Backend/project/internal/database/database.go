@@ -1,27 +1,110 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
 
-	_ "github.com/lib/pq"
 	"beautiful-minds/backend/project/config"
+
+	_ "github.com/lib/pq"
 )
 
+// Connect opens the connection pool and waits for Postgres to accept
+// connections, retrying with exponential backoff (useful while the
+// docker-compose container is still booting).
 func Connect(cfg *config.Config) (*sql.DB, error) {
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName,
-	)
+	dsn, err := DSN(&cfg.Database)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.Database.ConnMaxIdleTime)
+
+	if err := pingWithRetry(db, &cfg.Database); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
-}
\ No newline at end of file
+}
+
+// DSN builds a postgres:// URL from the configuration. Credentials and
+// parameters are escaped, so passwords containing spaces or quotes work.
+func DSN(cfg *config.DatabaseConfig) (string, error) {
+	var u *url.URL
+	if cfg.URL != "" {
+		parsed, err := url.Parse(cfg.URL)
+		if err != nil {
+			return "", fmt.Errorf("DATABASE_URL invalide: %w", err)
+		}
+		u = parsed
+	} else {
+		u = &url.URL{
+			Scheme: "postgres",
+			User:   url.UserPassword(cfg.User, cfg.Password),
+			Host:   net.JoinHostPort(cfg.Host, cfg.Port),
+			Path:   "/" + cfg.Name,
+		}
+	}
+
+	// Explicit parameters in DATABASE_URL win over the individual settings
+	q := u.Query()
+	setDefault(q, "sslmode", cfg.SSLMode)
+	setDefault(q, "sslrootcert", cfg.SSLRootCert)
+	if cfg.ConnectTimeout > 0 {
+		// Whole seconds rounded up: libpq reads 0 as "wait forever"
+		seconds := int(math.Ceil(cfg.ConnectTimeout.Seconds()))
+		setDefault(q, "connect_timeout", strconv.Itoa(seconds))
+	}
+	// TIMESTAMP columns hold UTC: NOW() and the values read back must agree
+	q.Set("timezone", "UTC")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+func setDefault(q url.Values, key, value string) {
+	if value != "" && q.Get(key) == "" {
+		q.Set(key, value)
+	}
+}
+
+func pingWithRetry(db *sql.DB, cfg *config.DatabaseConfig) error {
+	delay := 500 * time.Millisecond
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil || attempt >= cfg.ConnectRetries {
+			break
+		}
+
+		slog.Warn("PostgreSQL indisponible, nouvelle tentative",
+			"attempt", attempt+1, "retries", cfg.ConnectRetries, "delay", delay, "error", err)
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > cfg.RetryMaxDelay {
+			delay = cfg.RetryMaxDelay
+		}
+	}
+
+	return err
+}
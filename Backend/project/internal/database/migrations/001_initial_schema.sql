@@ -0,0 +1,41 @@
+-- Schéma de base, tel qu'il existait avant l'introduction des migrations
+CREATE TABLE IF NOT EXISTS members (
+    id                SERIAL PRIMARY KEY,
+    first_name        VARCHAR(100) NOT NULL,
+    last_name         VARCHAR(100) NOT NULL,
+    email             VARCHAR(120) NOT NULL UNIQUE,
+    phone             VARCHAR(30) NOT NULL DEFAULT '',
+    student_id        VARCHAR(50) NOT NULL DEFAULT '',
+    field_of_study    VARCHAR(100) NOT NULL DEFAULT '',
+    registration_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    is_active         BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at        TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS events (
+    id               SERIAL PRIMARY KEY,
+    title            VARCHAR(200) NOT NULL,
+    description      TEXT NOT NULL DEFAULT '',
+    date             TIMESTAMP NOT NULL,
+    location         VARCHAR(200) NOT NULL DEFAULT '',
+    image_url        TEXT,
+    max_participants INTEGER NOT NULL DEFAULT 0,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS event_registrations (
+    id            SERIAL PRIMARY KEY,
+    event_id      INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    member_id     INTEGER NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    registered_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (event_id, member_id)
+);
+
+CREATE TABLE IF NOT EXISTS announcements (
+    id             SERIAL PRIMARY KEY,
+    title          VARCHAR(200) NOT NULL,
+    content        TEXT NOT NULL,
+    published_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    is_pinned      BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW()
+);
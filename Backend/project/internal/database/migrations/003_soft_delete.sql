@@ -0,0 +1,12 @@
+-- Suppression logique : les lignes restent dans la corbeille jusqu'à la purge
+ALTER TABLE members ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE announcements ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS members_deleted_at_idx ON members (deleted_at);
+CREATE INDEX IF NOT EXISTS events_deleted_at_idx ON events (deleted_at);
+CREATE INDEX IF NOT EXISTS announcements_deleted_at_idx ON announcements (deleted_at);
+
+-- Un email ne doit être unique que parmi les membres non supprimés
+ALTER TABLE members DROP CONSTRAINT IF EXISTS members_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS members_email_active_key ON members (email) WHERE deleted_at IS NULL;
@@ -0,0 +1,18 @@
+-- Demandes d'export et d'effacement des données personnelles (RGPD)
+ALTER TABLE members ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS privacy_requests (
+    id           SERIAL PRIMARY KEY,
+    member_id    INTEGER REFERENCES members(id) ON DELETE SET NULL,
+    type         VARCHAR(20) NOT NULL CHECK (type IN ('export', 'erasure')),
+    status       VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'completed')),
+    source       VARCHAR(20) NOT NULL CHECK (source IN ('self', 'admin')),
+    token_hash   VARCHAR(64),
+    ip           VARCHAR(64) NOT NULL DEFAULT '',
+    requested_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMP,
+    completed_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS privacy_requests_token_idx ON privacy_requests (token_hash);
+CREATE INDEX IF NOT EXISTS privacy_requests_member_idx ON privacy_requests (member_id);
@@ -0,0 +1,8 @@
+-- Chiffrement au repos du téléphone et du numéro étudiant. Les valeurs en
+-- clair existantes sont chiffrées au démarrage par le serveur.
+ALTER TABLE members ALTER COLUMN phone TYPE TEXT;
+ALTER TABLE members ALTER COLUMN student_id TYPE TEXT;
+
+-- Index aveugle (HMAC) permettant la recherche exacte par numéro étudiant
+ALTER TABLE members ADD COLUMN IF NOT EXISTS student_id_index VARCHAR(64);
+CREATE INDEX IF NOT EXISTS members_student_id_index_idx ON members (student_id_index);
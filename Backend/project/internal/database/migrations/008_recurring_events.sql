@@ -0,0 +1,26 @@
+-- Événements récurrents : règle RRULE (sous-ensemble), dates exclues et
+-- date de la dernière occurrence (NULL si la série ne se termine pas)
+ALTER TABLE events ADD COLUMN IF NOT EXISTS recurrence_rule TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS recurrence_exdates TIMESTAMP[] NOT NULL DEFAULT '{}';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS recurrence_end TIMESTAMP;
+
+-- Modification ou annulation d'une occurrence, identifiée par sa date de
+-- début d'origine ; les colonnes NULL reprennent la valeur de la série
+CREATE TABLE IF NOT EXISTS event_occurrences (
+    event_id         INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    occurrence_start TIMESTAMP NOT NULL,
+    cancelled        BOOLEAN NOT NULL DEFAULT FALSE,
+    title            VARCHAR(200),
+    description      TEXT,
+    date             TIMESTAMP,
+    location         VARCHAR(200),
+    max_participants INTEGER,
+    updated_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, occurrence_start)
+);
+
+-- Inscription à une occurrence précise d'une série
+ALTER TABLE event_registrations ADD COLUMN IF NOT EXISTS occurrence_start TIMESTAMP;
+ALTER TABLE event_registrations DROP CONSTRAINT IF EXISTS event_registrations_event_id_member_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS event_registrations_occurrence_key
+    ON event_registrations (event_id, member_id, COALESCE(occurrence_start, 'epoch'));
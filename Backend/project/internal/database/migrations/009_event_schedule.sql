@@ -0,0 +1,42 @@
+-- Horaires des événements : début et fin stockés en UTC, fuseau IANA pour
+-- l'affichage et le calcul des occurrences.
+-- Les dates existantes ont été saisies en heure locale du club : elles sont
+-- converties en UTC, avec les dates des séries récurrentes qui en dépendent,
+-- et l'événement dure deux heures par défaut.
+--
+-- Les autres colonnes TIMESTAMP (created_at, deleted_at, expirations des
+-- jetons...) sont écrites par NOW() dans le fuseau de la session, désormais
+-- forcé à UTC par la connexion. Elles étaient jusqu'ici dans le fuseau du
+-- serveur PostgreSQL, UTC pour l'image Docker du projet : elles ne sont donc
+-- pas converties. Une base dont le paramètre TimeZone était autre les
+-- convertit une fois, par exemple :
+--   UPDATE members SET created_at = (created_at AT TIME ZONE 'Europe/Paris') AT TIME ZONE 'UTC';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'Europe/Paris';
+ALTER TABLE events ADD COLUMN IF NOT EXISTS end_date TIMESTAMP;
+
+-- Les dates d'occurrence des séries non converties (end_date NULL) suivent
+-- leur événement : elles désignent les occurrences par leur début.
+UPDATE event_occurrences o
+SET occurrence_start = (o.occurrence_start AT TIME ZONE e.timezone) AT TIME ZONE 'UTC',
+    date = (o.date AT TIME ZONE e.timezone) AT TIME ZONE 'UTC'
+FROM events e
+WHERE e.id = o.event_id AND e.end_date IS NULL;
+
+UPDATE event_registrations r
+SET occurrence_start = (r.occurrence_start AT TIME ZONE e.timezone) AT TIME ZONE 'UTC'
+FROM events e
+WHERE e.id = r.event_id AND e.end_date IS NULL AND r.occurrence_start IS NOT NULL;
+
+UPDATE events
+SET date = (date AT TIME ZONE timezone) AT TIME ZONE 'UTC',
+    end_date = ((date AT TIME ZONE timezone) AT TIME ZONE 'UTC') + INTERVAL '2 hours',
+    recurrence_exdates = ARRAY(
+        SELECT (d AT TIME ZONE timezone) AT TIME ZONE 'UTC'
+        FROM unnest(recurrence_exdates) WITH ORDINALITY AS x(d, i)
+        ORDER BY i),
+    recurrence_end = (recurrence_end AT TIME ZONE timezone) AT TIME ZONE 'UTC'
+WHERE end_date IS NULL;
+
+ALTER TABLE events ALTER COLUMN end_date SET NOT NULL;
+ALTER TABLE events DROP CONSTRAINT IF EXISTS events_end_after_start;
+ALTER TABLE events ADD CONSTRAINT events_end_after_start CHECK (end_date > date);
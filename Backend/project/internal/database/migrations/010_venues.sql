@@ -0,0 +1,16 @@
+-- Salles réservées par les événements. Le lieu texte des événements reste
+-- renseigné (nom de la salle) pour l'affichage et les événements extérieurs.
+CREATE TABLE IF NOT EXISTS venues (
+    id         SERIAL PRIMARY KEY,
+    name       VARCHAR(100) NOT NULL UNIQUE,
+    building   VARCHAR(100) NOT NULL DEFAULT '',
+    capacity   INTEGER NOT NULL CHECK (capacity > 0),
+    equipment  TEXT[] NOT NULL DEFAULT '{}',
+    notes      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE events ADD COLUMN IF NOT EXISTS venue_id INTEGER REFERENCES venues(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS events_venue_idx ON events (venue_id, date) WHERE deleted_at IS NULL;
@@ -0,0 +1,48 @@
+-- Intervenants, membres du club ou personnes extérieures
+CREATE TABLE IF NOT EXISTS speakers (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(200) NOT NULL,
+    bio         TEXT NOT NULL DEFAULT '',
+    photo_url   TEXT NOT NULL DEFAULT '',
+    affiliation VARCHAR(200) NOT NULL DEFAULT '',
+    member_id   INTEGER REFERENCES members(id) ON DELETE SET NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Sessions du programme d'un événement (horaires en UTC). Sans salle, room
+-- décrit librement le lieu de la session.
+CREATE TABLE IF NOT EXISTS event_sessions (
+    id                SERIAL PRIMARY KEY,
+    event_id          INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    title             VARCHAR(200) NOT NULL,
+    description       TEXT NOT NULL DEFAULT '',
+    start_time        TIMESTAMP NOT NULL,
+    end_time          TIMESTAMP NOT NULL,
+    venue_id          INTEGER REFERENCES venues(id) ON DELETE SET NULL,
+    room              VARCHAR(200) NOT NULL DEFAULT '',
+    -- Inscription propre à la session, limitée à capacity places (0 : sans limite)
+    registration_open BOOLEAN NOT NULL DEFAULT FALSE,
+    capacity          INTEGER NOT NULL DEFAULT 0 CHECK (capacity >= 0),
+    created_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (end_time > start_time)
+);
+
+CREATE INDEX IF NOT EXISTS event_sessions_event_idx ON event_sessions (event_id, start_time);
+
+CREATE TABLE IF NOT EXISTS session_speakers (
+    session_id INTEGER NOT NULL REFERENCES event_sessions(id) ON DELETE CASCADE,
+    speaker_id INTEGER NOT NULL REFERENCES speakers(id) ON DELETE CASCADE,
+    position   INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (session_id, speaker_id)
+);
+
+CREATE INDEX IF NOT EXISTS session_speakers_speaker_idx ON session_speakers (speaker_id);
+
+CREATE TABLE IF NOT EXISTS session_registrations (
+    session_id    INTEGER NOT NULL REFERENCES event_sessions(id) ON DELETE CASCADE,
+    member_id     INTEGER NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    registered_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (session_id, member_id)
+);
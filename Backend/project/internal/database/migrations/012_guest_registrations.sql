@@ -0,0 +1,35 @@
+-- Places réservées aux invités (non-membres) ; 0 ferme l'événement aux invités.
+-- Elles s'ajoutent à max_participants, réservé aux membres.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS guest_capacity INTEGER NOT NULL DEFAULT 0 CHECK (guest_capacity >= 0);
+
+-- Invités identifiés par leur email, vérifié à la confirmation de leur
+-- première inscription. member_id est renseigné quand l'invité devient membre.
+CREATE TABLE IF NOT EXISTS guests (
+    id                SERIAL PRIMARY KEY,
+    first_name        VARCHAR(100) NOT NULL,
+    last_name         VARCHAR(100) NOT NULL,
+    email             VARCHAR(120) NOT NULL,
+    email_verified_at TIMESTAMP,
+    member_id         INTEGER REFERENCES members(id) ON DELETE SET NULL,
+    converted_at      TIMESTAMP,
+    created_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS guests_email_key ON guests (LOWER(email));
+
+-- Inscriptions des invités, en attente tant que le lien envoyé par email
+-- n'a pas été suivi (token_hash est alors effacé)
+CREATE TABLE IF NOT EXISTS guest_registrations (
+    id               SERIAL PRIMARY KEY,
+    event_id         INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    guest_id         INTEGER NOT NULL REFERENCES guests(id) ON DELETE CASCADE,
+    occurrence_start TIMESTAMP,
+    token_hash       VARCHAR(64) UNIQUE,
+    token_expires_at TIMESTAMP,
+    registered_at    TIMESTAMP NOT NULL DEFAULT NOW(),
+    confirmed_at     TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS guest_registrations_occurrence_key
+    ON guest_registrations (event_id, guest_id, COALESCE(occurrence_start, 'epoch'));
@@ -0,0 +1,48 @@
+-- Présence constatée à l'événement (ou à l'occurrence) ; seuls les présents
+-- répondent au questionnaire de satisfaction
+ALTER TABLE event_registrations ADD COLUMN IF NOT EXISTS attended_at TIMESTAMP;
+
+-- Questionnaire de satisfaction, un par événement
+CREATE TABLE IF NOT EXISTS surveys (
+    id          SERIAL PRIMARY KEY,
+    event_id    INTEGER NOT NULL UNIQUE REFERENCES events(id) ON DELETE CASCADE,
+    title       VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    closes_at   TIMESTAMP,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Questions : note de 1 à 5 (rating), choix parmi options (choice, plusieurs
+-- réponses si multiple) ou texte libre (text)
+CREATE TABLE IF NOT EXISTS survey_questions (
+    id        SERIAL PRIMARY KEY,
+    survey_id INTEGER NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+    position  INTEGER NOT NULL,
+    kind      VARCHAR(10) NOT NULL CHECK (kind IN ('rating', 'choice', 'text')),
+    label     VARCHAR(500) NOT NULL,
+    required  BOOLEAN NOT NULL DEFAULT FALSE,
+    options   TEXT[] NOT NULL DEFAULT '{}',
+    multiple  BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS survey_questions_survey_idx ON survey_questions (survey_id, position);
+
+-- Une réponse par membre ; elle survit à la suppression du membre pour les
+-- résultats agrégés
+CREATE TABLE IF NOT EXISTS survey_responses (
+    id           SERIAL PRIMARY KEY,
+    survey_id    INTEGER NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+    member_id    INTEGER REFERENCES members(id) ON DELETE SET NULL,
+    submitted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (survey_id, member_id)
+);
+
+CREATE TABLE IF NOT EXISTS survey_answers (
+    response_id INTEGER NOT NULL REFERENCES survey_responses(id) ON DELETE CASCADE,
+    question_id INTEGER NOT NULL REFERENCES survey_questions(id) ON DELETE CASCADE,
+    rating      INTEGER CHECK (rating BETWEEN 1 AND 5),
+    choices     TEXT[] NOT NULL DEFAULT '{}',
+    text        TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (response_id, question_id)
+);
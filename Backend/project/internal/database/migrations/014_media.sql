@@ -0,0 +1,19 @@
+-- Images envoyées et leur miniature ; la clé de stockage dérive du SHA-256
+-- du contenu, un même fichier n'est donc stocké qu'une fois
+CREATE TABLE IF NOT EXISTS media (
+    id            SERIAL PRIMARY KEY,
+    storage_key   VARCHAR(100) NOT NULL UNIQUE,
+    thumbnail_key VARCHAR(100) NOT NULL,
+    content_type  VARCHAR(50) NOT NULL,
+    size          BIGINT NOT NULL,
+    width         INTEGER NOT NULL,
+    height        INTEGER NOT NULL,
+    url           TEXT NOT NULL,
+    thumbnail_url TEXT NOT NULL,
+    original_name VARCHAR(255) NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Image envoyée d'un événement ; image_url reprend alors son URL. Une image
+-- utilisée ne peut pas être supprimée.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS image_id INTEGER REFERENCES media(id);
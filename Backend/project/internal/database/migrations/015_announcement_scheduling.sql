@@ -0,0 +1,8 @@
+-- Brouillons et expiration des annonces ; une date de publication future
+-- programme l'annonce. Les listes publiques ne montrent que les annonces
+-- publiées et non expirées.
+ALTER TABLE announcements ADD COLUMN IF NOT EXISTS draft BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE announcements ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS announcements_published_idx ON announcements (published_date)
+    WHERE deleted_at IS NULL AND NOT draft;
@@ -0,0 +1,17 @@
+-- Rôles des membres (bureau, groupe robotique...), librement choisis
+ALTER TABLE members ADD COLUMN IF NOT EXISTS roles TEXT[] NOT NULL DEFAULT '{}';
+
+-- Public des annonces : filières, rôles et inscrits à des événements. Un
+-- membre est visé s'il répond à l'un des critères ; sans critère, l'annonce
+-- s'adresse à tous.
+ALTER TABLE announcements ADD COLUMN IF NOT EXISTS audience_fields TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE announcements ADD COLUMN IF NOT EXISTS audience_roles TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE announcements ADD COLUMN IF NOT EXISTS audience_event_ids INTEGER[] NOT NULL DEFAULT '{}';
+
+-- Notification par email du public à la publication ; notified_at évite un
+-- second envoi
+ALTER TABLE announcements ADD COLUMN IF NOT EXISTS notify BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE announcements ADD COLUMN IF NOT EXISTS notified_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS announcements_pending_notification_idx ON announcements (published_date)
+    WHERE notify AND notified_at IS NULL AND deleted_at IS NULL;
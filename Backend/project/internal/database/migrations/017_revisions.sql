@@ -0,0 +1,12 @@
+-- Historique des versions des événements et des annonces : un instantané
+-- complet par version de la ligne, pour comparer et revenir en arrière
+CREATE TABLE IF NOT EXISTS revisions (
+    id          BIGSERIAL PRIMARY KEY,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id   INTEGER NOT NULL,
+    version     INTEGER NOT NULL,
+    snapshot    JSONB NOT NULL,
+    author      VARCHAR(255) NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (entity_type, entity_id, version)
+);
@@ -0,0 +1,23 @@
+-- Effacement des données personnelles : le journal d'audit reste en ajout
+-- seul, sauf pour l'anonymisation d'un membre. Sa transaction désigne le
+-- membre avec set_config('audit_log.erase_member', id, true) et ne peut
+-- alors que vider les instantanés et l'adresse IP des entrées de ce membre ;
+-- la suppression reste interdite.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE'
+       AND OLD.entity_type = 'member'
+       AND current_setting('audit_log.erase_member', true) = OLD.entity_id::text
+       AND NEW.id = OLD.id
+       AND NEW.actor = OLD.actor
+       AND NEW.action = OLD.action
+       AND NEW.entity_type = OLD.entity_type
+       AND NEW.entity_id = OLD.entity_id
+       AND NEW.ip IN (OLD.ip, '')
+       AND NEW.request_id = OLD.request_id
+       AND NEW.created_at = OLD.created_at THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_log est en ajout seul';
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,24 @@
+-- Les entrées d'audit des membres écrites avant leur masquage complet
+-- gardent nom, email, téléphone et numéro étudiant, en clair ou en partie.
+-- Elles reçoivent le marqueur écrit désormais par l'application ; les
+-- changements de ces champs sont retirés, comme pour les nouvelles entrées.
+CREATE FUNCTION pg_temp.audit_redact_member(doc JSONB) RETURNS JSONB AS $$
+    SELECT doc || COALESCE(
+        (SELECT jsonb_object_agg(key, '"[masqué]"'::jsonb)
+         FROM jsonb_each(doc)
+         WHERE key IN ('first_name', 'last_name', 'email', 'phone', 'student_id')
+           AND value NOT IN ('""'::jsonb, '"[masqué]"'::jsonb, 'null'::jsonb)),
+        '{}'::jsonb)
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Réécriture ponctuelle, hors du seul cas admis par le déclencheur d'ajout seul
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_no_update;
+
+UPDATE audit_log
+SET before = pg_temp.audit_redact_member(before),
+    after = pg_temp.audit_redact_member(after),
+    changes = changes - ARRAY['first_name', 'last_name', 'email', 'phone', 'student_id']
+WHERE entity_type = 'member'
+  AND (before IS NOT NULL OR after IS NOT NULL OR changes IS NOT NULL);
+
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_no_update;
@@ -0,0 +1,5 @@
+-- Une inscription d'invité devenue celle d'un membre à sa conversion garde
+-- sa place invité : elle reste comptée dans guest_capacity et non dans
+-- max_participants. Les conversions antérieures ne sont pas retrouvables
+-- et restent comptées comme des places membres.
+ALTER TABLE event_registrations ADD COLUMN IF NOT EXISTS guest_seat BOOLEAN NOT NULL DEFAULT FALSE;
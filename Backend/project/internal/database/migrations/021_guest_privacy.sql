@@ -0,0 +1,25 @@
+-- Demandes RGPD des invités : une demande concerne un membre ou un invité
+ALTER TABLE privacy_requests ADD COLUMN IF NOT EXISTS guest_id INTEGER REFERENCES guests(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS privacy_requests_guest_idx ON privacy_requests (guest_id);
+
+-- Les entrées d'audit des invités ne gardent plus leur nom ni leur email,
+-- comme celles des membres (019_audit_member_redaction.sql)
+CREATE FUNCTION pg_temp.audit_redact_guest(doc JSONB) RETURNS JSONB AS $$
+    SELECT doc || COALESCE(
+        (SELECT jsonb_object_agg(key, '"[masqué]"'::jsonb)
+         FROM jsonb_each(doc)
+         WHERE key IN ('first_name', 'last_name', 'email')
+           AND value NOT IN ('""'::jsonb, '"[masqué]"'::jsonb, 'null'::jsonb)),
+        '{}'::jsonb)
+$$ LANGUAGE sql IMMUTABLE;
+
+ALTER TABLE audit_log DISABLE TRIGGER audit_log_no_update;
+
+UPDATE audit_log
+SET before = pg_temp.audit_redact_guest(before),
+    after = pg_temp.audit_redact_guest(after),
+    changes = changes - ARRAY['first_name', 'last_name', 'email']
+WHERE entity_type = 'guest'
+  AND (before IS NOT NULL OR after IS NOT NULL OR changes IS NOT NULL);
+
+ALTER TABLE audit_log ENABLE TRIGGER audit_log_no_update;
@@ -0,0 +1,13 @@
+-- Liens de réponse aux questionnaires, envoyés par email aux membres
+-- présents : la réponse est rattachée au membre du lien et non à un
+-- member_id fourni par le client. Seul le condensat du jeton est conservé.
+CREATE TABLE IF NOT EXISTS survey_invitations (
+    id         SERIAL PRIMARY KEY,
+    survey_id  INTEGER NOT NULL REFERENCES surveys(id) ON DELETE CASCADE,
+    member_id  INTEGER NOT NULL REFERENCES members(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS survey_invitations_member_idx ON survey_invitations (survey_id, member_id);
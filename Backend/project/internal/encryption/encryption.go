@@ -0,0 +1,217 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks values produced by Encrypt; anything else is legacy plaintext
+const prefix = "enc:v1:"
+
+var ErrUnknownKey = errors.New("clé de chiffrement inconnue")
+
+// Keyring performs envelope encryption: each value is sealed with its own
+// random data key, which is in turn sealed with a master key identified by
+// its ID. Rotating keys only requires rewrapping the data keys.
+type Keyring struct {
+	keys      map[string][]byte
+	currentID string
+	indexKey  []byte
+}
+
+// NewKeyring parses master keys given as "id:base64key" (32-byte keys) and
+// the blind index key (base64, at least 32 bytes)
+func NewKeyring(keys []string, currentID, indexKey string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte), currentID: currentID}
+
+	for _, entry := range keys {
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("clé %q: format attendu id:base64", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("clé %q: 32 octets encodés en base64 attendus", id)
+		}
+		k.keys[id] = key
+	}
+
+	if _, ok := k.keys[currentID]; !ok {
+		return nil, fmt.Errorf("clé courante %q absente du trousseau", currentID)
+	}
+
+	index, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil || len(index) < 32 {
+		return nil, errors.New("clé d'index aveugle: au moins 32 octets encodés en base64 attendus")
+	}
+	k.indexKey = index
+
+	return k, nil
+}
+
+// Encrypt seals plaintext under the current master key. Empty strings are
+// stored as is.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	sealedValue, err := seal(dataKey, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	sealedKey, err := seal(k.keys[k.currentID], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.currentID + ":" + encode(sealedKey) + ":" + encode(sealedValue), nil
+}
+
+// Decrypt opens a value produced by Encrypt. Legacy plaintext values are
+// returned unchanged so rows can be migrated lazily.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, prefix) {
+		return value, nil
+	}
+
+	keyID, sealedKey, sealedValue, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	master, ok := k.keys[keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	dataKey, err := open(master, sealedKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dataKey, sealedValue)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// NeedsRewrap reports whether a stored value is plaintext or sealed under a
+// master key other than the current one
+func (k *Keyring) NeedsRewrap(value string) bool {
+	if value == "" {
+		return false
+	}
+	if !strings.HasPrefix(value, prefix) {
+		return true
+	}
+	keyID, _, _, err := parse(value)
+	return err == nil && keyID != k.currentID
+}
+
+// Rewrap re-seals the data key of a value under the current master key,
+// encrypting legacy plaintext on the way
+func (k *Keyring) Rewrap(value string) (string, error) {
+	if !strings.HasPrefix(value, prefix) {
+		return k.Encrypt(value)
+	}
+
+	keyID, sealedKey, sealedValue, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	master, ok := k.keys[keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	dataKey, err := open(master, sealedKey)
+	if err != nil {
+		return "", err
+	}
+	resealed, err := seal(k.keys[k.currentID], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return prefix + k.currentID + ":" + encode(resealed) + ":" + encode(sealedValue), nil
+}
+
+// BlindIndex returns a keyed hash of the normalized value, allowing exact
+// lookups without decrypting. Empty values have no index.
+func (k *Keyring) BlindIndex(value string) *string {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "" {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	index := hex.EncodeToString(mac.Sum(nil))
+	return &index
+}
+
+func parse(value string) (keyID string, sealedKey, sealedValue []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("valeur chiffrée malformée")
+	}
+	if sealedKey, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, err
+	}
+	if sealedValue, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, err
+	}
+	return parts[0], sealedKey, sealedValue, nil
+}
+
+func encode(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+// seal encrypts with AES-256-GCM, prepending the nonce
+func seal(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("valeur chiffrée tronquée")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/middleware"
 	"beautiful-minds/backend/project/internal/models"
 	"beautiful-minds/backend/project/internal/repository"
 
@@ -12,17 +16,74 @@ import (
 )
 
 type AnnouncementHandler struct {
-	repo *repository.AnnouncementRepository
+	repo      *repository.AnnouncementRepository
+	audit     *repository.AuditRepository
+	revisions *repository.RevisionRepository
 }
 
-func NewAnnouncementHandler(repo *repository.AnnouncementRepository) *AnnouncementHandler {
-	return &AnnouncementHandler{repo: repo}
+func NewAnnouncementHandler(repo *repository.AnnouncementRepository, audit *repository.AuditRepository,
+	revisions *repository.RevisionRepository) *AnnouncementHandler {
+	return &AnnouncementHandler{repo: repo, audit: audit, revisions: revisions}
 }
 
+// audienceMember reads the optional ?member_id= of the member asking for
+// announcements, 0 when absent. Members have no credentials, so the ID is
+// taken on trust: it only narrows listings to what concerns the member and
+// is no access boundary. Targeted announcements are therefore readable by
+// anyone who names a member of their audience and must not hold anything
+// confidential.
+func audienceMember(w http.ResponseWriter, r *http.Request) (int, bool) {
+	value := r.URL.Query().Get("member_id")
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id <= 0 {
+		http.Error(w, "member_id invalide", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// isRejectedAnnouncement answers the write errors caused by the request
+func isRejectedAnnouncement(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, repository.ErrUnknownAudienceEvent) {
+		metrics.RecordValidationFailure("announcement", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return true
+	}
+	return false
+}
+
+// GetAll lists the published announcements meant for everyone, plus those
+// whose audience includes the member given by ?member_id=. Admins list the
+// other ones, whatever their audience, with ?status=draft, scheduled,
+// expired or all.
 func (h *AnnouncementHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	announcements, err := h.repo.GetAll()
+	memberID, ok := audienceMember(w, r)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && !models.ValidAnnouncementStatus(status) {
+		http.Error(w, "Statut invalide (draft, scheduled, published, expired ou all)", http.StatusBadRequest)
+		return
+	}
+	if status != "" && !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Filtre par statut réservé aux administrateurs", http.StatusForbidden)
+		return
+	}
+
+	var announcements []models.Announcement
+	var err error
+	if status == "" {
+		announcements, err = h.repo.GetAll(memberID)
+	} else {
+		announcements, err = h.repo.GetByStatus(status)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
@@ -38,12 +99,35 @@ func (h *AnnouncementHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	memberID, ok := audienceMember(w, r)
+	if !ok {
+		return
+	}
+
 	announcement, err := h.repo.GetByID(id)
-	if err != nil {
+	// Brouillons, annonces programmées ou expirées : visibles des seuls administrateurs
+	if err != nil || (!announcement.IsPublic() && !middleware.HasRole(r, middleware.RoleAdmin)) {
 		http.Error(w, "Annonce non trouvée", http.StatusNotFound)
 		return
 	}
 
+	// Annonce ciblée : visible de son public (?member_id=) et des administrateurs
+	if !announcement.Audience.IsEveryone() && !middleware.HasRole(r, middleware.RoleAdmin) {
+		in, err := h.repo.InAudience(id, memberID)
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+		if !in {
+			http.Error(w, "Annonce non trouvée", http.StatusNotFound)
+			return
+		}
+	}
+
+	if writeETag(w, r, announcement.Version) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(announcement)
 }
@@ -51,16 +135,29 @@ func (h *AnnouncementHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 func (h *AnnouncementHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateAnnouncementRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("announcement", "decode")
 		http.Error(w, "Données invalides", http.StatusBadRequest)
 		return
 	}
 
-	announcement, err := h.repo.Create(&req)
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("announcement", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	announcement, err := h.repo.Create(&req, actor(r))
+	if isRejectedAnnouncement(w, err) {
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	recordAudit(h.audit, r, "create", "announcement", announcement.ID, nil, announcement)
+
+	w.Header().Set("ETag", etag(announcement.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(announcement)
@@ -74,18 +171,42 @@ func (h *AnnouncementHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expected, ok := requiredVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var req models.CreateAnnouncementRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("announcement", "decode")
 		http.Error(w, "Données invalides", http.StatusBadRequest)
 		return
 	}
 
-	announcement, err := h.repo.Update(id, &req)
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("announcement", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Annonce non trouvée", http.StatusNotFound)
+		return
+	}
+
+	announcement, err := h.repo.Update(id, &req, expected, actor(r))
+	if isVersionConflict(w, err) || isRejectedAnnouncement(w, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, "Annonce non trouvée", http.StatusNotFound)
 		return
 	}
 
+	recordAudit(h.audit, r, "update", "announcement", id, before, announcement)
+
+	w.Header().Set("ETag", etag(announcement.Version))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(announcement)
 }
@@ -98,13 +219,204 @@ func (h *AnnouncementHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.repo.Delete(id)
+	expected, ok := requiredVersion(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Annonce non trouvée", http.StatusNotFound)
+		return
+	}
+
+	err = h.repo.Delete(id, expected, actor(r))
+	if isVersionConflict(w, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, "Annonce non trouvée", http.StatusNotFound)
 		return
 	}
 
+	recordAudit(h.audit, r, "delete", "announcement", id, before, nil)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Annonce supprimée"})
 }
+
+// Patch applies a JSON Merge Patch to an announcement; If-Match is required
+func (h *AnnouncementHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, expected, patch, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Annonce non trouvée", http.StatusNotFound)
+		return
+	}
+
+	var req models.CreateAnnouncementRequest
+	if !decodeMergePatch(w, "announcement", current, current.Version, expected, patch, &req) {
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("announcement", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	announcement, err := h.repo.Update(id, &req, expected, actor(r))
+	if isVersionConflict(w, err) || isRejectedAnnouncement(w, err) {
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "patch", "announcement", id, current, announcement)
+
+	w.Header().Set("ETag", etag(announcement.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(announcement)
+}
+
+// Revisions lists the recorded versions of an announcement, latest first.
+// Revisions may hold drafts, so they are reserved to admins.
+func (h *AnnouncementHandler) Revisions(w http.ResponseWriter, r *http.Request) {
+	announcement, ok := h.revisionAnnouncement(w, r)
+	if !ok {
+		return
+	}
+	listRevisions(w, r, h.revisions, "announcement", announcement.ID)
+}
+
+// Revision returns a version of an announcement with its snapshot
+func (h *AnnouncementHandler) Revision(w http.ResponseWriter, r *http.Request) {
+	announcement, ok := h.revisionAnnouncement(w, r)
+	if !ok {
+		return
+	}
+	writeRevision(w, r, h.revisions, "announcement", announcement.ID)
+}
+
+// RevisionDiff compares two versions of an announcement (?from= and ?to=)
+func (h *AnnouncementHandler) RevisionDiff(w http.ResponseWriter, r *http.Request) {
+	announcement, ok := h.revisionAnnouncement(w, r)
+	if !ok {
+		return
+	}
+	diffRevisions(w, r, h.revisions, "announcement", announcement.ID)
+}
+
+// Rollback puts an announcement back in the state of one of its revisions,
+// publication date included, which is recorded as a new version. Without
+// If-Match the current version is expected.
+func (h *AnnouncementHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	expected, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, ok := h.revisionAnnouncement(w, r)
+	if !ok {
+		return
+	}
+	version, ok := revisionVersion(w, r)
+	if !ok {
+		return
+	}
+	rev, ok := getRevision(w, r, h.revisions, "announcement", current.ID, version)
+	if !ok {
+		return
+	}
+
+	var snapshot models.Announcement
+	if err := json.Unmarshal(rev.Snapshot, &snapshot); err != nil {
+		serverError(w, r, err)
+		return
+	}
+	req := snapshot.AsRequest()
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("announcement", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if expected == 0 {
+		expected = current.Version
+	}
+	announcement, err := h.repo.Update(current.ID, &req, expected, actor(r))
+	if isVersionConflict(w, err) || isRejectedAnnouncement(w, err) {
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "rollback", "announcement", announcement.ID, current, announcement)
+
+	w.Header().Set("ETag", etag(announcement.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(announcement)
+}
+
+// revisionAnnouncement checks the caller is an admin and reads the
+// announcement whose revisions are asked for
+func (h *AnnouncementHandler) revisionAnnouncement(w http.ResponseWriter, r *http.Request) (*models.Announcement, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Historique des annonces réservé aux administrateurs", http.StatusForbidden)
+		return nil, false
+	}
+
+	announcement, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Annonce non trouvée", http.StatusNotFound)
+		return nil, false
+	}
+	return announcement, true
+}
+
+// Restore takes an announcement out of the trash; admins only
+func (h *AnnouncementHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Corbeille réservée aux administrateurs", http.StatusForbidden)
+		return
+	}
+
+	announcement, err := h.repo.Restore(id, actor(r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Annonce non trouvée dans la corbeille", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "restore", "announcement", id, nil, announcement)
+
+	w.Header().Set("ETag", etag(announcement.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(announcement)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"beautiful-minds/backend/project/internal/logging"
+	"beautiful-minds/backend/project/internal/middleware"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+)
+
+// Fields that change on every write and would only add noise to diffs
+var auditIgnoredFields = map[string]bool{"updated_at": true, "version": true}
+
+type AuditHandler struct {
+	repo *repository.AuditRepository
+}
+
+func NewAuditHandler(repo *repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{repo: repo}
+}
+
+// List returns audit entries filtered by actor, action, entity_type,
+// entity_id, from and to (RFC 3339), paginated with limit and offset;
+// admins only, entries holding IPs and full snapshots
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Journal d'audit réservé aux administrateurs", http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := models.AuditFilter{
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		EntityType: q.Get("entity_type"),
+		Limit:      100,
+	}
+
+	var err error
+	if v := q.Get("entity_id"); v != "" {
+		if filter.EntityID, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "entity_id invalide", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit < 1 || filter.Limit > 500 {
+			http.Error(w, "limit doit être compris entre 1 et 500", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if filter.Offset, err = strconv.Atoi(v); err != nil || filter.Offset < 0 {
+			http.Error(w, "offset invalide", http.StatusBadRequest)
+			return
+		}
+	}
+	for name, dest := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, name+" doit être une date RFC 3339", http.StatusBadRequest)
+				return
+			}
+			*dest = &t
+		}
+	}
+
+	entries, err := h.repo.List(filter)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// recordAudit stores an audit entry for a mutating call. The change has
+// already been applied, so a failure is logged rather than returned.
+func recordAudit(audit *repository.AuditRepository, r *http.Request, action, entityType string, entityID int, before, after any) {
+	entry := &models.AuditEntry{
+		Actor:      actor(r),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     snapshot(before),
+		After:      snapshot(after),
+		IP:         middleware.ClientIP(r),
+		RequestID:  logging.RequestID(r.Context()),
+	}
+	if entry.Before != nil && entry.After != nil {
+		entry.Changes = diffJSON(entry.Before, entry.After)
+	}
+
+	if err := audit.Create(entry); err != nil {
+		logging.FromContext(r.Context()).Error("écriture du journal d'audit échouée",
+			"error", err, "action", action, "entity_type", entityType, "entity_id", entityID)
+	}
+}
+
+// actor identifies who made a change by the role authenticated by the Roles
+// middleware. A header naming the caller would be forgeable by anyone, so
+// individual administrators are told apart only once they have their own
+// credentials.
+func actor(r *http.Request) string {
+	return string(middleware.RoleFrom(r.Context()))
+}
+
+func toJSON(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil || bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	return b
+}
+
+// snapshot encodes an entity for the audit log, nil when it cannot be
+func snapshot(v any) json.RawMessage {
+	b, err := models.Snapshot(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// diffJSON returns {"field": {"before": x, "after": y}} for every top-level
+// field that differs between the two documents
+func diffJSON(before, after json.RawMessage) json.RawMessage {
+	var b, a map[string]any
+	json.Unmarshal(before, &b)
+	json.Unmarshal(after, &a)
+
+	changes := make(map[string]map[string]any)
+	for key, value := range a {
+		if !auditIgnoredFields[key] && !reflect.DeepEqual(b[key], value) {
+			changes[key] = map[string]any{"before": b[key], "after": value}
+		}
+	}
+	for key, value := range b {
+		if _, ok := a[key]; !ok && !auditIgnoredFields[key] {
+			changes[key] = map[string]any{"before": value, "after": nil}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return toJSON(changes)
+}
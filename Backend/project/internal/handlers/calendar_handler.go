@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"beautiful-minds/backend/project/internal/ical"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// CalendarHandler publishes the events as iCalendar feeds that calendar
+// applications can subscribe to
+type CalendarHandler struct {
+	events      *repository.EventRepository
+	occurrences *repository.OccurrenceRepository
+	// host qualifies the event UIDs so they stay unique across calendars
+	host string
+}
+
+func NewCalendarHandler(events *repository.EventRepository, occurrences *repository.OccurrenceRepository, publicURL string) *CalendarHandler {
+	host := "localhost"
+	if u, err := url.Parse(publicURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	return &CalendarHandler{events: events, occurrences: occurrences, host: host}
+}
+
+// Feed serves the upcoming events, filtered like the JSON listing by
+// ?category= and ?tag=
+func (h *CalendarHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	filter := models.EventFilter{
+		Category: r.URL.Query().Get("category"),
+		Tag:      models.NormalizeTag(r.URL.Query().Get("tag")),
+	}
+
+	events, err := h.events.GetAll(filter)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	h.write(w, r, "Beautiful Minds", events)
+}
+
+// Event serves a single event, with all the occurrences of a series
+func (h *CalendarHandler) Event(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.events.GetByID(id)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	h.write(w, r, event.Title, []models.Event{*event})
+}
+
+func (h *CalendarHandler) write(w http.ResponseWriter, r *http.Request, name string, events []models.Event) {
+	var series []int
+	for _, e := range events {
+		if e.RecurrenceRule != nil {
+			series = append(series, e.ID)
+		}
+	}
+	overrides, err := h.occurrences.GetByEvents(series)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	calendar := ical.Calendar{Name: name}
+	for _, e := range events {
+		calendar.Events = append(calendar.Events, h.vevents(e, overrides[e.ID])...)
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+	calendar.WriteTo(w)
+}
+
+// vevents converts an event into its VEVENT. A series carries its rule with
+// the exception dates and cancelled occurrences as EXDATEs; each modified
+// occurrence gets its own VEVENT identified by RECURRENCE-ID.
+func (h *CalendarHandler) vevents(e models.Event, overrides map[int64]models.OccurrenceOverride) []ical.Event {
+	end := e.EndDate
+	main := ical.Event{
+		UID:         ical.UID(e.ID, h.host),
+		Summary:     e.Title,
+		Description: e.Description,
+		Location:    e.Location,
+		Start:       e.Date,
+		End:         &end,
+		Updated:     e.UpdatedAt,
+		Zone:        e.Zone(),
+	}
+	if e.RecurrenceRule == nil {
+		return []ical.Event{main}
+	}
+
+	main.Rule = *e.RecurrenceRule
+	main.ExDates = append(main.ExDates, e.ExceptionDates...)
+
+	starts := make([]int64, 0, len(overrides))
+	for start := range overrides {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	vevents := []ical.Event{main}
+	for _, start := range starts {
+		override := overrides[start]
+		if !e.HasOccurrence(override.OccurrenceStart) {
+			continue
+		}
+		if override.Cancelled {
+			vevents[0].ExDates = append(vevents[0].ExDates, override.OccurrenceStart)
+			continue
+		}
+
+		o := e.OccurrenceAt(override.OccurrenceStart, &override)
+		recurrenceID := o.OccurrenceStart
+		occurrenceEnd := o.EndDate
+		vevents = append(vevents, ical.Event{
+			UID:          main.UID,
+			Summary:      o.Title,
+			Description:  o.Description,
+			Location:     o.Location,
+			Start:        o.Date,
+			End:          &occurrenceEnd,
+			RecurrenceID: &recurrenceID,
+			Updated:      override.UpdatedAt,
+			Zone:         main.Zone,
+		})
+	}
+
+	return vevents
+}
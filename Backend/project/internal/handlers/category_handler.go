@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+type CategoryHandler struct {
+	repo  *repository.CategoryRepository
+	audit *repository.AuditRepository
+}
+
+func NewCategoryHandler(repo *repository.CategoryRepository, audit *repository.AuditRepository) *CategoryHandler {
+	return &CategoryHandler{repo: repo, audit: audit}
+}
+
+// GetAll lists the categories with their upcoming event counts, restricted
+// to the events carrying ?tag= when given
+func (h *CategoryHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.repo.GetAll(models.NormalizeTag(r.URL.Query().Get("tag")))
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+func (h *CategoryHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("category", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("category", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.repo.Create(&req)
+	if err != nil {
+		if isDuplicate(err) {
+			http.Error(w, "Une catégorie porte déjà ce nom", http.StatusConflict)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "create", "category", category.ID, nil, category)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CreateCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("category", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("category", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+
+	category, err := h.repo.Update(id, &req)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		if isDuplicate(err) {
+			http.Error(w, "Une catégorie porte déjà ce nom", http.StatusConflict)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "update", "category", id, before, category)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(category)
+}
+
+// Delete removes a category; the events in it are kept
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+
+	if err := h.repo.Delete(id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "delete", "category", id, before, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Catégorie supprimée"})
+}
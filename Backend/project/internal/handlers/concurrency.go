@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// MergePatchContentType is the media type of JSON Merge Patch (RFC 7386)
+const MergePatchContentType = "application/merge-patch+json"
+
+var (
+	errInvalidPrecondition  = errors.New("en-tête If-Match invalide")
+	errPreconditionRequired = errors.New("en-tête If-Match obligatoire : renvoyez l'ETag de la ressource")
+)
+
+// etag formats a row version as a strong entity tag
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// writeETag sets the ETag header and answers 304 when it matches If-None-Match
+func writeETag(w http.ResponseWriter, r *http.Request, version int) (notModified bool) {
+	tag := etag(version)
+	w.Header().Set("ETag", tag)
+
+	if r.Method == http.MethodGet && ifNoneMatch(r, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// ifNoneMatch reports whether If-None-Match lists tag
+func ifNoneMatch(r *http.Request, tag string) bool {
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == tag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedVersion reads the version required by If-Match; 0 means none
+func expectedVersion(r *http.Request) (int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	value = strings.TrimPrefix(value, "W/")
+	if len(value) < 3 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, errInvalidPrecondition
+	}
+
+	version, err := strconv.Atoi(value[1 : len(value)-1])
+	if err != nil || version <= 0 {
+		return 0, errInvalidPrecondition
+	}
+	return version, nil
+}
+
+// requiredVersion reads the version required by If-Match for a write that
+// must not overwrite a concurrent one: it answers 428 when If-Match is
+// missing or "*" and 400 when it is malformed
+func requiredVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	version, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	if version == 0 {
+		http.Error(w, errPreconditionRequired.Error(), http.StatusPreconditionRequired)
+		return 0, false
+	}
+	return version, true
+}
+
+// isVersionConflict writes a 412 when err is a version conflict
+func isVersionConflict(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, repository.ErrVersionConflict) {
+		http.Error(w, "La ressource a été modifiée entre-temps, rechargez-la", http.StatusPreconditionFailed)
+		return true
+	}
+	return false
+}
+
+// isMergePatch reports whether the request body is a JSON merge patch
+func isMergePatch(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && (mediaType == MergePatchContentType || mediaType == "application/json")
+}
+
+// readMergePatch reads a PATCH request: the {id} path variable, the version
+// required by If-Match and the merge patch body. It answers the request
+// itself when it returns false.
+func readMergePatch(w http.ResponseWriter, r *http.Request) (id, expected int, patch []byte, ok bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return 0, 0, nil, false
+	}
+
+	if !isMergePatch(r) {
+		http.Error(w, "Content-Type attendu: "+MergePatchContentType, http.StatusUnsupportedMediaType)
+		return 0, 0, nil, false
+	}
+
+	if expected, ok = requiredVersion(w, r); !ok {
+		return 0, 0, nil, false
+	}
+
+	if patch, err = io.ReadAll(r.Body); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return 0, 0, nil, false
+	}
+	return id, expected, patch, true
+}
+
+// decodeMergePatch applies patch to current, whose version must be the
+// expected one, and decodes the result into req. entity labels the
+// validation failure metric. It answers the request itself when it
+// returns false.
+func decodeMergePatch(w http.ResponseWriter, entity string, current any, version, expected int, patch []byte, req any) bool {
+	if version != expected {
+		isVersionConflict(w, repository.ErrVersionConflict)
+		return false
+	}
+
+	if err := applyMergePatch(current, patch, req); err != nil {
+		metrics.RecordValidationFailure(entity, "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// applyMergePatch applies an RFC 7386 merge patch to the JSON form of
+// current and decodes the result into out
+func applyMergePatch(current any, patch []byte, out any) error {
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	var target, changes any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return err
+	}
+	if _, ok := changes.(map[string]any); !ok {
+		return errors.New("le patch doit être un objet JSON")
+	}
+
+	merged, err := json.Marshal(mergeValue(target, changes))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(merged, out)
+}
+
+func mergeValue(target, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	result, ok := target.(map[string]any)
+	if !ok {
+		result = make(map[string]any)
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(result, key)
+		} else {
+			result[key] = mergeValue(result[key], value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"beautiful-minds/backend/project/internal/logging"
+
+	"github.com/lib/pq"
+)
+
+// serverError logs the error with the request ID and answers with a 500.
+// The error itself stays in the logs: it may hold SQL, driver or file
+// system details; the request ID lets support find it.
+func serverError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error("erreur interne", "error", err)
+
+	message := "Erreur interne"
+	if id := logging.RequestID(r.Context()); id != "" {
+		message += " (requête " + id + ")"
+	}
+	http.Error(w, message, http.StatusInternalServerError)
+}
+
+// isDuplicate reports whether err is a unique constraint violation
+// (SQLSTATE 23505)
+func isDuplicate(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -1,10 +1,15 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/middleware"
 	"beautiful-minds/backend/project/internal/models"
 	"beautiful-minds/backend/project/internal/repository"
 
@@ -12,20 +17,55 @@ import (
 )
 
 type EventHandler struct {
-	repo *repository.EventRepository
+	repo        *repository.EventRepository
+	occurrences *repository.OccurrenceRepository
+	audit       *repository.AuditRepository
+	revisions   *repository.RevisionRepository
+	// horizon is how far ahead recurring events are expanded in listings
+	horizon  time.Duration
+	defaults models.EventDefaults
 }
 
-func NewEventHandler(repo *repository.EventRepository) *EventHandler {
-	return &EventHandler{repo: repo}
+func NewEventHandler(repo *repository.EventRepository, occurrences *repository.OccurrenceRepository,
+	audit *repository.AuditRepository, revisions *repository.RevisionRepository,
+	horizon time.Duration, defaults models.EventDefaults) *EventHandler {
+	return &EventHandler{repo: repo, occurrences: occurrences, audit: audit, revisions: revisions,
+		horizon: horizon, defaults: defaults}
 }
 
+// GetAll lists the upcoming events, filtered by ?category= (slug) and ?tag=.
+// Recurring events are replaced by their occurrences within the horizon
+// unless ?expand=false, which lists the series themselves.
 func (h *EventHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	events, err := h.repo.GetAll()
+	filter := models.EventFilter{
+		Category: r.URL.Query().Get("category"),
+		Tag:      models.NormalizeTag(r.URL.Query().Get("tag")),
+		Past:     r.URL.Query().Get("past") == "true",
+	}
+
+	events, err := h.repo.GetAll(filter)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	// Les séries passées sont listées sans leurs occurrences
+	if !filter.Past && r.URL.Query().Get("expand") != "false" {
+		var series []int
+		for _, e := range events {
+			if e.RecurrenceRule != nil {
+				series = append(series, e.ID)
+			}
+		}
+		overrides, err := h.occurrences.GetByEvents(series)
+		if err != nil {
+			serverError(w, r, err)
+			return
+		}
+		now := time.Now()
+		events = models.ExpandEvents(events, now, now.Add(h.horizon), overrides)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(events)
 }
@@ -44,6 +84,10 @@ func (h *EventHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if writeETag(w, r, event.Version) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(event)
 }
@@ -51,16 +95,30 @@ func (h *EventHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 func (h *EventHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateEventRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("event", "decode")
 		http.Error(w, "Données invalides", http.StatusBadRequest)
 		return
 	}
 
-	event, err := h.repo.Create(&req)
+	if err := req.Validate(h.defaults); err != nil {
+		metrics.RecordValidationFailure("event", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.repo.Create(&req, actor(r))
+	if isRejectedEvent(w, err) {
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	recordAudit(h.audit, r, "create", "event", event.ID, nil, event)
+	addEventWarnings(event)
+
+	w.Header().Set("ETag", etag(event.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(event)
@@ -76,21 +134,90 @@ func (h *EventHandler) RegisterMember(w http.ResponseWriter, r *http.Request) {
 
 	var req models.RegisterEventRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("event", "decode")
 		http.Error(w, "Données invalides", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.repo.RegisterMember(eventID, req.MemberID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	event, err := h.repo.GetByID(eventID)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	var ok bool
+	req.OccurrenceStart, ok = registrationOccurrence(w, r, h.occurrences, event, req.OccurrenceStart)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.RegisterMember(eventID, req.MemberID, req.OccurrenceStart); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Événement ou membre non trouvé", http.StatusNotFound)
+			return
+		}
+		if isDuplicate(err) {
+			http.Error(w, "Membre déjà inscrit", http.StatusConflict)
+			return
+		}
+		serverError(w, r, err)
 		return
 	}
 
+	recordAudit(h.audit, r, "register", "event", eventID, nil, req)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
 		"message": "Inscription réussie",
 	})
 }
 
+// Attendance marks the registered members who attended the event, or an
+// occurrence of a series; only they can answer its feedback survey
+func (h *EventHandler) Attendance(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	var req models.AttendanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("attendance", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("attendance", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.repo.GetByID(eventID)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	var ok bool
+	req.OccurrenceStart, ok = registrationOccurrence(w, r, h.occurrences, event, req.OccurrenceStart)
+	if !ok {
+		return
+	}
+
+	updated, err := h.repo.SetAttendance(eventID, req.OccurrenceStart, req.MemberIDs, *req.Attended)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "attendance", "event", eventID, nil, req)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"updated": updated})
+}
+
 func (h *EventHandler) Update(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["id"])
@@ -99,18 +226,46 @@ func (h *EventHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expected, ok := requiredVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var req models.CreateEventRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("event", "decode")
 		http.Error(w, "Données invalides", http.StatusBadRequest)
 		return
 	}
 
-	event, err := h.repo.Update(id, &req)
+	if err := req.Validate(h.defaults); err != nil {
+		metrics.RecordValidationFailure("event", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	event, err := h.repo.Update(id, &req, expected, actor(r))
+	if isVersionConflict(w, err) {
+		return
+	}
+	if isRejectedEvent(w, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, "Événement non trouvé", http.StatusNotFound)
 		return
 	}
 
+	recordAudit(h.audit, r, "update", "event", id, before, event)
+	addEventWarnings(event)
+
+	w.Header().Set("ETag", etag(event.Version))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(event)
 }
@@ -123,13 +278,300 @@ func (h *EventHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.repo.Delete(id)
+	expected, ok := requiredVersion(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
 	if err != nil {
 		http.Error(w, "Événement non trouvé", http.StatusNotFound)
 		return
 	}
 
+	err = h.repo.Delete(id, expected, actor(r))
+	if isVersionConflict(w, err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	recordAudit(h.audit, r, "delete", "event", id, before, nil)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Événement supprimé"})
 }
+
+// Patch applies a JSON Merge Patch to an event; If-Match is required
+func (h *EventHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, expected, patch, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	var req models.CreateEventRequest
+	if !decodeMergePatch(w, "event", current, current.Version, expected, patch, &req) {
+		return
+	}
+
+	if err := req.Validate(h.defaults); err != nil {
+		metrics.RecordValidationFailure("event", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.repo.Update(id, &req, expected, actor(r))
+	if isVersionConflict(w, err) {
+		return
+	}
+	if isRejectedEvent(w, err) {
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "patch", "event", id, current, event)
+	addEventWarnings(event)
+
+	w.Header().Set("ETag", etag(event.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// Revisions lists the recorded versions of an event, latest first
+func (h *EventHandler) Revisions(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.revisionEvent(w, r)
+	if !ok {
+		return
+	}
+	listRevisions(w, r, h.revisions, "event", event.ID)
+}
+
+// Revision returns a version of an event with its snapshot
+func (h *EventHandler) Revision(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.revisionEvent(w, r)
+	if !ok {
+		return
+	}
+	writeRevision(w, r, h.revisions, "event", event.ID)
+}
+
+// RevisionDiff compares two versions of an event (?from= and ?to=)
+func (h *EventHandler) RevisionDiff(w http.ResponseWriter, r *http.Request) {
+	event, ok := h.revisionEvent(w, r)
+	if !ok {
+		return
+	}
+	diffRevisions(w, r, h.revisions, "event", event.ID)
+}
+
+// Rollback puts an event back in the state of one of its revisions, which
+// is recorded as a new version. Without If-Match the current version is
+// expected.
+func (h *EventHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	expected, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, ok := h.revisionEvent(w, r)
+	if !ok {
+		return
+	}
+	version, ok := revisionVersion(w, r)
+	if !ok {
+		return
+	}
+	rev, ok := getRevision(w, r, h.revisions, "event", current.ID, version)
+	if !ok {
+		return
+	}
+
+	var snapshot models.Event
+	if err := json.Unmarshal(rev.Snapshot, &snapshot); err != nil {
+		serverError(w, r, err)
+		return
+	}
+	req := snapshot.AsRequest()
+	// L'état restauré repasse la validation, les règles ayant pu changer depuis
+	if err := req.Validate(h.defaults); err != nil {
+		metrics.RecordValidationFailure("event", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if expected == 0 {
+		expected = current.Version
+	}
+	event, err := h.repo.Update(current.ID, &req, expected, actor(r))
+	if isVersionConflict(w, err) {
+		return
+	}
+	if isRejectedEvent(w, err) {
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "rollback", "event", event.ID, current, event)
+	addEventWarnings(event)
+
+	w.Header().Set("ETag", etag(event.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// revisionEvent checks the caller is an admin and reads the event whose
+// revisions are asked for
+func (h *EventHandler) revisionEvent(w http.ResponseWriter, r *http.Request) (*models.Event, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Historique des événements réservé aux administrateurs", http.StatusForbidden)
+		return nil, false
+	}
+
+	event, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return nil, false
+	}
+	return event, true
+}
+
+// Restore takes an event out of the trash; admins only
+func (h *EventHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Corbeille réservée aux administrateurs", http.StatusForbidden)
+		return
+	}
+
+	event, err := h.repo.Restore(id, actor(r))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Événement non trouvé dans la corbeille", http.StatusNotFound)
+		return
+	}
+	if isRejectedEvent(w, err) {
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "restore", "event", id, nil, event)
+
+	w.Header().Set("ETag", etag(event.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// Tags lists the tags of upcoming events with their counts, optionally within
+// a category (?category= slug)
+func (h *EventHandler) Tags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.repo.GetTags(r.URL.Query().Get("category"))
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// registrationOccurrence checks the occurrence a registration targets:
+// required for recurring events, where it must exist and not be cancelled,
+// and refused for single events. It returns the start normalized to UTC.
+func registrationOccurrence(w http.ResponseWriter, r *http.Request, occurrences *repository.OccurrenceRepository,
+	event *models.Event, start *time.Time) (*time.Time, bool) {
+	// Les inscriptions aux événements récurrents portent sur une occurrence
+	if event.RecurrenceRule == nil {
+		if start != nil {
+			http.Error(w, "occurrence_start réservé aux événements récurrents", http.StatusBadRequest)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	if start == nil {
+		http.Error(w, "occurrence_start est obligatoire pour un événement récurrent", http.StatusBadRequest)
+		return nil, false
+	}
+	utc := start.UTC()
+	if !event.HasOccurrence(utc) {
+		http.Error(w, "Occurrence non trouvée", http.StatusNotFound)
+		return nil, false
+	}
+	override, err := occurrences.Get(event.ID, utc)
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, err)
+		return nil, false
+	}
+	if override != nil && override.Cancelled {
+		http.Error(w, "Cette occurrence est annulée", http.StatusConflict)
+		return nil, false
+	}
+	return &utc, true
+}
+
+// isRejectedEvent answers the write errors caused by the request itself:
+// unknown categories or venue, and bookings overlapping another event or
+// session of the venue, listed in the 409 body
+func isRejectedEvent(w http.ResponseWriter, err error) bool {
+	switch {
+	case isBookingConflict(w, err):
+		return true
+	case errors.Is(err, repository.ErrUnknownCategory), errors.Is(err, repository.ErrUnknownVenue):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return true
+	}
+	return false
+}
+
+// isBookingConflict answers 409 with the conflicting bookings when the venue
+// is already taken
+func isBookingConflict(w http.ResponseWriter, err error) bool {
+	var conflict *repository.BookingConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":     conflict.Error(),
+		"conflicts": conflict.Conflicts,
+	})
+	return true
+}
+
+// addEventWarnings flags the accepted values worth a second look
+func addEventWarnings(e *models.Event) {
+	if warning := e.CapacityWarning(); warning != "" {
+		e.Warnings = append(e.Warnings, warning)
+	}
+}
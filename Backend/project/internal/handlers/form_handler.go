@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"beautiful-minds/backend/project/internal/antispam"
+)
+
+type FormHandler struct {
+	guard *antispam.Guard
+}
+
+func NewFormHandler(guard *antispam.Guard) *FormHandler {
+	return &FormHandler{guard: guard}
+}
+
+type formTokenResponse struct {
+	Token     string    `json:"token"`
+	Header    string    `json:"header"`
+	Honeypot  string    `json:"honeypot"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Token issues a form token to fetch when the public registration form is
+// displayed and send back in the X-Form-Token header on submission
+func (h *FormHandler) Token(w http.ResponseWriter, r *http.Request) {
+	token, expiresAt, err := h.guard.Issue()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(formTokenResponse{
+		Token:     token,
+		Header:    antispam.TokenHeader,
+		Honeypot:  antispam.HoneypotField,
+		ExpiresAt: expiresAt,
+	})
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"beautiful-minds/backend/project/internal/logging"
+	"beautiful-minds/backend/project/internal/mailer"
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/middleware"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+var guestConfirmationPage = template.Must(template.New("guest").Parse(`<!DOCTYPE html>
+<html lang="fr">
+<head><meta charset="utf-8"><title>Inscription à {{.Title}}</title></head>
+<body>
+  <p>{{.Message}}</p>
+</body>
+</html>
+`))
+
+// GuestHandler serves the registration of non-members to public events and
+// their conversion into members
+type GuestHandler struct {
+	repo        *repository.GuestRepository
+	events      *repository.EventRepository
+	occurrences *repository.OccurrenceRepository
+	members     *repository.MemberRepository
+	audit       *repository.AuditRepository
+	mailer      mailer.Mailer
+	publicURL   string
+	tokenTTL    time.Duration
+}
+
+func NewGuestHandler(
+	repo *repository.GuestRepository,
+	events *repository.EventRepository,
+	occurrences *repository.OccurrenceRepository,
+	members *repository.MemberRepository,
+	audit *repository.AuditRepository,
+	m mailer.Mailer,
+	publicURL string,
+	tokenTTL time.Duration,
+) *GuestHandler {
+	return &GuestHandler{
+		repo:        repo,
+		events:      events,
+		occurrences: occurrences,
+		members:     members,
+		audit:       audit,
+		mailer:      m,
+		publicURL:   strings.TrimSuffix(publicURL, "/"),
+		tokenTTL:    tokenTTL,
+	}
+}
+
+// guestEmailRoles may read the emails of guests
+var guestEmailRoles = []middleware.Role{middleware.RoleAdmin}
+
+// auditGuest redacts the personal data so the audit log never holds it
+func auditGuest(g *models.Guest) any {
+	if g == nil {
+		return nil
+	}
+	return g.Redacted()
+}
+
+// isRejectedGuest answers the registrations refused by the state of the
+// event or of the guest
+func isRejectedGuest(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, repository.ErrGuestsClosed), errors.Is(err, repository.ErrGuestCapacity),
+		errors.Is(err, repository.ErrAlreadyConfirmed):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return true
+	case errors.Is(err, repository.ErrGuestConverted):
+		http.Error(w, err.Error()+", inscrivez-vous en tant que membre", http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+// Register records a guest registration and emails the link confirming it.
+// The seat is only taken once the link is followed.
+func (h *GuestHandler) Register(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	var req models.GuestRegistrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("guest", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("guest", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.events.GetByID(eventID)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	var ok bool
+	req.OccurrenceStart, ok = registrationOccurrence(w, r, h.occurrences, event, req.OccurrenceStart)
+	if !ok {
+		return
+	}
+
+	token, err := newToken()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	expiresAt := time.Now().Add(h.tokenTTL)
+	guest, err := h.repo.CreatePending(eventID, &req, hashToken(token), expiresAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		if isRejectedGuest(w, err) {
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	start := event.Date
+	if req.OccurrenceStart != nil {
+		start = *req.OccurrenceStart
+	}
+	link := fmt.Sprintf("%s/api/v1/guest-registrations/%s/confirm", h.publicURL, token)
+	body := fmt.Sprintf(
+		"Bonjour %s,\n\nPour confirmer votre inscription à « %s » du %s, utilisez ce lien avant le %s :\n\n%s\n\nSi vous n'êtes pas à l'origine de cette demande, ignorez cet email.\n",
+		guest.FirstName, event.Title, start.In(event.Zone()).Format("02/01/2006 15:04"),
+		expiresAt.Format("02/01/2006 15:04"), link,
+	)
+	if err := h.mailer.Send(req.Email, "Confirmez votre inscription", body); err != nil {
+		logging.FromContext(r.Context()).Error("envoi de l'email d'inscription invité échoué", "error", err, "guest_id", guest.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Un email de confirmation a été envoyé",
+	})
+}
+
+// Confirm takes the seat of the registration whose emailed link was
+// followed and answers with a page for the guest
+func (h *GuestHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	page := struct{ Title, Message string }{Title: "un événement"}
+	status := http.StatusOK
+
+	reg, err := h.repo.Confirm(hashToken(mux.Vars(r)["token"]))
+	switch {
+	case err == sql.ErrNoRows:
+		status, page.Message = http.StatusNotFound, "Lien invalide ou expiré."
+	case errors.Is(err, repository.ErrGuestsClosed), errors.Is(err, repository.ErrGuestCapacity):
+		status, page.Message = http.StatusConflict, "Désolé, il n'y a plus de place pour les invités."
+	case err != nil:
+		serverError(w, r, err)
+		return
+	default:
+		page.Title = reg.EventTitle
+		page.Message = "Votre inscription à « " + reg.EventTitle + " » est confirmée. À bientôt !"
+		recordAudit(h.audit, r, "confirm", "guest_registration", reg.ID, nil, reg)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	guestConfirmationPage.Execute(w, page)
+}
+
+// ListByEvent lists the guest registrations of an event, pending included;
+// the emails of the guests are left out unless the caller may read them
+func (h *GuestHandler) ListByEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.events.GetByID(eventID); err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	registrations, err := h.repo.GetByEvent(eventID)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	if !middleware.HasRole(r, guestEmailRoles...) {
+		for i := range registrations {
+			masked := registrations[i].Guest.Masked()
+			registrations[i].Guest = &masked
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(registrations)
+}
+
+// CancelRegistration frees a guest's seat at an event
+func (h *GuestHandler) CancelRegistration(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	eventID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(vars["registrationId"])
+	if err != nil {
+		http.Error(w, "ID d'inscription invalide", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.DeleteRegistration(eventID, id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inscription non trouvée", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "delete", "guest_registration", id, nil, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Inscription annulée"})
+}
+
+// GetAll lists the guests; their emails are left out unless the caller may
+// read them
+func (h *GuestHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	guests, err := h.repo.GetAll()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	if !middleware.HasRole(r, guestEmailRoles...) {
+		for i := range guests {
+			guests[i] = guests[i].Masked()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(guests)
+}
+
+// GetByID returns a guest with its registrations, without its email unless
+// the caller may read it
+func (h *GuestHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	guest, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Invité non trouvé", http.StatusNotFound)
+		return
+	}
+	if !middleware.HasRole(r, guestEmailRoles...) {
+		masked := guest.Masked()
+		guest = &masked
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(guest)
+}
+
+// Delete removes a guest and its registrations
+func (h *GuestHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Invité non trouvé", http.StatusNotFound)
+		return
+	}
+
+	if err := h.repo.Delete(id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invité non trouvé", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "delete", "guest", id, auditGuest(before), nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Invité supprimé"})
+}
+
+// Convert turns a verified guest into a member, either an existing one
+// with the same email (member_id) or one created from the guest's identity.
+// The guest's confirmed registrations move to the member.
+func (h *GuestHandler) Convert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	var req models.ConvertGuestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("guest", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Invité non trouvé", http.StatusNotFound)
+		return
+	}
+	// Vérifié avant de créer le membre pour ne pas le créer en vain
+	switch {
+	case before.MemberID != nil:
+		http.Error(w, repository.ErrGuestConverted.Error(), http.StatusConflict)
+		return
+	case before.EmailVerifiedAt == nil:
+		http.Error(w, repository.ErrGuestNotVerified.Error(), http.StatusConflict)
+		return
+	}
+
+	memberID := 0
+	if req.MemberID != nil {
+		memberID = *req.MemberID
+	} else {
+		memberReq := req.MemberRequest(before)
+		if err := memberReq.Validate(); err != nil {
+			metrics.RecordValidationFailure("guest", "validate")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		member, err := h.members.Create(memberReq)
+		if err != nil {
+			if isDuplicate(err) {
+				http.Error(w, "Un membre utilise déjà cet email ou ce numéro étudiant, indiquez son member_id", http.StatusConflict)
+				return
+			}
+			serverError(w, r, err)
+			return
+		}
+		recordAudit(h.audit, r, "create", "member", member.ID, nil, auditMember(member))
+		memberID = member.ID
+	}
+
+	guest, err := h.repo.Convert(id, memberID)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Invité non trouvé", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrUnknownMember):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, repository.ErrGuestConverted), errors.Is(err, repository.ErrGuestNotVerified),
+		errors.Is(err, repository.ErrGuestEmailMismatch):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "convert", "guest", id, auditGuest(before), auditGuest(guest))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(guest)
+}
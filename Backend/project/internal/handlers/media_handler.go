@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"beautiful-minds/backend/project/internal/imaging"
+	"beautiful-minds/backend/project/internal/logging"
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+	"beautiful-minds/backend/project/internal/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// multipartOverhead is the room left for the multipart framing and the
+// other form fields on top of the image itself
+const multipartOverhead = 64 << 10
+
+// MediaHandler serves the uploaded images, their thumbnails and the images
+// of events
+type MediaHandler struct {
+	repo   *repository.MediaRepository
+	events *repository.EventRepository
+	audit  *repository.AuditRepository
+	store  storage.Store
+	// maxSize (bytes) and maxPixels bound the accepted images, thumbSize
+	// their thumbnails
+	maxSize     int
+	maxPixels   int
+	thumbSize   int
+	cacheMaxAge time.Duration
+}
+
+func NewMediaHandler(
+	repo *repository.MediaRepository,
+	events *repository.EventRepository,
+	audit *repository.AuditRepository,
+	store storage.Store,
+	maxSize, maxPixels, thumbSize int,
+	cacheMaxAge time.Duration,
+) *MediaHandler {
+	return &MediaHandler{
+		repo:        repo,
+		events:      events,
+		audit:       audit,
+		store:       store,
+		maxSize:     maxSize,
+		maxPixels:   maxPixels,
+		thumbSize:   thumbSize,
+		cacheMaxAge: cacheMaxAge,
+	}
+}
+
+// parseForm reads the multipart form within the size limit, in memory
+func (h *MediaHandler) parseForm(w http.ResponseWriter, r *http.Request) bool {
+	limit := int64(h.maxSize) + multipartOverhead
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	if err := r.ParseMultipartForm(limit); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, h.tooLargeMessage(), http.StatusRequestEntityTooLarge)
+			return false
+		}
+		metrics.RecordValidationFailure("media", "decode")
+		http.Error(w, "Formulaire multipart/form-data attendu", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (h *MediaHandler) tooLargeMessage() string {
+	return fmt.Sprintf("Image trop volumineuse (max %d Ko)", h.maxSize>>10)
+}
+
+// upload checks the image of the form field "image", stores it with its
+// thumbnail and records it. Files are keyed by the SHA-256 of their
+// content, so an image uploaded twice yields the existing record and
+// created false.
+func (h *MediaHandler) upload(w http.ResponseWriter, r *http.Request) (media *models.Media, created, ok bool) {
+	if !h.parseForm(w, r) {
+		return nil, false, false
+	}
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		metrics.RecordValidationFailure("media", "decode")
+		http.Error(w, "Fichier image manquant (champ image)", http.StatusBadRequest)
+		return nil, false, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, int64(h.maxSize)+1))
+	if err != nil {
+		serverError(w, r, err)
+		return nil, false, false
+	}
+	if len(data) > h.maxSize {
+		metrics.RecordValidationFailure("media", "validate")
+		http.Error(w, h.tooLargeMessage(), http.StatusRequestEntityTooLarge)
+		return nil, false, false
+	}
+
+	// Le type est déduit du contenu, pas de l'en-tête envoyé par le client
+	img, err := imaging.Process(data, h.maxPixels, h.thumbSize)
+	switch {
+	case errors.Is(err, imaging.ErrUnsupported):
+		metrics.RecordValidationFailure("media", "validate")
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return nil, false, false
+	case errors.Is(err, imaging.ErrTooLarge):
+		metrics.RecordValidationFailure("media", "validate")
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return nil, false, false
+	case err != nil:
+		serverError(w, r, err)
+		return nil, false, false
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	media = &models.Media{
+		ContentType:  img.ContentType,
+		Size:         int64(len(data)),
+		Width:        img.Width,
+		Height:       img.Height,
+		OriginalName: models.CleanFileName(header.Filename),
+		Key:          hash + img.Ext,
+		ThumbnailKey: hash + "-thumb" + img.ThumbnailExt,
+	}
+	media.URL = h.store.URL(media.Key)
+	media.ThumbnailURL = h.store.URL(media.ThumbnailKey)
+
+	// Fichiers d'abord : un enregistrement ne désigne jamais un fichier absent
+	ctx := r.Context()
+	if err := h.store.Put(ctx, media.Key, bytes.NewReader(data), media.Size, media.ContentType); err != nil {
+		serverError(w, r, err)
+		return nil, false, false
+	}
+	err = h.store.Put(ctx, media.ThumbnailKey, bytes.NewReader(img.Thumbnail), int64(len(img.Thumbnail)), img.ThumbnailType)
+	if err != nil {
+		serverError(w, r, err)
+		return nil, false, false
+	}
+
+	media, created, err = h.repo.Create(media)
+	if err != nil {
+		serverError(w, r, err)
+		return nil, false, false
+	}
+	if created {
+		recordAudit(h.audit, r, "create", "media", media.ID, nil, media)
+	}
+	return media, created, true
+}
+
+func (h *MediaHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	media, err := h.repo.GetAll()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(media)
+}
+
+func (h *MediaHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	media, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Image non trouvée", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(media)
+}
+
+// Upload adds an image to the library; 201 for a new image, 200 when the
+// same content was already uploaded
+func (h *MediaHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	media, created, ok := h.upload(w, r)
+	if !ok {
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(media)
+}
+
+// Delete removes an image unused by events, with its files
+func (h *MediaHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Image non trouvée", http.StatusNotFound)
+		return
+	}
+
+	if err := h.repo.Delete(id); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			http.Error(w, "Image non trouvée", http.StatusNotFound)
+		case errors.Is(err, repository.ErrMediaInUse):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			serverError(w, r, err)
+		}
+		return
+	}
+
+	for _, key := range []string{before.Key, before.ThumbnailKey} {
+		if err := h.store.Delete(r.Context(), key); err != nil {
+			logging.FromContext(r.Context()).Error("suppression du fichier échouée", "error", err, "key", key)
+		}
+	}
+
+	recordAudit(h.audit, r, "delete", "media", id, before, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Image supprimée"})
+}
+
+// Serve answers a stored file. Keys change with the content, so clients may
+// cache files for cacheMaxAge without revalidating.
+func (h *MediaHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	tag := `"` + key + `"`
+
+	if h.cacheMaxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d, immutable", int(h.cacheMaxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("ETag", tag)
+	if ifNoneMatch(r, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	file, err := h.store.Get(r.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Fichier non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if rs, ok := file.(io.ReadSeeker); ok {
+		// Type d'après l'extension de la clé, tailles et plages (Range)
+		http.ServeContent(w, r, key, time.Time{}, rs)
+		return
+	}
+	io.Copy(w, file)
+}
+
+// SetEventImage shows an image on an event: a new upload (field image) or
+// an image of the library (field media_id)
+func (h *MediaHandler) SetEventImage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	expected, err := expectedVersion(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.events.GetByID(id)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	if !h.parseForm(w, r) {
+		return
+	}
+
+	var media *models.Media
+	if value := r.FormValue("media_id"); value != "" {
+		mediaID, err := strconv.Atoi(value)
+		if err != nil {
+			metrics.RecordValidationFailure("media", "validate")
+			http.Error(w, "media_id invalide", http.StatusBadRequest)
+			return
+		}
+		if media, err = h.repo.GetByID(mediaID); err != nil {
+			http.Error(w, "Image non trouvée", http.StatusBadRequest)
+			return
+		}
+	} else {
+		var ok bool
+		if media, _, ok = h.upload(w, r); !ok {
+			return
+		}
+	}
+
+	h.setEventImage(w, r, before, media, expected)
+}
+
+// RemoveEventImage stops showing an image on an event; the image stays in
+// the library
+func (h *MediaHandler) RemoveEventImage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	expected, ok := requiredVersion(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := h.events.GetByID(id)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	h.setEventImage(w, r, before, nil, expected)
+}
+
+func (h *MediaHandler) setEventImage(w http.ResponseWriter, r *http.Request, before *models.Event, media *models.Media, expected int) {
+	event, err := h.events.SetImage(before.ID, media, expected, actor(r))
+	if isVersionConflict(w, err) {
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "update", "event", event.ID, before, event)
+
+	w.Header().Set("ETag", etag(event.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
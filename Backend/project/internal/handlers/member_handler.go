@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/middleware"
 	"beautiful-minds/backend/project/internal/models"
 	"beautiful-minds/backend/project/internal/repository"
 
@@ -13,22 +16,23 @@ import (
 )
 
 type MemberHandler struct {
-	repo *repository.MemberRepository
+	repo  *repository.MemberRepository
+	audit *repository.AuditRepository
 }
 
-func NewMemberHandler(repo *repository.MemberRepository) *MemberHandler {
-	return &MemberHandler{repo: repo}
+func NewMemberHandler(repo *repository.MemberRepository, audit *repository.AuditRepository) *MemberHandler {
+	return &MemberHandler{repo: repo, audit: audit}
 }
 
 func (h *MemberHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 	members, err := h.repo.GetAll()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(members)
+	json.NewEncoder(w).Encode(shapeMembers(r, members))
 }
 
 func (h *MemberHandler) GetByID(w http.ResponseWriter, r *http.Request) {
@@ -45,19 +49,29 @@ func (h *MemberHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if writeETag(w, r, member.Version) {
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(member)
+	json.NewEncoder(w).Encode(shapeMember(r, member))
 }
 
 func (h *MemberHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateMemberRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("member", "decode")
 		http.Error(w, "Données invalides", http.StatusBadRequest)
 		return
 	}
 
+	if !memberRolesAllowed(w, r, &req, nil) {
+		return
+	}
+
 	// Validate request
 	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("member", "validate")
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -65,17 +79,20 @@ func (h *MemberHandler) Create(w http.ResponseWriter, r *http.Request) {
 	member, err := h.repo.Create(&req)
 	if err != nil {
 		// Check for duplicate email error
-		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique constraint") {
+		if isDuplicate(err) {
 			http.Error(w, "Cet email est déjà utilisé", http.StatusConflict)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
+	recordAudit(h.audit, r, "create", "member", member.ID, nil, auditMember(member))
+
+	w.Header().Set("ETag", etag(member.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(member)
+	json.NewEncoder(w).Encode(shapeMember(r, member))
 }
 
 // Delete removes a member
@@ -87,12 +104,28 @@ func (h *MemberHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.repo.Delete(id)
+	expected, ok := requiredVersion(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Membre non trouvé", http.StatusNotFound)
+		return
+	}
+
+	err = h.repo.Delete(id, expected)
+	if isVersionConflict(w, err) {
+		return
+	}
 	if err != nil {
 		http.Error(w, "Membre non trouvé", http.StatusNotFound)
 		return
 	}
 
+	recordAudit(h.audit, r, "delete", "member", id, auditMember(before), nil)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Membre supprimé"})
@@ -101,19 +134,32 @@ func (h *MemberHandler) Delete(w http.ResponseWriter, r *http.Request) {
 // Search searches for members by name or email
 func (h *MemberHandler) Search(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
-	if query == "" {
-		http.Error(w, "Paramètre 'q' requis pour la recherche", http.StatusBadRequest)
+	studentID := r.URL.Query().Get("student_id")
+	if query == "" && studentID == "" {
+		http.Error(w, "Paramètre 'q' ou 'student_id' requis pour la recherche", http.StatusBadRequest)
 		return
 	}
 
-	members, err := h.repo.Search(query)
+	var members []models.Member
+	var err error
+	if studentID != "" {
+		// Exact lookup through the blind index, reserved to roles that may
+		// see student IDs
+		if !middleware.HasRole(r, sensitiveFieldRoles...) {
+			http.Error(w, "Recherche par numéro étudiant non autorisée", http.StatusForbidden)
+			return
+		}
+		members, err = h.repo.GetByStudentID(studentID)
+	} else {
+		members, err = h.repo.Search(query)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(members)
+	json.NewEncoder(w).Encode(shapeMembers(r, members))
 }
 
 // Update updates a member
@@ -125,22 +171,42 @@ func (h *MemberHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	expected, ok := requiredVersion(w, r)
+	if !ok {
+		return
+	}
+
 	var req models.CreateMemberRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("member", "decode")
 		http.Error(w, "Données invalides", http.StatusBadRequest)
 		return
 	}
 
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Membre non trouvé", http.StatusNotFound)
+		return
+	}
+	keepMaskedFields(&req, before)
+	if !memberRolesAllowed(w, r, &req, before) {
+		return
+	}
+
 	// Validate request
 	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("member", "validate")
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	member, err := h.repo.Update(id, &req)
+	member, err := h.repo.Update(id, &req, expected)
+	if isVersionConflict(w, err) {
+		return
+	}
 	if err != nil {
 		// Check for duplicate email error
-		if strings.Contains(err.Error(), "duplicate key") || strings.Contains(err.Error(), "unique constraint") {
+		if isDuplicate(err) {
 			http.Error(w, "Cet email est déjà utilisé", http.StatusConflict)
 			return
 		}
@@ -148,6 +214,179 @@ func (h *MemberHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	recordAudit(h.audit, r, "update", "member", id, auditMember(before), auditMember(member))
+
+	w.Header().Set("ETag", etag(member.Version))
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(member)
+	json.NewEncoder(w).Encode(shapeMember(r, member))
+}
+
+// Patch applies a JSON Merge Patch to a member; If-Match is required
+func (h *MemberHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, expected, patch, ok := readMergePatch(w, r)
+	if !ok {
+		return
+	}
+
+	current, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Membre non trouvé", http.StatusNotFound)
+		return
+	}
+
+	var req models.CreateMemberRequest
+	if !decodeMergePatch(w, "member", current, current.Version, expected, patch, &req) {
+		return
+	}
+	keepMaskedFields(&req, current)
+	if !memberRolesAllowed(w, r, &req, current) {
+		return
+	}
+
+	// Validate request
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("member", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.repo.Update(id, &req, expected)
+	if isVersionConflict(w, err) {
+		return
+	}
+	if err != nil {
+		// Check for duplicate email error
+		if isDuplicate(err) {
+			http.Error(w, "Cet email est déjà utilisé", http.StatusConflict)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "patch", "member", id, auditMember(current), auditMember(member))
+
+	w.Header().Set("ETag", etag(member.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shapeMember(r, member))
+}
+
+// Restore takes a member out of the trash; admins only
+func (h *MemberHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	if !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Corbeille réservée aux administrateurs", http.StatusForbidden)
+		return
+	}
+
+	member, err := h.repo.Restore(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Membre non trouvé dans la corbeille", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		// A new member may have taken the email in the meantime
+		if isDuplicate(err) {
+			http.Error(w, "Cet email est déjà utilisé", http.StatusConflict)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "restore", "member", id, nil, auditMember(member))
+
+	w.Header().Set("ETag", etag(member.Version))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shapeMember(r, member))
+}
+
+// sensitiveFieldRoles may read phone numbers and student IDs in clear
+var sensitiveFieldRoles = []middleware.Role{middleware.RoleAdmin}
+
+// shapeMember masks the sensitive fields unless the caller may see them
+func shapeMember(r *http.Request, m *models.Member) *models.Member {
+	if middleware.HasRole(r, sensitiveFieldRoles...) {
+		return m
+	}
+	masked := m.Masked()
+	return &masked
+}
+
+func shapeMembers(r *http.Request, members []models.Member) []models.Member {
+	if middleware.HasRole(r, sensitiveFieldRoles...) {
+		return members
+	}
+	masked := make([]models.Member, len(members))
+	for i, m := range members {
+		masked[i] = m.Masked()
+	}
+	return masked
+}
+
+// auditMember redacts the personal data so the audit log never holds it,
+// not even partly
+func auditMember(m *models.Member) any {
+	if m == nil {
+		return nil
+	}
+	return m.Redacted()
+}
+
+// keepMaskedFields keeps the stored value of fields sent back as they were
+// masked, so clients editing a masked member do not overwrite them
+func keepMaskedFields(req *models.CreateMemberRequest, current *models.Member) {
+	masked := current.Masked()
+	if current.Phone != "" && strings.TrimSpace(req.Phone) == masked.Phone {
+		req.Phone = current.Phone
+	}
+	if current.StudentID != "" && strings.TrimSpace(req.StudentID) == masked.StudentID {
+		req.StudentID = current.StudentID
+	}
+}
+
+// memberRoleRoles may set the roles announcements are targeted at
+var memberRoleRoles = []middleware.Role{middleware.RoleAdmin}
+
+// memberRolesAllowed keeps the current roles (none for a new member) when a
+// caller who may not set them leaves them out, and answers 403 when such a
+// caller tries to change them
+func memberRolesAllowed(w http.ResponseWriter, r *http.Request, req *models.CreateMemberRequest, current *models.Member) bool {
+	if middleware.HasRole(r, memberRoleRoles...) {
+		return true
+	}
+
+	var roles []string
+	if current != nil {
+		roles = current.Roles
+	}
+	if req.Roles == nil {
+		req.Roles = roles
+		return true
+	}
+
+	// Invalid roles are left to Validate
+	requested, err := models.NormalizeRoles(req.Roles)
+	if err != nil {
+		return true
+	}
+	held := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		held[role] = true
+	}
+	changed := len(requested) != len(held)
+	for _, role := range requested {
+		changed = changed || !held[role]
+	}
+	if changed {
+		http.Error(w, "Seul un administrateur peut attribuer des rôles", http.StatusForbidden)
+		return false
+	}
+	return true
 }
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"beautiful-minds/backend/project/internal/middleware"
+	"beautiful-minds/backend/project/internal/models"
+)
+
+// asRole returns a request carrying the role resolved for the bearer token
+func asRole(t *testing.T, token string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPut, "/members/1", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	var resolved *http.Request
+	middleware.Roles("secret")(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		resolved = r
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	return resolved
+}
+
+func TestMemberRolesAllowed(t *testing.T) {
+	current := &models.Member{Roles: []string{"bureau", "robotique"}}
+	tests := []struct {
+		name      string
+		token     string
+		roles     []string
+		current   *models.Member
+		allowed   bool
+		wantRoles []string
+	}{
+		{"admin sets roles", "secret", []string{"bureau"}, nil, true, []string{"bureau"}},
+		{"anonymous creates without roles", "", nil, nil, true, nil},
+		{"anonymous creates with roles", "", []string{"bureau"}, nil, false, nil},
+		{"anonymous keeps the current roles", "", nil, current, true, []string{"bureau", "robotique"}},
+		{"anonymous resends the current roles", "", []string{"Robotique", "bureau"}, current, true, []string{"Robotique", "bureau"}},
+		{"anonymous adds a role", "", []string{"bureau", "robotique", "admin"}, current, false, nil},
+		{"anonymous drops a role", "", []string{"bureau"}, current, false, nil},
+		{"anonymous clears the roles", "", []string{}, current, false, nil},
+		{"wrong token", "guess", []string{"bureau"}, nil, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &models.CreateMemberRequest{Roles: tt.roles}
+			w := httptest.NewRecorder()
+			if got := memberRolesAllowed(w, asRole(t, tt.token), req, tt.current); got != tt.allowed {
+				t.Fatalf("allowed = %v, want %v", got, tt.allowed)
+			}
+			if !tt.allowed {
+				if w.Code != http.StatusForbidden {
+					t.Errorf("status %d, want 403", w.Code)
+				}
+				return
+			}
+			if !reflect.DeepEqual(req.Roles, tt.wantRoles) {
+				t.Errorf("roles = %q, want %q", req.Roles, tt.wantRoles)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// maxOccurrenceRange bounds the window of an occurrence listing
+const maxOccurrenceRange = 2 * 366 * 24 * time.Hour
+
+type OccurrenceHandler struct {
+	events      *repository.EventRepository
+	occurrences *repository.OccurrenceRepository
+	audit       *repository.AuditRepository
+	horizon     time.Duration
+}
+
+func NewOccurrenceHandler(events *repository.EventRepository, occurrences *repository.OccurrenceRepository,
+	audit *repository.AuditRepository, horizon time.Duration) *OccurrenceHandler {
+	return &OccurrenceHandler{events: events, occurrences: occurrences, audit: audit, horizon: horizon}
+}
+
+// series loads the recurring event of the route; it writes the error
+// response and returns nil when there is none
+func (h *OccurrenceHandler) series(w http.ResponseWriter, r *http.Request) *models.Event {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return nil
+	}
+
+	event, err := h.events.GetByID(id)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return nil
+	}
+	if event.RecurrenceRule == nil {
+		http.Error(w, "L'événement n'est pas récurrent", http.StatusBadRequest)
+		return nil
+	}
+
+	return event
+}
+
+// occurrenceStart parses the {start} route variable and checks that it is an
+// occurrence of the series
+func occurrenceStart(w http.ResponseWriter, r *http.Request, event *models.Event) (time.Time, bool) {
+	start, err := time.Parse(time.RFC3339, mux.Vars(r)["start"])
+	if err != nil {
+		http.Error(w, "Début d'occurrence invalide (RFC 3339 attendu)", http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	start = start.UTC()
+
+	if !event.HasOccurrence(start) {
+		http.Error(w, "Occurrence non trouvée", http.StatusNotFound)
+		return time.Time{}, false
+	}
+
+	return start, true
+}
+
+// List expands a recurring event into its occurrences between ?from= and
+// ?to= (RFC 3339, defaulting to now and the listing horizon). Cancelled
+// occurrences are included.
+func (h *OccurrenceHandler) List(w http.ResponseWriter, r *http.Request) {
+	event := h.series(w, r)
+	if event == nil {
+		return
+	}
+
+	from := time.Now()
+	to := from.Add(h.horizon)
+	for name, dest := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := r.URL.Query().Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, name+" doit être une date RFC 3339", http.StatusBadRequest)
+				return
+			}
+			*dest = t
+		}
+	}
+	if !to.After(from) || to.Sub(from) > maxOccurrenceRange {
+		http.Error(w, "Intervalle invalide (to doit suivre from, deux ans au plus)", http.StatusBadRequest)
+		return
+	}
+
+	overrides, err := h.occurrences.GetByEvents([]int{event.ID})
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	occurrences := event.Occurrences(from, to, overrides[event.ID])
+	if occurrences == nil {
+		occurrences = []models.Occurrence{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(occurrences)
+}
+
+// Update replaces the override of one occurrence. Null fields follow the
+// series; cancelled: false restores a cancelled occurrence.
+func (h *OccurrenceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	event := h.series(w, r)
+	if event == nil {
+		return
+	}
+	start, ok := occurrenceStart(w, r, event)
+	if !ok {
+		return
+	}
+
+	var req models.OccurrenceOverride
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("occurrence", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("occurrence", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.OccurrenceStart = start
+
+	h.save(w, r, event, &req, "update_occurrence")
+}
+
+// Cancel marks one occurrence as cancelled, keeping its other changes
+func (h *OccurrenceHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	event := h.series(w, r)
+	if event == nil {
+		return
+	}
+	start, ok := occurrenceStart(w, r, event)
+	if !ok {
+		return
+	}
+
+	override, err := h.occurrences.Get(event.ID, start)
+	if err == sql.ErrNoRows {
+		override = &models.OccurrenceOverride{OccurrenceStart: start}
+	} else if err != nil {
+		serverError(w, r, err)
+		return
+	}
+	override.Cancelled = true
+
+	h.save(w, r, event, override, "cancel_occurrence")
+}
+
+func (h *OccurrenceHandler) save(w http.ResponseWriter, r *http.Request, event *models.Event, override *models.OccurrenceOverride, action string) {
+	var before *models.Occurrence
+	previous, err := h.occurrences.Get(event.ID, override.OccurrenceStart)
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, err)
+		return
+	}
+	if previous != nil {
+		o := event.OccurrenceAt(previous.OccurrenceStart, previous)
+		before = &o
+	}
+
+	saved, err := h.occurrences.Save(event.ID, override)
+	if isRejectedEvent(w, err) {
+		return
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	occurrence := event.OccurrenceAt(saved.OccurrenceStart, saved)
+	recordAudit(h.audit, r, action, "event", event.ID, before, occurrence)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(occurrence)
+}
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"beautiful-minds/backend/project/internal/logging"
+	"beautiful-minds/backend/project/internal/mailer"
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/middleware"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+var erasurePage = template.Must(template.New("erasure").Parse(`<!DOCTYPE html>
+<html lang="fr">
+<head><meta charset="utf-8"><title>Effacement de vos données</title></head>
+<body>
+{{if .Done}}
+  <p>Vos données personnelles ont été effacées. Merci d'avoir fait partie du club.</p>
+{{else}}
+  <p>Confirmez-vous l'effacement définitif de vos données personnelles ?
+  Vos inscriptions passées seront conservées de façon anonyme.</p>
+  <form method="post"><button type="submit">Effacer mes données</button></form>
+{{end}}
+</body>
+</html>
+`))
+
+type PrivacyHandler struct {
+	members   *repository.MemberRepository
+	events    *repository.EventRepository
+	sessions  *repository.SessionRepository
+	surveys   *repository.SurveyRepository
+	guests    *repository.GuestRepository
+	requests  *repository.PrivacyRepository
+	audit     *repository.AuditRepository
+	mailer    mailer.Mailer
+	publicURL string
+	tokenTTL  time.Duration
+}
+
+func NewPrivacyHandler(
+	members *repository.MemberRepository,
+	events *repository.EventRepository,
+	sessions *repository.SessionRepository,
+	surveys *repository.SurveyRepository,
+	guests *repository.GuestRepository,
+	requests *repository.PrivacyRepository,
+	audit *repository.AuditRepository,
+	m mailer.Mailer,
+	publicURL string,
+	tokenTTL time.Duration,
+) *PrivacyHandler {
+	return &PrivacyHandler{
+		members:   members,
+		events:    events,
+		sessions:  sessions,
+		surveys:   surveys,
+		guests:    guests,
+		requests:  requests,
+		audit:     audit,
+		mailer:    m,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		tokenTTL:  tokenTTL,
+	}
+}
+
+// Request starts a self-service export or erasure for a member or, failing
+// that, a guest. The answer does not reveal whether the email is known.
+func (h *PrivacyHandler) Request(w http.ResponseWriter, r *http.Request) {
+	var req models.CreatePrivacyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("privacy_request", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("privacy_request", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.members.GetByEmail(req.Email)
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, err)
+		return
+	}
+
+	if member != nil {
+		err = h.startRequest(r, req.Type, member.FirstName, member.Email, &member.ID, nil)
+	} else {
+		var guest *models.Guest
+		guest, err = h.guests.GetByEmail(req.Email)
+		if err == nil {
+			err = h.startRequest(r, req.Type, guest.FirstName, guest.Email, nil, &guest.ID)
+		}
+	}
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Si cette adresse correspond à un membre ou à un invité, un email de confirmation a été envoyé",
+	})
+}
+
+// startRequest records the pending request of a member or a guest and
+// emails its confirmation link
+func (h *PrivacyHandler) startRequest(r *http.Request, requestType, firstName, email string, memberID, guestID *int) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(h.tokenTTL)
+	request, err := h.requests.CreatePending(memberID, guestID, requestType, hashToken(token), middleware.ClientIP(r), expiresAt)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/v1/privacy-requests/%s/%s", h.publicURL, token, map[string]string{
+		models.PrivacyExport:  "export",
+		models.PrivacyErasure: "erase",
+	}[requestType])
+
+	subject := "Export de vos données personnelles"
+	action := "télécharger une copie de vos données"
+	if requestType == models.PrivacyErasure {
+		subject = "Effacement de vos données personnelles"
+		action = "confirmer l'effacement de vos données"
+	}
+	body := fmt.Sprintf(
+		"Bonjour %s,\n\nNous avons reçu une demande pour %s.\nUtilisez ce lien avant le %s :\n\n%s\n\nSi vous n'êtes pas à l'origine de cette demande, ignorez cet email.\n",
+		firstName, action, expiresAt.Format("02/01/2006 15:04"), link,
+	)
+
+	if err := h.mailer.Send(email, subject, body); err != nil {
+		logging.FromContext(r.Context()).Error("envoi de l'email RGPD échoué", "error", err, "request_id", request.ID)
+	}
+	return nil
+}
+
+// ExportByToken serves the data export of a confirmed self-service request
+func (h *PrivacyHandler) ExportByToken(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.requestFromToken(w, r, models.PrivacyExport)
+	if !ok {
+		return
+	}
+
+	var err error
+	if req.GuestID != nil {
+		err = h.writeGuestExport(w, r, *req.GuestID)
+	} else {
+		err = h.writeMemberExport(w, r, *req.MemberID, true)
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	if err := h.requests.Complete(req.ID, false); err != nil {
+		logging.FromContext(r.Context()).Error("clôture de la demande RGPD échouée", "error", err, "request_id", req.ID)
+	}
+}
+
+// EraseByToken shows a confirmation page (GET) and erases the data (POST)
+func (h *PrivacyHandler) EraseByToken(w http.ResponseWriter, r *http.Request) {
+	req, ok := h.requestFromToken(w, r, models.PrivacyErasure)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if r.Method == http.MethodGet {
+		erasurePage.Execute(w, map[string]bool{"Done": false})
+		return
+	}
+
+	var err error
+	if req.GuestID != nil {
+		err = h.eraseGuest(r, *req.GuestID)
+	} else {
+		err = h.erase(r, *req.MemberID)
+	}
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, err)
+		return
+	}
+	if err := h.requests.Complete(req.ID, true); err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	erasurePage.Execute(w, map[string]bool{"Done": true})
+}
+
+// List returns every privacy request for administrators
+func (h *PrivacyHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Demandes RGPD réservées aux administrateurs", http.StatusForbidden)
+		return
+	}
+
+	requests, err := h.requests.GetAll()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+// ExportMember lets an administrator export a member's data
+func (h *PrivacyHandler) ExportMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.memberID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.requests.CreateCompleted(id, models.PrivacyExport, middleware.ClientIP(r)); err != nil {
+		serverError(w, r, err)
+		return
+	}
+	recordAudit(h.audit, r, "export", "member", id, nil, nil)
+
+	if err := h.writeMemberExport(w, r, id, false); err != nil {
+		serverError(w, r, err)
+	}
+}
+
+// EraseMember lets an administrator anonymize a member
+func (h *PrivacyHandler) EraseMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.memberID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.erase(r, id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Membre déjà anonymisé", http.StatusConflict)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+	if _, err := h.requests.CreateCompleted(id, models.PrivacyErasure, middleware.ClientIP(r)); err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Données personnelles effacées"})
+}
+
+// memberID checks the caller is an admin and reads the member, trashed or
+// not, an administrator acts on
+func (h *PrivacyHandler) memberID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return 0, false
+	}
+
+	if !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Action réservée aux administrateurs", http.StatusForbidden)
+		return 0, false
+	}
+
+	if _, err := h.members.GetByIDIncludingDeleted(id); err != nil {
+		http.Error(w, "Membre non trouvé", http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *PrivacyHandler) requestFromToken(w http.ResponseWriter, r *http.Request, requestType string) (*models.PrivacyRequest, bool) {
+	req, err := h.requests.GetByToken(hashToken(mux.Vars(r)["token"]), requestType)
+	if err == sql.ErrNoRows || (err == nil && req.MemberID == nil && req.GuestID == nil) {
+		http.Error(w, "Lien invalide ou expiré", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return nil, false
+	}
+	return req, true
+}
+
+// erase anonymizes the member and audits the change without keeping the
+// erased personal data in the audit entry
+func (h *PrivacyHandler) erase(r *http.Request, memberID int) error {
+	member, err := h.members.Anonymize(memberID)
+	if err != nil {
+		return err
+	}
+	recordAudit(h.audit, r, "erase", "member", memberID, nil, auditMember(member))
+	return nil
+}
+
+// eraseGuest deletes a guest and its registrations, auditing the erasure
+// without the guest's personal data
+func (h *PrivacyHandler) eraseGuest(r *http.Request, guestID int) error {
+	guest, err := h.guests.GetByID(guestID)
+	if err != nil {
+		return err
+	}
+	if err := h.guests.Delete(guestID); err != nil {
+		return err
+	}
+	recordAudit(h.audit, r, "erase", "guest", guestID, auditGuest(guest), nil)
+	return nil
+}
+
+// exportFile is a file of an export archive
+type exportFile struct {
+	name    string
+	content any
+}
+
+// writeExport answers with a ZIP archive of the files, or the whole
+// document when format=json is requested
+func writeExport(w http.ResponseWriter, r *http.Request, filename string, document any, files []exportFile) error {
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		return json.NewEncoder(w).Encode(document)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// writeMemberExport answers the data export of a member. The member's own
+// export (owner), reached with the emailed token, holds their phone number
+// and student ID in clear; for anyone else the profile is masked as by
+// shapeMember.
+func (h *PrivacyHandler) writeMemberExport(w http.ResponseWriter, r *http.Request, memberID int, owner bool) error {
+	export, err := h.buildExport(memberID)
+	if err != nil {
+		return err
+	}
+	if !owner {
+		export.Profile = shapeMember(r, export.Profile)
+	}
+
+	return writeExport(w, r, fmt.Sprintf("membre-%d-donnees", memberID), export, []exportFile{
+		{"profile.json", export.Profile},
+		{"registrations.json", export.Registrations},
+		{"session_registrations.json", export.SessionRegistrations},
+		{"survey_responses.json", export.SurveyResponses},
+		{"privacy_requests.json", export.PrivacyRequests},
+		{"guests.json", export.Guests},
+	})
+}
+
+func (h *PrivacyHandler) writeGuestExport(w http.ResponseWriter, r *http.Request, guestID int) error {
+	guest, err := h.guests.GetByID(guestID)
+	if err != nil {
+		return err
+	}
+	requests, err := h.requests.GetByGuest(guestID)
+	if err != nil {
+		return err
+	}
+
+	export := &models.GuestDataExport{
+		GeneratedAt:     time.Now().UTC(),
+		Profile:         guest,
+		PrivacyRequests: requests,
+	}
+	return writeExport(w, r, fmt.Sprintf("invite-%d-donnees", guestID), export, []exportFile{
+		{"profile.json", export.Profile},
+		{"privacy_requests.json", export.PrivacyRequests},
+	})
+}
+
+func (h *PrivacyHandler) buildExport(memberID int) (*models.MemberDataExport, error) {
+	member, err := h.members.GetByIDIncludingDeleted(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	registrations, err := h.events.GetRegistrationsByMember(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionRegistrations, err := h.sessions.GetRegistrationsByMember(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	surveyResponses, err := h.surveys.GetResponsesByMember(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	requests, err := h.requests.GetByMember(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	guests, err := h.guests.GetByMember(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MemberDataExport{
+		GeneratedAt:          time.Now().UTC(),
+		Profile:              member,
+		Registrations:        registrations,
+		SessionRegistrations: sessionRegistrations,
+		SurveyResponses:      surveyResponses,
+		PrivacyRequests:      requests,
+		Guests:               guests,
+	}, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
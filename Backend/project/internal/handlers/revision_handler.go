@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// listRevisions answers the revisions of an entity, latest first
+func listRevisions(w http.ResponseWriter, r *http.Request, revisions *repository.RevisionRepository, entityType string, entityID int) {
+	list, err := revisions.List(entityType, entityID)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// revisionVersion reads the {version} path variable
+func revisionVersion(w http.ResponseWriter, r *http.Request) (int, bool) {
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil || version <= 0 {
+		http.Error(w, "Version invalide", http.StatusBadRequest)
+		return 0, false
+	}
+	return version, true
+}
+
+// getRevision reads a revision of an entity, answering 404 when that
+// version was not recorded
+func getRevision(w http.ResponseWriter, r *http.Request, revisions *repository.RevisionRepository, entityType string, entityID, version int) (*models.Revision, bool) {
+	rev, err := revisions.Get(entityType, entityID, version)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Révision non trouvée", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return nil, false
+	}
+	return rev, true
+}
+
+// writeRevision answers a revision with its snapshot
+func writeRevision(w http.ResponseWriter, r *http.Request, revisions *repository.RevisionRepository, entityType string, entityID int) {
+	version, ok := revisionVersion(w, r)
+	if !ok {
+		return
+	}
+
+	rev, ok := getRevision(w, r, revisions, entityType, entityID, version)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rev)
+}
+
+// diffRevisions answers the fields changed between the revisions
+// ?from= and ?to= of an entity
+func diffRevisions(w http.ResponseWriter, r *http.Request, revisions *repository.RevisionRepository, entityType string, entityID int) {
+	var versions [2]int
+	for i, name := range []string{"from", "to"} {
+		v, err := strconv.Atoi(r.URL.Query().Get(name))
+		if err != nil || v <= 0 {
+			http.Error(w, "Paramètres from et to obligatoires (numéros de version)", http.StatusBadRequest)
+			return
+		}
+		versions[i] = v
+	}
+
+	from, ok := getRevision(w, r, revisions, entityType, entityID, versions[0])
+	if !ok {
+		return
+	}
+	to, ok := getRevision(w, r, revisions, entityType, entityID, versions[1])
+	if !ok {
+		return
+	}
+
+	diff := models.RevisionDiff{From: from.Version, To: to.Version, Changes: diffJSON(from.Snapshot, to.Snapshot)}
+	if diff.Changes == nil {
+		diff.Changes = json.RawMessage(`{}`)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
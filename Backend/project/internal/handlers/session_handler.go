@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// SessionHandler serves the agenda of events and the registrations to
+// their sessions
+type SessionHandler struct {
+	repo   *repository.SessionRepository
+	events *repository.EventRepository
+	audit  *repository.AuditRepository
+}
+
+func NewSessionHandler(repo *repository.SessionRepository, events *repository.EventRepository, audit *repository.AuditRepository) *SessionHandler {
+	return &SessionHandler{repo: repo, events: events, audit: audit}
+}
+
+// ids reads the event ID and, when present, the session ID of the route
+func (h *SessionHandler) ids(w http.ResponseWriter, r *http.Request) (eventID, sessionID int, ok bool) {
+	vars := mux.Vars(r)
+	eventID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	if v, found := vars["sessionId"]; found {
+		if sessionID, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "ID de session invalide", http.StatusBadRequest)
+			return 0, 0, false
+		}
+	}
+	return eventID, sessionID, true
+}
+
+// Agenda lists the sessions of an event in chronological order
+func (h *SessionHandler) Agenda(w http.ResponseWriter, r *http.Request) {
+	eventID, _, ok := h.ids(w, r)
+	if !ok {
+		return
+	}
+
+	event, err := h.events.GetByID(eventID)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	sessions, err := h.repo.GetByEvent(eventID)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.Agenda{
+		EventID:  event.ID,
+		Title:    event.Title,
+		Timezone: event.Timezone,
+		Sessions: sessions,
+	})
+}
+
+func (h *SessionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	eventID, sessionID, ok := h.ids(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.repo.GetByID(eventID, sessionID)
+	if err != nil {
+		http.Error(w, "Session non trouvée", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// decode reads and validates a session against the event it belongs to
+func (h *SessionHandler) decode(w http.ResponseWriter, r *http.Request, eventID int) (*models.CreateSessionRequest, bool) {
+	var req models.CreateSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("session", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return nil, false
+	}
+
+	event, err := h.events.GetByID(eventID)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return nil, false
+	}
+
+	if err := req.Validate(event); err != nil {
+		metrics.RecordValidationFailure("session", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return &req, true
+}
+
+// isRejectedSession answers the references to missing speakers or venues,
+// the venue bookings it would overlap and a capacity below the seats taken
+func isRejectedSession(w http.ResponseWriter, err error) bool {
+	switch {
+	case isBookingConflict(w, err):
+		return true
+	case errors.Is(err, repository.ErrUnknownSpeaker), errors.Is(err, repository.ErrUnknownVenue):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return true
+	case errors.Is(err, repository.ErrCapacityBelowRegistrations):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return true
+	}
+	return false
+}
+
+func (h *SessionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	eventID, _, ok := h.ids(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := h.decode(w, r, eventID)
+	if !ok {
+		return
+	}
+
+	session, err := h.repo.Create(eventID, req)
+	if err != nil {
+		if isRejectedSession(w, err) {
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "create", "session", session.ID, nil, session)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+func (h *SessionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	eventID, sessionID, ok := h.ids(w, r)
+	if !ok {
+		return
+	}
+
+	req, ok := h.decode(w, r, eventID)
+	if !ok {
+		return
+	}
+
+	before, err := h.repo.GetByID(eventID, sessionID)
+	if err != nil {
+		http.Error(w, "Session non trouvée", http.StatusNotFound)
+		return
+	}
+
+	session, err := h.repo.Update(eventID, sessionID, req)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Session non trouvée", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		if isRejectedSession(w, err) {
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "update", "session", sessionID, before, session)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+func (h *SessionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	eventID, sessionID, ok := h.ids(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := h.repo.GetByID(eventID, sessionID)
+	if err != nil {
+		http.Error(w, "Session non trouvée", http.StatusNotFound)
+		return
+	}
+
+	if err := h.repo.Delete(eventID, sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Session non trouvée", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "delete", "session", sessionID, before, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Session supprimée"})
+}
+
+// Register takes a seat in a session. The member must be registered to the
+// event, and the session must accept registrations and have seats left.
+func (h *SessionHandler) Register(w http.ResponseWriter, r *http.Request) {
+	eventID, sessionID, ok := h.ids(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.RegisterSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("session", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	err := h.repo.Register(eventID, sessionID, req.MemberID)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Session non trouvée", http.StatusNotFound)
+		return
+	case errors.Is(err, repository.ErrNotRegistered):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, repository.ErrRegistrationClosed), errors.Is(err, repository.ErrSessionFull):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case isDuplicate(err):
+		http.Error(w, "Membre déjà inscrit à la session", http.StatusConflict)
+		return
+	case err != nil:
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "register", "session", sessionID, nil, req)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Inscription à la session réussie",
+	})
+}
+
+// Unregister frees the seat of ?member_id= in a session
+func (h *SessionHandler) Unregister(w http.ResponseWriter, r *http.Request) {
+	eventID, sessionID, ok := h.ids(w, r)
+	if !ok {
+		return
+	}
+
+	memberID, err := strconv.Atoi(r.URL.Query().Get("member_id"))
+	if err != nil {
+		http.Error(w, "member_id invalide", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.Unregister(eventID, sessionID, memberID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Inscription non trouvée", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "unregister", "session", sessionID, models.RegisterSessionRequest{MemberID: memberID}, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Inscription annulée"})
+}
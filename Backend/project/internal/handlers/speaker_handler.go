@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+type SpeakerHandler struct {
+	repo  *repository.SpeakerRepository
+	audit *repository.AuditRepository
+}
+
+func NewSpeakerHandler(repo *repository.SpeakerRepository, audit *repository.AuditRepository) *SpeakerHandler {
+	return &SpeakerHandler{repo: repo, audit: audit}
+}
+
+func (h *SpeakerHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	speakers, err := h.repo.GetAll()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(speakers)
+}
+
+func (h *SpeakerHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	speaker, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Intervenant non trouvé", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(speaker)
+}
+
+func (h *SpeakerHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateSpeakerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("speaker", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("speaker", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	speaker, err := h.repo.Create(&req)
+	if err != nil {
+		if errors.Is(err, repository.ErrUnknownMember) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "create", "speaker", speaker.ID, nil, speaker)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(speaker)
+}
+
+func (h *SpeakerHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CreateSpeakerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("speaker", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("speaker", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Intervenant non trouvé", http.StatusNotFound)
+		return
+	}
+
+	speaker, err := h.repo.Update(id, &req)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Intervenant non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrUnknownMember) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "update", "speaker", id, before, speaker)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(speaker)
+}
+
+// Delete removes a speaker; the sessions keep running without them
+func (h *SpeakerHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Intervenant non trouvé", http.StatusNotFound)
+		return
+	}
+
+	if err := h.repo.Delete(id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Intervenant non trouvé", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "delete", "speaker", id, before, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Intervenant supprimé"})
+}
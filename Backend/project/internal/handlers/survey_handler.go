@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"beautiful-minds/backend/project/internal/logging"
+	"beautiful-minds/backend/project/internal/mailer"
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// SurveyHandler serves the feedback surveys of events
+type SurveyHandler struct {
+	repo      *repository.SurveyRepository
+	events    *repository.EventRepository
+	members   *repository.MemberRepository
+	audit     *repository.AuditRepository
+	mailer    mailer.Mailer
+	publicURL string
+	tokenTTL  time.Duration
+}
+
+func NewSurveyHandler(
+	repo *repository.SurveyRepository,
+	events *repository.EventRepository,
+	members *repository.MemberRepository,
+	audit *repository.AuditRepository,
+	m mailer.Mailer,
+	publicURL string,
+	tokenTTL time.Duration,
+) *SurveyHandler {
+	return &SurveyHandler{
+		repo:      repo,
+		events:    events,
+		members:   members,
+		audit:     audit,
+		mailer:    m,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		tokenTTL:  tokenTTL,
+	}
+}
+
+// survey reads the event of the route and its survey, answering 404 when
+// either is missing
+func (h *SurveyHandler) survey(w http.ResponseWriter, r *http.Request) (*models.Event, *models.Survey, bool) {
+	eventID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	event, err := h.events.GetByID(eventID)
+	if err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	survey, err := h.repo.GetByEvent(eventID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Questionnaire non trouvé", http.StatusNotFound)
+		return nil, nil, false
+	}
+	if err != nil {
+		serverError(w, r, err)
+		return nil, nil, false
+	}
+	return event, survey, true
+}
+
+func (h *SurveyHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, survey, ok := h.survey(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(survey)
+}
+
+// Save creates or replaces the survey of an event; its questions are
+// frozen once it has responses
+func (h *SurveyHandler) Save(w http.ResponseWriter, r *http.Request) {
+	eventID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	var req models.SurveyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("survey", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("survey", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.events.GetByID(eventID); err != nil {
+		http.Error(w, "Événement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	before, err := h.repo.GetByEvent(eventID)
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, err)
+		return
+	}
+
+	survey, err := h.repo.Save(eventID, &req)
+	if err != nil {
+		if errors.Is(err, repository.ErrSurveyAnswered) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	status, action := http.StatusOK, "update"
+	if before == nil {
+		status, action = http.StatusCreated, "create"
+	}
+	recordAudit(h.audit, r, action, "survey", survey.ID, before, survey)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(survey)
+}
+
+// Delete removes the survey of an event along with its responses
+func (h *SurveyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	event, survey, ok := h.survey(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Delete(event.ID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Questionnaire non trouvé", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "delete", "survey", survey.ID, survey, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Questionnaire supprimé"})
+}
+
+// isOpen answers 409 unless the survey accepts responses: once the event
+// (the first occurrence for a series) is over and until it closes
+func isOpen(w http.ResponseWriter, event *models.Event, survey *models.Survey) bool {
+	now := time.Now()
+	if now.Before(event.EndDate) {
+		http.Error(w, "Le questionnaire ouvre à la fin de l'événement", http.StatusConflict)
+		return false
+	}
+	if survey.IsClosed(now) {
+		http.Error(w, "Le questionnaire est clos", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// Invite emails the link answering the survey when the address belongs to
+// a member who attended the event. The answer does not reveal whether it
+// does.
+func (h *SurveyHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	event, survey, ok := h.survey(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.SurveyInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("survey_invitation", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("survey_invitation", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !isOpen(w, event, survey) {
+		return
+	}
+
+	member, err := h.members.GetByEmail(req.Email)
+	if err != nil && err != sql.ErrNoRows {
+		serverError(w, r, err)
+		return
+	}
+	if member != nil {
+		if err := h.invite(r, event, survey, member); err != nil && !errors.Is(err, repository.ErrNotAttended) {
+			serverError(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Si cette adresse correspond à un membre présent, un lien de réponse a été envoyé",
+	})
+}
+
+func (h *SurveyHandler) invite(r *http.Request, event *models.Event, survey *models.Survey, member *models.Member) error {
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(h.tokenTTL)
+	if survey.ClosesAt != nil && survey.ClosesAt.Before(expiresAt) {
+		expiresAt = *survey.ClosesAt
+	}
+	if err := h.repo.Invite(survey, member.ID, hashToken(token), expiresAt); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/api/v1/events/%d/survey", h.publicURL, event.ID)
+	body := fmt.Sprintf(
+		"Bonjour %s,\n\nMerci d'avoir participé à « %s ». Les questions du questionnaire sont ici :\n\n%s\n\nRépondez avant le %s avec ce jeton (champ token) :\n\n%s\n\nSi vous n'êtes pas à l'origine de cette demande, ignorez cet email.\n",
+		member.FirstName, event.Title, link, expiresAt.Format("02/01/2006 15:04"), token,
+	)
+	if err := h.mailer.Send(member.Email, "Votre avis sur "+event.Title, body); err != nil {
+		logging.FromContext(r.Context()).Error("envoi du lien de questionnaire échoué", "error", err, "survey_id", survey.ID)
+	}
+	return nil
+}
+
+// Respond records the response of the member whose emailed token is given.
+// The member must have attended the event.
+func (h *SurveyHandler) Respond(w http.ResponseWriter, r *http.Request) {
+	event, survey, ok := h.survey(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.SurveyResponseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("survey_response", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := survey.ValidateResponse(&req); err != nil {
+		metrics.RecordValidationFailure("survey_response", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !isOpen(w, event, survey) {
+		return
+	}
+
+	err := h.repo.Submit(survey, hashToken(req.Token), &req)
+	switch {
+	case errors.Is(err, repository.ErrInvalidSurveyToken):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, repository.ErrNotAttended):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case isDuplicate(err):
+		http.Error(w, "Ce membre a déjà répondu", http.StatusConflict)
+		return
+	case err != nil:
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Merci pour votre réponse"})
+}
+
+// Results aggregates the responses: average and distribution of ratings,
+// counts of choices and free text answers
+func (h *SurveyHandler) Results(w http.ResponseWriter, r *http.Request) {
+	_, survey, ok := h.survey(w, r)
+	if !ok {
+		return
+	}
+
+	responses, err := h.repo.Responses(survey.ID)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(survey.Aggregate(responses))
+}
+
+// Export answers the responses as CSV, one anonymous response per row and
+// one column per question
+func (h *SurveyHandler) Export(w http.ResponseWriter, r *http.Request) {
+	event, survey, ok := h.survey(w, r)
+	if !ok {
+		return
+	}
+
+	responses, err := h.repo.Responses(survey.ID)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	header := []string{"reponse", "soumise_le"}
+	for _, q := range survey.Questions {
+		header = append(header, csvCell(q.Label))
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="questionnaire-evenement-%d.csv"`, event.ID))
+
+	out := csv.NewWriter(w)
+	out.Write(header)
+	for i := range responses {
+		resp := &responses[i]
+		row := []string{strconv.Itoa(resp.ID), resp.SubmittedAt.UTC().Format(time.RFC3339)}
+		for _, q := range survey.Questions {
+			a := resp.Answer(q.ID)
+			switch {
+			case a == nil:
+				row = append(row, "")
+			case a.Rating != nil:
+				row = append(row, strconv.Itoa(*a.Rating))
+			case len(a.Choices) > 0:
+				row = append(row, csvCell(strings.Join(a.Choices, "; ")))
+			default:
+				row = append(row, csvCell(a.Text))
+			}
+		}
+		out.Write(row)
+	}
+	out.Flush()
+}
+
+// csvCell keeps spreadsheets from evaluating free text as a formula,
+// including behind leading whitespace or a tab or carriage return some
+// spreadsheets treat as a formula start
+func csvCell(value string) string {
+	if value == "" {
+		return value
+	}
+	trimmed := strings.TrimLeftFunc(value, unicode.IsSpace)
+	if strings.ContainsRune("\t\r", rune(value[0])) || (trimmed != "" && strings.ContainsRune("=+-@", rune(trimmed[0]))) {
+		return "'" + value
+	}
+	return value
+}
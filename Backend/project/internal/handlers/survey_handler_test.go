@@ -0,0 +1,27 @@
+package handlers
+
+import "testing"
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"Très bien", "Très bien"},
+		{"a = b", "a = b"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+33 6 12", "'+33 6 12"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"\tplain", "'\tplain"},
+		{"  =1+1", "'  =1+1"},
+		{"\n@SUM(A1)", "'\n@SUM(A1)"},
+	}
+	for _, tt := range tests {
+		if got := csvCell(tt.in); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
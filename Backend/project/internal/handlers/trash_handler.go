@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"beautiful-minds/backend/project/internal/middleware"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+)
+
+type TrashHandler struct {
+	members       *repository.MemberRepository
+	events        *repository.EventRepository
+	announcements *repository.AnnouncementRepository
+}
+
+func NewTrashHandler(members *repository.MemberRepository, events *repository.EventRepository, announcements *repository.AnnouncementRepository) *TrashHandler {
+	return &TrashHandler{members: members, events: events, announcements: announcements}
+}
+
+type trashResponse struct {
+	Members       []models.Member       `json:"members"`
+	Events        []models.Event        `json:"events"`
+	Announcements []models.Announcement `json:"announcements"`
+}
+
+// GetAll lists every soft-deleted member, event and announcement; admins
+// only, drafts and targeted announcements being listed as well
+func (h *TrashHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	if !middleware.HasRole(r, middleware.RoleAdmin) {
+		http.Error(w, "Corbeille réservée aux administrateurs", http.StatusForbidden)
+		return
+	}
+
+	var resp trashResponse
+	var err error
+
+	if resp.Members, err = h.members.GetDeleted(); err != nil {
+		serverError(w, r, err)
+		return
+	}
+	resp.Members = shapeMembers(r, resp.Members)
+	if resp.Events, err = h.events.GetDeleted(); err != nil {
+		serverError(w, r, err)
+		return
+	}
+	if resp.Announcements, err = h.announcements.GetDeleted(); err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"beautiful-minds/backend/project/internal/repository"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultBookingRange is the window of a bookings listing without ?to=
+const defaultBookingRange = 30 * 24 * time.Hour
+
+type VenueHandler struct {
+	repo  *repository.VenueRepository
+	audit *repository.AuditRepository
+}
+
+func NewVenueHandler(repo *repository.VenueRepository, audit *repository.AuditRepository) *VenueHandler {
+	return &VenueHandler{repo: repo, audit: audit}
+}
+
+func (h *VenueHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	venues, err := h.repo.GetAll()
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(venues)
+}
+
+func (h *VenueHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	venue, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Salle non trouvée", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(venue)
+}
+
+func (h *VenueHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateVenueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("venue", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("venue", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	venue, err := h.repo.Create(&req)
+	if err != nil {
+		if isDuplicate(err) {
+			http.Error(w, "Une salle porte déjà ce nom", http.StatusConflict)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "create", "venue", venue.ID, nil, venue)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(venue)
+}
+
+func (h *VenueHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	var req models.CreateVenueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		metrics.RecordValidationFailure("venue", "decode")
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		metrics.RecordValidationFailure("venue", "validate")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Salle non trouvée", http.StatusNotFound)
+		return
+	}
+
+	venue, err := h.repo.Update(id, &req)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Salle non trouvée", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		if isDuplicate(err) {
+			http.Error(w, "Une salle porte déjà ce nom", http.StatusConflict)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "update", "venue", id, before, venue)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(venue)
+}
+
+// Delete removes a venue; its events keep their location text
+func (h *VenueHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.repo.GetByID(id)
+	if err != nil {
+		http.Error(w, "Salle non trouvée", http.StatusNotFound)
+		return
+	}
+
+	if err := h.repo.Delete(id); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Salle non trouvée", http.StatusNotFound)
+			return
+		}
+		serverError(w, r, err)
+		return
+	}
+
+	recordAudit(h.audit, r, "delete", "venue", id, before, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Salle supprimée"})
+}
+
+// Bookings lists the time slots held in a venue between ?from= and ?to=
+// (RFC 3339, defaulting to now and thirty days later)
+func (h *VenueHandler) Bookings(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "ID invalide", http.StatusBadRequest)
+		return
+	}
+
+	from := time.Now()
+	to := from.Add(defaultBookingRange)
+	for name, dest := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := r.URL.Query().Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, name+" doit être une date RFC 3339", http.StatusBadRequest)
+				return
+			}
+			*dest = t
+		}
+	}
+	if !to.After(from) || to.Sub(from) > maxOccurrenceRange {
+		http.Error(w, "Intervalle invalide (to doit suivre from, deux ans au plus)", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.repo.GetByID(id); err != nil {
+		http.Error(w, "Salle non trouvée", http.StatusNotFound)
+		return
+	}
+
+	bookings, err := h.repo.Bookings(id, from, to)
+	if err != nil {
+		serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookings)
+}
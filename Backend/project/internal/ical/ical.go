@@ -0,0 +1,139 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"beautiful-minds/backend/project/internal/recurrence"
+)
+
+// ContentType is the media type of iCalendar documents
+const ContentType = "text/calendar; charset=utf-8"
+
+// Event is a VEVENT. A recurring event carries its RRULE and EXDATEs; a
+// modified occurrence of a series is a separate Event sharing the UID with
+// RecurrenceID set to its original start. Times are written in UTC, or as
+// local times with a TZID when Zone is set, which lets calendar applications
+// keep the local hour of recurring events across DST changes.
+type Event struct {
+	UID          string
+	Summary      string
+	Description  string
+	Location     string
+	Start        time.Time
+	End          *time.Time
+	Rule         string
+	ExDates      []time.Time
+	RecurrenceID *time.Time
+	Cancelled    bool
+	Updated      time.Time
+	Zone         *time.Location
+}
+
+// Calendar writes a VCALENDAR document
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// WriteTo serializes the calendar following RFC 5545: CRLF line endings,
+// escaped text values and lines folded at 75 octets
+func (c *Calendar) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	line := func(name, value string) {
+		writeFolded(&b, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Beautiful Minds//Club Scientifique//FR")
+	line("CALSCALE", "GREGORIAN")
+	if c.Name != "" {
+		line("X-WR-CALNAME", escape(c.Name))
+	}
+
+	now := recurrence.FormatDate(time.Now())
+	for _, e := range c.Events {
+		line("BEGIN", "VEVENT")
+		line("UID", e.UID)
+		line("DTSTAMP", now)
+		if e.RecurrenceID != nil {
+			line(e.dateTime("RECURRENCE-ID", *e.RecurrenceID))
+		}
+		line(e.dateTime("DTSTART", e.Start))
+		if e.End != nil {
+			line(e.dateTime("DTEND", *e.End))
+		}
+		if e.Rule != "" {
+			line("RRULE", e.Rule)
+		}
+		if len(e.ExDates) > 0 {
+			name, _ := e.dateTime("EXDATE", time.Time{})
+			dates := make([]string, len(e.ExDates))
+			for i, d := range e.ExDates {
+				_, dates[i] = e.dateTime("EXDATE", d)
+			}
+			line(name, strings.Join(dates, ","))
+		}
+		line("SUMMARY", escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION", escape(e.Description))
+		}
+		if e.Location != "" {
+			line("LOCATION", escape(e.Location))
+		}
+		if e.Cancelled {
+			line("STATUS", "CANCELLED")
+		}
+		if !e.Updated.IsZero() {
+			line("LAST-MODIFIED", recurrence.FormatDate(e.Updated))
+		}
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// localLayout is the floating date-time format used along with a TZID
+const localLayout = "20060102T150405"
+
+// dateTime returns the property name, with its TZID parameter when the
+// event has a zone, and the formatted time
+func (e *Event) dateTime(name string, t time.Time) (string, string) {
+	if e.Zone == nil || e.Zone == time.UTC {
+		return name, recurrence.FormatDate(t)
+	}
+	return name + ";TZID=" + e.Zone.String(), t.In(e.Zone).Format(localLayout)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// writeFolded writes a content line, folding it at 75 octets without
+// splitting UTF-8 sequences
+func writeFolded(b *strings.Builder, s string) {
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	b.WriteString("\r\n")
+}
+
+// UID builds a stable identifier for an event of the given host
+func UID(eventID int, host string) string {
+	return fmt.Sprintf("event-%d@%s", eventID, host)
+}
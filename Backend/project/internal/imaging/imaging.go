@@ -0,0 +1,140 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	// ErrUnsupported is returned for content that is not a JPEG, PNG or GIF
+	// image, whatever its declared type
+	ErrUnsupported = errors.New("format d'image non pris en charge (JPEG, PNG ou GIF)")
+	// ErrTooLarge is returned for images with too many pixels to decode
+	ErrTooLarge = errors.New("dimensions de l'image trop grandes")
+)
+
+// formats maps the sniffed content types to the file extension used in
+// storage keys
+var formats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Image is a checked upload and its thumbnail
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	// Thumbnail fits in the requested size; JPEG for JPEG photos, PNG
+	// otherwise to keep transparency
+	Thumbnail     []byte
+	ThumbnailType string
+	ThumbnailExt  string
+}
+
+// Process sniffs the content type of data, checks its dimensions before
+// decoding it and renders a thumbnail no wider nor higher than thumbSize
+func Process(data []byte, maxPixels, thumbSize int) (*Image, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := formats[contentType]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrUnsupported
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > int64(maxPixels) {
+		return nil, fmt.Errorf("%w (%d×%d)", ErrTooLarge, cfg.Width, cfg.Height)
+	}
+
+	src, err := decode(contentType, data)
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	img := &Image{ContentType: contentType, Ext: ext, Width: cfg.Width, Height: cfg.Height}
+	thumb := Thumbnail(src, thumbSize)
+
+	var buf bytes.Buffer
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		img.ThumbnailType, img.ThumbnailExt = "image/jpeg", ".jpg"
+	} else {
+		err = png.Encode(&buf, thumb)
+		img.ThumbnailType, img.ThumbnailExt = "image/png", ".png"
+	}
+	if err != nil {
+		return nil, err
+	}
+	img.Thumbnail = buf.Bytes()
+	return img, nil
+}
+
+// decode uses the decoder of the sniffed type rather than the registered
+// format matching the content
+func decode(contentType string, data []byte) (image.Image, error) {
+	r := bytes.NewReader(data)
+	switch contentType {
+	case "image/jpeg":
+		return jpeg.Decode(r)
+	case "image/png":
+		return png.Decode(r)
+	default:
+		// Première image des GIF animés
+		return gif.Decode(r)
+	}
+}
+
+// Thumbnail scales src down to fit in size×size, keeping its proportions.
+// Each pixel averages the source pixels it covers; smaller images keep their
+// size.
+func Thumbnail(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	tw, th := w, h
+	if w > size || h > size {
+		if w >= h {
+			tw, th = size, max(1, h*size/w)
+		} else {
+			tw, th = max(1, w*size/h), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,119 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encode(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		format        string
+		contentType   string
+		ext           string
+		thumbnailType string
+	}{
+		{"jpeg", "image/jpeg", ".jpg", "image/jpeg"},
+		{"png", "image/png", ".png", "image/png"},
+		{"gif", "image/gif", ".gif", "image/png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			img, err := Process(encode(t, tt.format, 200, 100), 1_000_000, 50)
+			if err != nil {
+				t.Fatalf("Process: %v", err)
+			}
+			if img.ContentType != tt.contentType || img.Ext != tt.ext {
+				t.Errorf("type %s%s, want %s%s", img.ContentType, img.Ext, tt.contentType, tt.ext)
+			}
+			if img.Width != 200 || img.Height != 100 {
+				t.Errorf("dimensions %d×%d, want 200×100", img.Width, img.Height)
+			}
+			if img.ThumbnailType != tt.thumbnailType {
+				t.Errorf("thumbnail type %s, want %s", img.ThumbnailType, tt.thumbnailType)
+			}
+
+			thumb, _, err := image.DecodeConfig(bytes.NewReader(img.Thumbnail))
+			if err != nil {
+				t.Fatalf("decoding the thumbnail: %v", err)
+			}
+			if thumb.Width != 50 || thumb.Height != 25 {
+				t.Errorf("thumbnail %d×%d, want 50×25", thumb.Width, thumb.Height)
+			}
+		})
+	}
+}
+
+func TestProcessSniffsContent(t *testing.T) {
+	// The declared type plays no part: only the bytes are looked at
+	for name, data := range map[string][]byte{
+		"text":          []byte("<html><body>not an image</body></html>"),
+		"svg":           []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`),
+		"truncated png": encode(t, "png", 20, 20)[:40],
+		"empty":         nil,
+	} {
+		if _, err := Process(data, 1_000_000, 50); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: got %v, want ErrUnsupported", name, err)
+		}
+	}
+}
+
+func TestProcessPixelLimit(t *testing.T) {
+	data := encode(t, "png", 100, 100)
+
+	if _, err := Process(data, 9_999, 50); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("over the limit: got %v, want ErrTooLarge", err)
+	}
+	if _, err := Process(data, 10_000, 50); err != nil {
+		t.Errorf("at the limit: %v", err)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		w, h, size   int
+		wantW, wantH int
+	}{
+		{400, 200, 100, 100, 50},
+		{200, 400, 100, 50, 100},
+		{1000, 1, 100, 100, 1},
+		// Smaller images keep their size
+		{60, 40, 100, 60, 40},
+	}
+	for _, tt := range tests {
+		got := Thumbnail(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), tt.size).Bounds()
+		if got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+			t.Errorf("%d×%d in %d: got %d×%d, want %d×%d", tt.w, tt.h, tt.size, got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"beautiful-minds/backend/project/internal/mailer"
+	"beautiful-minds/backend/project/internal/markdown"
+	"beautiful-minds/backend/project/internal/models"
+)
+
+// AnnouncementClaimer returns the published announcements whose audience
+// is still to be notified, marking them as notified
+type AnnouncementClaimer interface {
+	ClaimNotifications() ([]models.Announcement, error)
+}
+
+// AudienceLister lists the members an announcement is meant for
+type AudienceLister interface {
+	GetAudience(announcementID int) ([]models.Member, error)
+}
+
+// StartAnnouncementNotifications emails every interval the announcements
+// published with notify to their audience, until ctx is cancelled.
+// Scheduled announcements go out on the first run after their publication.
+func StartAnnouncementNotifications(ctx context.Context, interval time.Duration,
+	announcements AnnouncementClaimer, members AudienceLister, m mailer.Mailer) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			notifyAnnouncements(announcements, members, m)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func notifyAnnouncements(announcements AnnouncementClaimer, members AudienceLister, m mailer.Mailer) {
+	claimed, err := announcements.ClaimNotifications()
+	if err != nil {
+		slog.Error("Lecture des annonces à notifier échouée", "error", err)
+		return
+	}
+
+	for _, a := range claimed {
+		audience, err := members.GetAudience(a.ID)
+		if err != nil {
+			slog.Error("Lecture du public de l'annonce échouée", "error", err, "announcement_id", a.ID)
+			continue
+		}
+
+		// The emails are plain text: the Markdown is sent without its markup
+		content := markdown.Text(markdown.Render(a.Content))
+		sent := 0
+		for _, member := range audience {
+			body := fmt.Sprintf("Bonjour %s,\n\n%s\n\n%s\n", member.FirstName, a.Title, content)
+			if err := m.Send(member.Email, "Annonce : "+a.Title, body); err != nil {
+				slog.Error("Envoi de l'annonce échoué", "error", err, "announcement_id", a.ID, "member_id", member.ID)
+				continue
+			}
+			sent++
+		}
+		slog.Info("Annonce envoyée à son public", "announcement_id", a.ID, "recipients", len(audience), "sent", sent)
+	}
+}
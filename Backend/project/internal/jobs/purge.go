@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Purger permanently deletes rows trashed for longer than a retention,
+// measured against the database clock that wrote their deleted_at
+type Purger interface {
+	Purge(retention time.Duration) (int64, error)
+}
+
+// StartTrashPurge runs a purge every interval, deleting items that have been
+// in the trash for longer than retention, until ctx is cancelled
+func StartTrashPurge(ctx context.Context, interval, retention time.Duration, purgers map[string]Purger) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purgeTrash(retention, purgers)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func purgeTrash(retention time.Duration, purgers map[string]Purger) {
+	for name, p := range purgers {
+		n, err := p.Purge(retention)
+		if err != nil {
+			slog.Error("Purge de la corbeille échouée", "table", name, "error", err)
+			continue
+		}
+		if n > 0 {
+			slog.Info("Corbeille purgée", "table", name, "deleted", n)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"beautiful-minds/backend/project/config"
+)
+
+// Mailer sends plain-text emails
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// New returns an SMTP mailer, or a mailer that only logs messages when no
+// SMTP host is configured (development)
+func New(cfg *config.SMTPConfig) Mailer {
+	if cfg.Host == "" {
+		return logMailer{}
+	}
+	return &smtpMailer{cfg: cfg}
+}
+
+type smtpMailer struct {
+	cfg *config.SMTPConfig
+}
+
+func (m *smtpMailer) Send(to, subject, body string) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	return smtp.SendMail(addr, auth, m.cfg.From, []string{to}, buildMessage(m.cfg.From, to, subject, body))
+}
+
+type logMailer struct{}
+
+func (logMailer) Send(to, subject, body string) error {
+	slog.Info("Email non envoyé (SMTP non configuré)", "to", to, "subject", subject, "body", body)
+	return nil
+}
+
+func buildMessage(from, to, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,71 @@
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// ExcerptLength is the length, in characters, of the excerpts shown in
+// listings
+const ExcerptLength = 200
+
+// Hard wraps keep the line breaks of content written as plain text before
+// Markdown was supported. Raw HTML in the source is dropped by goldmark and
+// the output goes through an allowlist anyway.
+var (
+	renderer = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(gmhtml.WithHardWraps()),
+	)
+	policy = bluemonday.UGCPolicy().
+		RequireNoFollowOnLinks(true).
+		AddTargetBlankToFullyQualifiedLinks(true)
+	textPolicy = bluemonday.StrictPolicy()
+)
+
+// Render converts Markdown into sanitized HTML
+func Render(source string) string {
+	if strings.TrimSpace(source) == "" {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		// Le rendu ne peut échouer qu'en écrivant dans buf : repli sur le texte échappé
+		return "<p>" + html.EscapeString(source) + "</p>"
+	}
+	return policy.Sanitize(buf.String())
+}
+
+// Text returns the text of HTML produced by Render, without markup and with
+// whitespace collapsed
+func Text(rendered string) string {
+	text := html.UnescapeString(textPolicy.Sanitize(rendered))
+	return strings.Join(strings.FieldsFunc(text, unicode.IsSpace), " ")
+}
+
+// Excerpt returns the beginning of the text of HTML produced by Render, cut
+// on a word boundary to at most length characters plus an ellipsis
+func Excerpt(rendered string, length int) string {
+	text := Text(rendered)
+
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	cut := string(runes[:length])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRightFunc(cut, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	}) + "…"
+}
@@ -0,0 +1,69 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, source string
+		contains     []string
+		excludes     []string
+	}{
+		{"empty", "  \n", nil, []string{"<p>"}},
+		{"emphasis", "**gras** et _italique_", []string{"<strong>gras</strong>", "<em>italique</em>"}, nil},
+		{"hard wraps", "ligne 1\nligne 2", []string{"ligne 1<br>"}, nil},
+		{"raw html", "<script>alert(1)</script><b onclick=\"x()\">b</b>", nil, []string{"<script", "onclick", "alert(1)"}},
+		{"javascript link", "[lien](javascript:alert(1))", nil, []string{"javascript:"}},
+		{"external link", "[site](https://example.org)", []string{`href="https://example.org"`, `rel="nofollow noopener"`, `target="_blank"`}, nil},
+		{"table", "| a | b |\n|---|---|\n| 1 | 2 |", []string{"<table>", "<td>1</td>"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Render(tt.source)
+			if tt.name == "empty" && got != "" {
+				t.Errorf("Render = %q, want empty", got)
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("Render = %q, missing %q", got, s)
+				}
+			}
+			for _, s := range tt.excludes {
+				if strings.Contains(got, s) {
+					t.Errorf("Render = %q, contains %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	got := Text(Render("# Titre\n\nUn **paragraphe** & un [lien](https://example.org).\n\n- un\n- deux"))
+	want := "Titre Un paragraphe & un lien. un deux"
+	if got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		source string
+		length int
+		want   string
+	}{
+		{"", 10, ""},
+		{"Court", 10, "Court"},
+		{"Juste **dix**", 10, "Juste dix"},
+		{"Une phrase un peu plus longue", 12, "Une phrase…"},
+		{"Bonjour, tout le monde", 10, "Bonjour…"},
+		{"Anticonstitutionnellement", 10, "Anticonsti…"},
+		{"Été à l'opéra", 6, "Été à…"},
+	}
+	for _, tt := range tests {
+		if got := Excerpt(Render(tt.source), tt.length); got != tt.want {
+			t.Errorf("Excerpt(%q, %d) = %q, want %q", tt.source, tt.length, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "club_scientific"
+
+var (
+	// HTTPRequestsTotal counts handled requests per route template
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Nombre de requêtes HTTP traitées.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration tracks request latency per route template
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Durée des requêtes HTTP.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// DBQueryDuration tracks repository query latency
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Durée des requêtes SQL exécutées par les repositories.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"repository", "operation"})
+
+	// EventRegistrations counts successful registrations by kind (member or
+	// guest); per-event counts come from the database, a label per event
+	// would grow without bound
+	EventRegistrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "event_registrations_total",
+		Help:      "Nombre d'inscriptions aux événements.",
+	}, []string{"kind"})
+
+	// MembersCreated counts new members; use increase(...[1d]) for a daily figure
+	MembersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "members_created_total",
+		Help:      "Nombre de nouveaux membres inscrits.",
+	})
+
+	// ValidationFailures counts rejected payloads per entity and reason
+	ValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "validation_failures_total",
+		Help:      "Nombre de requêtes rejetées pour données invalides.",
+	}, []string{"entity", "reason"})
+
+	// RateLimited counts requests refused by the rate limiter per route
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Nombre de requêtes refusées par la limitation de débit.",
+	}, []string{"method", "route"})
+
+	// SpamRejected counts form submissions refused by the anti-spam checks
+	SpamRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "spam_rejected_total",
+		Help:      "Nombre de soumissions de formulaire rejetées comme spam.",
+	}, []string{"reason"})
+)
+
+// RegisterDB exposes the sql.DBStats of the connection pool
+func RegisterDB(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// TrackQuery starts a timer for a repository query; call the returned func when done
+func TrackQuery(repository, operation string) func() {
+	start := time.Now()
+	return func() {
+		DBQueryDuration.WithLabelValues(repository, operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Registration kinds of the EventRegistrations counter
+const (
+	RegistrationMember = "member"
+	RegistrationGuest  = "guest"
+)
+
+// RecordRegistration increments the registration counter of a kind
+func RecordRegistration(kind string) {
+	EventRegistrations.WithLabelValues(kind).Inc()
+}
+
+// RecordValidationFailure increments the validation failure counter
+func RecordValidationFailure(entity, reason string) {
+	ValidationFailures.WithLabelValues(entity, reason).Inc()
+}
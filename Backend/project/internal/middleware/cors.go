@@ -1,18 +1,169 @@
 package middleware
 
-import "net/http"
+import (
+	"net/http"
+	"strconv"
+	"strings"
 
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	"beautiful-minds/backend/project/config"
+)
+
+// corsPolicy is the compiled form of the settings in config.CORSConfig
+type corsPolicy struct {
+	origins        []string
+	allowAll       bool
+	methods        []string
+	headers        []string
+	allowAnyHeader bool
+	exposed        string
+	credentials    bool
+	maxAge         string
+}
+
+// CORS builds a middleware enforcing the configured CORS policy. It must wrap
+// the router itself so that preflight requests are answered before routing.
+func CORS(cfg *config.Config) func(http.Handler) http.Handler {
+	p := &corsPolicy{
+		methods:     upperAll(cfg.CORS.AllowedMethods),
+		exposed:     strings.Join(cfg.CORS.ExposedHeaders, ", "),
+		credentials: cfg.CORS.AllowCredentials,
+	}
+	for _, o := range cfg.CORS.AllowedOrigins {
+		if o == "*" {
+			p.allowAll = true
+			continue
+		}
+		p.origins = append(p.origins, strings.ToLower(strings.TrimSuffix(o, "/")))
+	}
+	for _, h := range cfg.CORS.AllowedHeaders {
+		if h == "*" {
+			p.allowAnyHeader = true
+			continue
+		}
+		p.headers = append(p.headers, http.CanonicalHeaderKey(h))
+	}
+	if cfg.CORS.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(cfg.CORS.MaxAge)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			w.Header().Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if preflight {
+				p.handlePreflight(w, r, origin)
+				return
+			}
+
+			if origin != "" && p.allowOrigin(origin) {
+				p.setOriginHeaders(w, origin)
+				if p.exposed != "" {
+					w.Header().Set("Access-Control-Expose-Headers", p.exposed)
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (p *corsPolicy) handlePreflight(w http.ResponseWriter, r *http.Request, origin string) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if origin == "" || !p.allowOrigin(origin) {
+		http.Error(w, "Origine non autorisée", http.StatusForbidden)
+		return
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !contains(p.methods, method) {
+		http.Error(w, "Méthode non autorisée", http.StatusForbidden)
+		return
+	}
+
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	if !p.allowHeaders(requested) {
+		http.Error(w, "En-têtes non autorisés", http.StatusForbidden)
+		return
+	}
+
+	p.setOriginHeaders(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(p.methods, ", "))
+	if p.allowAnyHeader && requested != "" {
+		w.Header().Set("Access-Control-Allow-Headers", requested)
+	} else if len(p.headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(p.headers, ", "))
+	}
+	if p.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", p.maxAge)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *corsPolicy) setOriginHeaders(w http.ResponseWriter, origin string) {
+	// A literal "*" is not allowed together with credentials
+	if p.allowAll && !p.credentials {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+// allowOrigin matches exact origins and wildcard subdomain patterns such as
+// https://*.example.com
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.origins {
+		if allowed == origin {
+			return true
 		}
+		if i := strings.Index(allowed, "://*."); i >= 0 {
+			scheme, suffix := allowed[:i+3], allowed[i+4:]
+			if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, suffix) &&
+				len(origin) > len(scheme)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *corsPolicy) allowHeaders(requested string) bool {
+	if requested == "" || p.allowAnyHeader {
+		return true
+	}
+	for _, h := range strings.Split(requested, ",") {
+		h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+		if h != "" && !contains(p.headers, h) {
+			return false
+		}
+	}
+	return true
+}
 
-		next.ServeHTTP(w, r)
-	})
-}
\ No newline at end of file
+func upperAll(values []string) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToUpper(v)
+	}
+	return out
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Deprecated marks responses of a legacy route prefix with the Deprecation,
+// Sunset and successor Link headers pointing at the replacement prefix.
+func Deprecated(legacyPrefix, successorPrefix string, sunset time.Time) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+
+			successor := successorPrefix + strings.TrimPrefix(r.URL.Path, legacyPrefix)
+			w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
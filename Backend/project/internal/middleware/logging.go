@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"beautiful-minds/backend/project/internal/logging"
+)
+
+// RequestIDHeader is the header used to propagate request IDs
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID propagates the incoming X-Request-ID or generates a new one
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// AccessLog writes one structured log line per request
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		logging.FromContext(r.Context()).Log(r.Context(), level, "requête HTTP",
+			"method", r.Method,
+			"route", routeTemplate(r),
+			"path", r.URL.Path,
+			"status", rec.status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"bytes", rec.bytes,
+			"client_ip", ClientIP(r),
+		)
+	})
+}
+
+type clientIPKey struct{}
+
+// ClientAddress resolves the client IP of each request for ClientIP. Behind
+// a reverse proxy, header names the header in which the proxy passes the
+// client address (X-Real-IP, or X-Forwarded-For whose last entry is the one
+// the proxy appended). Clients can send that header too, so it is only read
+// when configured; an empty header keeps the remote address.
+func ClientAddress(header string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := remoteIP(r)
+			if header != "" {
+				if forwarded := forwardedIP(r.Header.Values(header)); forwarded != "" {
+					ip = forwarded
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// ClientIP returns the client address resolved by ClientAddress, or the
+// remote address of the request without its port
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// forwardedIP returns the last address of a proxy header, "" when it is
+// missing or not an IP
+func forwardedIP(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	entries := strings.Split(values[len(values)-1], ",")
+	ip := net.ParseIP(strings.TrimSpace(entries[len(entries)-1]))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts short, printable IDs from upstream proxies
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
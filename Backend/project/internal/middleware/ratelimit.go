@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"beautiful-minds/backend/project/config"
+	"beautiful-minds/backend/project/internal/metrics"
+)
+
+// bucket is a token bucket refilled continuously at rate tokens per second
+type bucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func newBucket(requests int, period time.Duration, now time.Time) *bucket {
+	return &bucket{
+		tokens:   float64(requests),
+		capacity: float64(requests),
+		rate:     float64(requests) / period.Seconds(),
+		last:     now,
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// wait is how long until the bucket holds a whole token again
+func (b *bucket) wait() time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	global    config.RouteLimit
+	routes    map[string]config.RouteLimit
+	lastSweep time.Time
+}
+
+// RateLimit throttles API calls per client IP with token buckets: a default
+// budget shared by every route plus the stricter per-route budgets of the
+// configuration. A per-route budget is shared by every path the route
+// matches, so spreading calls over event IDs does not get around it.
+// Refused calls get a 429 with Retry-After. It must be used on a mux router
+// so the route template is known; /api and /api/v1 share their budgets.
+func RateLimit(cfg *config.RateLimitConfig) func(http.Handler) http.Handler {
+	if !cfg.Enabled {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	// Already checked by config.Validate
+	limits, _ := cfg.RouteLimits()
+	l := &rateLimiter{
+		buckets:   make(map[string]*bucket),
+		global:    config.RouteLimit{Requests: cfg.Requests, Period: cfg.Period},
+		routes:    make(map[string]config.RouteLimit),
+		lastSweep: time.Now(),
+	}
+	for _, limit := range limits {
+		l.routes[limit.Method+" "+limit.Route] = limit
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			route := unversioned(routeTemplate(r))
+			if wait := l.take(ClientIP(r), r.Method+" "+route); wait > 0 {
+				metrics.RateLimited.WithLabelValues(r.Method, route).Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Trop de requêtes, réessayez plus tard", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// take consumes a token from the global bucket of the client and, when route
+// has a budget, from its bucket for the route template, shared by every ID
+// it matches, or returns how long to wait when one of them is empty
+func (l *rateLimiter) take(ip, route string) time.Duration {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	buckets := []*bucket{l.bucket(ip, l.global, now)}
+	if limit, ok := l.routes[route]; ok {
+		buckets = append(buckets, l.bucket(ip+" "+route, limit, now))
+	}
+
+	var wait time.Duration
+	for _, b := range buckets {
+		b.refill(now)
+		wait = max(wait, b.wait())
+	}
+	if wait > 0 {
+		return wait
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0
+}
+
+func (l *rateLimiter) bucket(key string, limit config.RouteLimit, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newBucket(limit.Requests, limit.Period, now)
+		l.buckets[key] = b
+	}
+	return b
+}
+
+// sweep drops the buckets that have refilled completely, once a minute, so
+// memory does not grow with every client ever seen
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.capacity {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// unversioned strips the API prefix from a route template
+func unversioned(route string) string {
+	for _, prefix := range []string{"/api/v1", "/api"} {
+		if rest, ok := strings.CutPrefix(route, prefix); ok && strings.HasPrefix(rest, "/") {
+			return rest
+		}
+	}
+	return route
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Role is the access level of the caller
+type Role string
+
+const (
+	RoleAnonymous Role = "anonymous"
+	RoleAdmin     Role = "admin"
+)
+
+type roleKey struct{}
+
+// Roles resolves the caller's role from the Authorization header. A bearer
+// token equal to the configured admin token grants RoleAdmin; anything else
+// is anonymous. An empty admin token disables the admin role.
+func Roles(adminToken string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role := RoleAnonymous
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if ok && adminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+				role = RoleAdmin
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, role)))
+		})
+	}
+}
+
+// RoleFrom returns the role resolved by Roles, anonymous by default
+func RoleFrom(ctx context.Context) Role {
+	if role, ok := ctx.Value(roleKey{}).(Role); ok {
+		return role
+	}
+	return RoleAnonymous
+}
+
+// HasRole reports whether the request was made with one of the given roles
+func HasRole(r *http.Request, roles ...Role) bool {
+	current := RoleFrom(r.Context())
+	for _, role := range roles {
+		if current == role {
+			return true
+		}
+	}
+	return false
+}
@@ -1,18 +1,184 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"beautiful-minds/backend/project/internal/markdown"
+)
+
+// Announcement statuses, derived from the draft flag and the publication
+// and expiry dates
+const (
+	AnnouncementDraft     = "draft"
+	AnnouncementScheduled = "scheduled"
+	AnnouncementPublished = "published"
+	AnnouncementExpired   = "expired"
+)
 
 type Announcement struct {
-	ID            int       `json:"id"`
-	Title         string    `json:"title"`
-	Content       string    `json:"content"`
-	PublishedDate time.Time `json:"published_date"`
-	IsPinned      bool      `json:"is_pinned"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID            int        `json:"id"`
+	Title         string     `json:"title"`
+	Content       string     `json:"content"`
+	PublishedDate time.Time  `json:"published_date"`
+	IsPinned      bool       `json:"is_pinned"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Version       int        `json:"version"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	// Status follows from Draft, PublishedDate (a future one schedules the
+	// announcement) and ExpiresAt
+	Draft     bool       `json:"draft"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Status    string     `json:"status"`
+	// Notify emails the audience once the announcement is published
+	Audience   Audience   `json:"audience"`
+	Notify     bool       `json:"notify"`
+	NotifiedAt *time.Time `json:"notified_at"`
+}
+
+// Audience narrows the members an announcement is meant for: those of one
+// of the fields of study, with one of the roles or registered to one of the
+// events. An empty audience is everyone.
+type Audience struct {
+	FieldsOfStudy []string `json:"fields_of_study"`
+	Roles         []string `json:"roles"`
+	EventIDs      []int    `json:"event_ids"`
+}
+
+// IsEveryone reports whether the audience sets no criterion
+func (a *Audience) IsEveryone() bool {
+	return len(a.FieldsOfStudy) == 0 && len(a.Roles) == 0 && len(a.EventIDs) == 0
+}
+
+// Normalize trims and deduplicates the criteria (fields of study ignoring
+// case, roles lowercased) and checks their number and length
+func (a *Audience) Normalize() error {
+	fields := []string{}
+	seen := map[string]bool{}
+	for _, field := range a.FieldsOfStudy {
+		field = strings.Join(strings.Fields(field), " ")
+		key := strings.ToLower(field)
+		if field == "" || seen[key] {
+			continue
+		}
+		if len(field) > 100 {
+			return fmt.Errorf("filière trop longue (max 100 caractères): %q", field)
+		}
+		seen[key] = true
+		fields = append(fields, field)
+	}
+
+	roles, err := NormalizeRoles(a.Roles)
+	if err != nil {
+		return err
+	}
+
+	events := []int{}
+	seenEvents := map[int]bool{}
+	for _, id := range a.EventIDs {
+		if id <= 0 {
+			return fmt.Errorf("event_ids invalide: %d", id)
+		}
+		if !seenEvents[id] {
+			seenEvents[id] = true
+			events = append(events, id)
+		}
+	}
+
+	if len(fields) > 50 || len(roles) > 50 || len(events) > 50 {
+		return fmt.Errorf("public trop large (max 50 filières, rôles ou événements)")
+	}
+
+	a.FieldsOfStudy, a.Roles, a.EventIDs = fields, roles, events
+	return nil
+}
+
+// IsPublic reports whether the announcement shows in public listings
+func (a *Announcement) IsPublic() bool {
+	return a.Status == AnnouncementPublished
+}
+
+// MarshalJSON adds the content (Markdown) rendered and sanitized, and its
+// beginning as plain text for listings
+func (a Announcement) MarshalJSON() ([]byte, error) {
+	type announcement Announcement
+	content := markdown.Render(a.Content)
+	return json.Marshal(struct {
+		announcement
+		ContentHTML string `json:"content_html"`
+		Excerpt     string `json:"excerpt"`
+	}{
+		announcement: announcement(a),
+		ContentHTML:  content,
+		Excerpt:      markdown.Excerpt(content, markdown.ExcerptLength),
+	})
+}
+
+// AsRequest returns the request that puts an announcement back in this
+// state, publication date included; used to roll back to a revision
+func (a *Announcement) AsRequest() CreateAnnouncementRequest {
+	published := a.PublishedDate
+	return CreateAnnouncementRequest{
+		Title:     a.Title,
+		Content:   a.Content,
+		IsPinned:  a.IsPinned,
+		Draft:     a.Draft,
+		PublishAt: &published,
+		ExpiresAt: a.ExpiresAt,
+		Audience:  a.Audience,
+		Notify:    a.Notify,
+	}
 }
 
 type CreateAnnouncementRequest struct {
 	Title    string `json:"title"`
 	Content  string `json:"content"`
 	IsPinned bool   `json:"is_pinned"`
-}
\ No newline at end of file
+	// Draft keeps the announcement unpublished. PublishAt schedules it;
+	// omitted, it is published now, or keeps its date on update.
+	Draft     bool       `json:"draft"`
+	PublishAt *time.Time `json:"publish_at"`
+	// ExpiresAt removes the announcement from public listings
+	ExpiresAt *time.Time `json:"expires_at"`
+	// Audience restricts who sees the announcement; Notify emails them
+	// once it is published
+	Audience Audience `json:"audience"`
+	Notify   bool     `json:"notify"`
+}
+
+// Validate checks the title, the publication window and the audience
+func (r *CreateAnnouncementRequest) Validate() error {
+	r.Title = strings.TrimSpace(r.Title)
+	if r.Title == "" {
+		return fmt.Errorf("titre est obligatoire")
+	}
+	if len(r.Title) > 200 {
+		return fmt.Errorf("titre trop long (max 200 caractères)")
+	}
+
+	if r.PublishAt != nil {
+		publish := r.PublishAt.UTC()
+		r.PublishAt = &publish
+	}
+	if r.ExpiresAt != nil {
+		expires := r.ExpiresAt.UTC()
+		r.ExpiresAt = &expires
+		if r.PublishAt != nil && !expires.After(*r.PublishAt) {
+			return fmt.Errorf("expires_at doit être postérieur à publish_at")
+		}
+	}
+	return r.Audience.Normalize()
+}
+
+// ValidAnnouncementStatus reports whether status filters the admin listing:
+// one of the statuses, or "all"
+func ValidAnnouncementStatus(status string) bool {
+	switch status {
+	case "all", AnnouncementDraft, AnnouncementScheduled, AnnouncementPublished, AnnouncementExpired:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type Category struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Slug        string    `json:"slug"`
+	Description string    `json:"description"`
+	Color       string    `json:"color"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CategoryCount is a category with the number of upcoming events in it
+type CategoryCount struct {
+	Category
+	EventCount int `json:"event_count"`
+}
+
+// TagCount is a tag with the number of upcoming events carrying it
+type TagCount struct {
+	Name       string `json:"name"`
+	EventCount int    `json:"event_count"`
+}
+
+type CreateCategoryRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	// Slug is derived from Name by Validate
+	Slug string `json:"-"`
+}
+
+var colorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Validate checks the category and derives its slug
+func (r *CreateCategoryRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Description = strings.TrimSpace(r.Description)
+	r.Color = strings.TrimSpace(r.Color)
+
+	if r.Name == "" {
+		return fmt.Errorf("nom est obligatoire")
+	}
+	if len(r.Name) > 100 {
+		return fmt.Errorf("nom trop long (max 100 caractères)")
+	}
+	if r.Color != "" && !colorRegex.MatchString(r.Color) {
+		return fmt.Errorf("couleur invalide (format #RRGGBB)")
+	}
+
+	r.Slug = Slugify(r.Name)
+	if r.Slug == "" {
+		return fmt.Errorf("le nom doit contenir au moins une lettre ou un chiffre")
+	}
+
+	return nil
+}
+
+var accents = strings.NewReplacer(
+	"à", "a", "â", "a", "ä", "a", "á", "a", "ç", "c",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"î", "i", "ï", "i", "í", "i", "ô", "o", "ö", "o", "ó", "o",
+	"ù", "u", "û", "u", "ü", "u", "ú", "u", "ÿ", "y", "ñ", "n",
+	"œ", "oe", "æ", "ae",
+)
+
+// Slugify turns a name into a lowercase ASCII identifier for URLs,
+// e.g. "Soirée d'astronomie" becomes "soiree-d-astronomie"
+func Slugify(name string) string {
+	s := accents.Replace(strings.ToLower(name))
+
+	var b strings.Builder
+	dash := false
+	for _, c := range s {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// NormalizeTag lowercases a tag and collapses its whitespace
+func NormalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), " ")
+}
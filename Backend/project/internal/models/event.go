@@ -1,27 +1,298 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"beautiful-minds/backend/project/internal/markdown"
+	"beautiful-minds/backend/project/internal/recurrence"
+)
 
 type Event struct {
-	ID              int       `json:"id"`
-	Title           string    `json:"title"`
-	Description     string    `json:"description"`
-	Date            time.Time `json:"date"`
-	Location        string    `json:"location"`
-	ImageURL        *string   `json:"image_url"`
-	MaxParticipants int       `json:"max_participants"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Date and EndDate are stored in UTC; Timezone (IANA) is the one the
+	// event is scheduled in and rendered with
+	Date     time.Time `json:"date"`
+	EndDate  time.Time `json:"end_date"`
+	Timezone string    `json:"timezone"`
+	Location string    `json:"location"`
+	// VenueID is the booked room; Location then holds its name
+	VenueID         *int       `json:"venue_id"`
+	Venue           *Venue     `json:"venue,omitempty"`
+	ImageURL        *string    `json:"image_url"`
+	MaxParticipants int        `json:"max_participants"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Version         int        `json:"version"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	Categories      []Category `json:"categories"`
+	Tags            []string   `json:"tags"`
+	// ImageID is the uploaded image shown by the event, whose URL is then
+	// ImageURL; Image adds its thumbnail and dimensions
+	ImageID *int   `json:"image_id"`
+	Image   *Media `json:"image,omitempty"`
+	// GuestCapacity is the number of seats open to non-members on top of
+	// MaxParticipants; 0 closes the event to guests
+	GuestCapacity int `json:"guest_capacity"`
+	// RecurrenceRule makes the event a series whose first occurrence is
+	// Date, e.g. "FREQ=WEEKLY;INTERVAL=2;COUNT=10"
+	RecurrenceRule *string     `json:"recurrence_rule"`
+	ExceptionDates []time.Time `json:"exception_dates"`
+	// OccurrenceStart identifies the occurrence in expanded listings
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty"`
+	// Warnings flags accepted but questionable values, such as more
+	// participants than the venue holds
+	Warnings []string `json:"warnings,omitempty"`
+	// AverageRating is the mean of the ratings given in the event's
+	// feedback survey, RatingCount their number
+	AverageRating *float64 `json:"average_rating,omitempty"`
+	RatingCount   int      `json:"rating_count,omitempty"`
+}
+
+// MarshalJSON adds the start and end rendered in the event's timezone, the
+// description (Markdown) rendered and sanitized, and its beginning as plain
+// text for listings
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	zone := e.Zone()
+	description := markdown.Render(e.Description)
+	return json.Marshal(struct {
+		event
+		DescriptionHTML string `json:"description_html"`
+		Excerpt         string `json:"excerpt"`
+		LocalStart      string `json:"local_start"`
+		LocalEnd        string `json:"local_end"`
+	}{
+		event:           event(e),
+		DescriptionHTML: description,
+		Excerpt:         markdown.Excerpt(description, markdown.ExcerptLength),
+		LocalStart:      e.Date.In(zone).Format(time.RFC3339),
+		LocalEnd:        e.EndDate.In(zone).Format(time.RFC3339),
+	})
+}
+
+// Zone returns the event's timezone, UTC when it is unknown
+func (e *Event) Zone() *time.Location {
+	if zone, err := time.LoadLocation(e.Timezone); err == nil {
+		return zone
+	}
+	return time.UTC
+}
+
+// AsRequest returns the request that puts an event back in this state,
+// classification included; used to roll back to a revision
+func (e *Event) AsRequest() CreateEventRequest {
+	categoryIDs := make([]int, len(e.Categories))
+	for i, c := range e.Categories {
+		categoryIDs[i] = c.ID
+	}
+	tags := append([]string{}, e.Tags...)
+
+	return CreateEventRequest{
+		Title:           e.Title,
+		Description:     e.Description,
+		Date:            e.Date.Format(time.RFC3339),
+		EndDate:         e.EndDate.Format(time.RFC3339),
+		Timezone:        e.Timezone,
+		Location:        e.Location,
+		VenueID:         e.VenueID,
+		ImageURL:        e.ImageURL,
+		MaxParticipants: e.MaxParticipants,
+		GuestCapacity:   e.GuestCapacity,
+		CategoryIDs:     &categoryIDs,
+		Tags:            &tags,
+		RecurrenceRule:  e.RecurrenceRule,
+		ExceptionDates:  append([]time.Time{}, e.ExceptionDates...),
+	}
+}
+
+// Duration returns how long each occurrence of the event lasts
+func (e *Event) Duration() time.Duration {
+	return e.EndDate.Sub(e.Date)
+}
+
+// CapacityWarning returns a warning when more participants are allowed than
+// the venue holds
+func (e *Event) CapacityWarning() string {
+	if e.Venue == nil || e.MaxParticipants <= e.Venue.Capacity {
+		return ""
+	}
+	return fmt.Sprintf("max_participants (%d) dépasse la capacité de la salle %s (%d places)",
+		e.MaxParticipants, e.Venue.Name, e.Venue.Capacity)
+}
+
+// EventDefaults completes the requests that omit the timezone or the end
+type EventDefaults struct {
+	Timezone string
+	Duration time.Duration
+}
+
+// localLayouts are the accepted date-time formats without UTC offset,
+// interpreted in the event's timezone
+var localLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// parseEventTime reads an RFC 3339 date-time, or a local one in zone
+func parseEventTime(value string, zone *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, zone); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date invalide %q (RFC 3339 ou AAAA-MM-JJTHH:MM attendu)", value)
 }
 
 type CreateEventRequest struct {
-	Title           string  `json:"title"`
-	Description     string  `json:"description"`
-	Date            string  `json:"date"`
-	Location        string  `json:"location"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Date and EndDate are RFC 3339 date-times, or local ones
+	// ("2026-11-05T18:30") read in Timezone
+	Date     string `json:"date"`
+	EndDate  string `json:"end_date"`
+	Timezone string `json:"timezone"`
+	Location string `json:"location"`
+	// VenueID books a room; its name replaces Location
+	VenueID         *int    `json:"venue_id"`
 	ImageURL        *string `json:"image_url"`
 	MaxParticipants int     `json:"max_participants"`
+	GuestCapacity   int     `json:"guest_capacity"`
+	// CategoryIDs and Tags replace the event's classification when present
+	// and leave it untouched when omitted
+	CategoryIDs *[]int    `json:"category_ids"`
+	Tags        *[]string `json:"tags"`
+	// RecurrenceRule and ExceptionDates define a series; omitting the rule
+	// makes the event a single one
+	RecurrenceRule *string     `json:"recurrence_rule"`
+	ExceptionDates []time.Time `json:"exception_dates"`
+
+	// Start and End are Date and EndDate resolved in UTC by Validate
+	Start time.Time `json:"-"`
+	End   time.Time `json:"-"`
+}
+
+// Validate resolves the schedule in UTC, normalizes the tags and the
+// recurrence rule and removes duplicate categories
+func (r *CreateEventRequest) Validate(defaults EventDefaults) error {
+	r.Timezone = strings.TrimSpace(r.Timezone)
+	if r.Timezone == "" {
+		r.Timezone = defaults.Timezone
+	}
+	zone, err := time.LoadLocation(r.Timezone)
+	if err != nil || r.Timezone == "Local" {
+		return fmt.Errorf("fuseau horaire inconnu %q (nom IANA attendu, ex. Europe/Paris)", r.Timezone)
+	}
+
+	if strings.TrimSpace(r.Date) == "" {
+		return fmt.Errorf("date est obligatoire")
+	}
+	if r.Start, err = parseEventTime(strings.TrimSpace(r.Date), zone); err != nil {
+		return err
+	}
+	if strings.TrimSpace(r.EndDate) == "" {
+		r.End = r.Start.Add(defaults.Duration)
+	} else if r.End, err = parseEventTime(strings.TrimSpace(r.EndDate), zone); err != nil {
+		return err
+	}
+	if !r.End.After(r.Start) {
+		return fmt.Errorf("la fin de l'événement doit suivre son début")
+	}
+
+	if r.GuestCapacity < 0 {
+		return fmt.Errorf("guest_capacity doit être positive")
+	}
+
+	if r.RecurrenceRule != nil && *r.RecurrenceRule == "" {
+		r.RecurrenceRule = nil
+	}
+	if r.RecurrenceRule != nil {
+		rule, err := recurrence.Parse(*r.RecurrenceRule)
+		if err != nil {
+			return err
+		}
+		canonical := rule.String()
+		r.RecurrenceRule = &canonical
+	} else if len(r.ExceptionDates) > 0 {
+		return fmt.Errorf("exception_dates n'a de sens que pour un événement récurrent")
+	}
+	for i, d := range r.ExceptionDates {
+		r.ExceptionDates[i] = d.UTC()
+	}
+
+	if r.CategoryIDs != nil {
+		seen := make(map[int]bool)
+		ids := []int{}
+		for _, id := range *r.CategoryIDs {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+		r.CategoryIDs = &ids
+	}
+
+	if r.Tags != nil {
+		seen := make(map[string]bool)
+		tags := []string{}
+		for _, tag := range *r.Tags {
+			tag = NormalizeTag(tag)
+			if tag == "" || seen[tag] {
+				continue
+			}
+			if len(tag) > 50 {
+				return fmt.Errorf("étiquette trop longue (max 50 caractères): %q", tag)
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+		if len(tags) > 20 {
+			return fmt.Errorf("trop d'étiquettes (max 20)")
+		}
+		r.Tags = &tags
+	}
+
+	return nil
+}
+
+// EventFilter narrows the upcoming events listing; empty fields match all
+type EventFilter struct {
+	// Category is a category slug
+	Category string
+	Tag      string
+	// Past lists the events that are over instead of the upcoming ones
+	Past bool
 }
 
 type RegisterEventRequest struct {
 	MemberID int `json:"member_id"`
+	// OccurrenceStart is required for recurring events
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty"`
+}
+
+// AttendanceRequest records who attended an event, or an occurrence of a
+// series; Attended defaults to true, false clears the attendance
+type AttendanceRequest struct {
+	MemberIDs       []int      `json:"member_ids"`
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty"`
+	Attended        *bool      `json:"attended"`
+}
+
+// Validate requires at least one member
+func (r *AttendanceRequest) Validate() error {
+	if len(r.MemberIDs) == 0 {
+		return fmt.Errorf("member_ids est obligatoire")
+	}
+	if len(r.MemberIDs) > 1000 {
+		return fmt.Errorf("trop de membres (max 1000)")
+	}
+	if r.Attended == nil {
+		attended := true
+		r.Attended = &attended
+	}
+	return nil
 }
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var guestEmailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+
+// Guest is a non-member who registered to a public event. The email is
+// verified when the guest confirms a registration; MemberID is set once
+// the guest has become a member.
+type Guest struct {
+	ID              int                 `json:"id"`
+	FirstName       string              `json:"first_name"`
+	LastName        string              `json:"last_name"`
+	Email           string              `json:"email,omitempty"`
+	EmailVerifiedAt *time.Time          `json:"email_verified_at"`
+	MemberID        *int                `json:"member_id"`
+	ConvertedAt     *time.Time          `json:"converted_at,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+	Registrations   []GuestRegistration `json:"registrations,omitempty"`
+}
+
+// Masked returns a copy of the guest without its email, for callers not
+// allowed to contact guests
+func (g Guest) Masked() Guest {
+	g.Email = ""
+	return g
+}
+
+// Redacted returns a copy of the guest with its name and email replaced by
+// RedactedValue, for the audit log
+func (g Guest) Redacted() Guest {
+	for _, field := range []*string{&g.FirstName, &g.LastName, &g.Email} {
+		if *field != "" {
+			*field = RedactedValue
+		}
+	}
+	return g
+}
+
+// GuestRegistration holds a guest's seat at an event, pending until the
+// emailed link is followed
+type GuestRegistration struct {
+	ID         int    `json:"id"`
+	EventID    int    `json:"event_id"`
+	EventTitle string `json:"event_title"`
+	GuestID    int    `json:"guest_id"`
+	// OccurrenceStart is set for recurring events
+	OccurrenceStart *time.Time `json:"occurrence_start"`
+	RegisteredAt    time.Time  `json:"registered_at"`
+	ConfirmedAt     *time.Time `json:"confirmed_at"`
+	Guest           *Guest     `json:"guest,omitempty"`
+}
+
+type GuestRegistrationRequest struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	// OccurrenceStart is required for recurring events
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty"`
+}
+
+// Validate checks the identity of the guest
+func (r *GuestRegistrationRequest) Validate() error {
+	r.FirstName = strings.TrimSpace(r.FirstName)
+	r.LastName = strings.TrimSpace(r.LastName)
+	r.Email = strings.TrimSpace(r.Email)
+
+	if r.FirstName == "" {
+		return fmt.Errorf("prénom est obligatoire")
+	}
+	if r.LastName == "" {
+		return fmt.Errorf("nom est obligatoire")
+	}
+	if r.Email == "" {
+		return fmt.Errorf("email est obligatoire")
+	}
+	if !guestEmailRegex.MatchString(r.Email) {
+		return fmt.Errorf("format d'email invalide")
+	}
+	if len(r.FirstName) > 100 {
+		return fmt.Errorf("prénom trop long (max 100 caractères)")
+	}
+	if len(r.LastName) > 100 {
+		return fmt.Errorf("nom trop long (max 100 caractères)")
+	}
+	if len(r.Email) > 120 {
+		return fmt.Errorf("email trop long (max 120 caractères)")
+	}
+
+	return nil
+}
+
+// ConvertGuestRequest turns a guest into a member: MemberID links an
+// existing member, otherwise a member is created from the guest's identity
+// and the remaining fields
+type ConvertGuestRequest struct {
+	MemberID     *int   `json:"member_id"`
+	Phone        string `json:"phone"`
+	StudentID    string `json:"student_id"`
+	FieldOfStudy string `json:"field_of_study"`
+}
+
+// MemberRequest returns the member to create for the guest
+func (r *ConvertGuestRequest) MemberRequest(g *Guest) *CreateMemberRequest {
+	return &CreateMemberRequest{
+		FirstName:    g.FirstName,
+		LastName:     g.LastName,
+		Email:        g.Email,
+		Phone:        r.Phone,
+		StudentID:    r.StudentID,
+		FieldOfStudy: r.FieldOfStudy,
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Media is an uploaded image and its thumbnail
+type Media struct {
+	ID           int    `json:"id"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	// OriginalName is the name of the uploaded file, for display only
+	OriginalName string    `json:"original_name"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Key and ThumbnailKey locate the files in the storage
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
+
+// CleanFileName keeps the base name of an uploaded file without control
+// characters, cut to 255 bytes
+func CleanFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "." || name == "/" {
+		return ""
+	}
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
@@ -8,16 +8,23 @@ import (
 )
 
 type Member struct {
-	ID               int       `json:"id"`
-	FirstName        string    `json:"first_name"`
-	LastName         string    `json:"last_name"`
-	Email            string    `json:"email"`
-	Phone            string    `json:"phone"`
-	StudentID        string    `json:"student_id"`
-	FieldOfStudy     string    `json:"field_of_study"`
-	RegistrationDate time.Time `json:"registration_date"`
-	IsActive         bool      `json:"is_active"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID               int        `json:"id"`
+	FirstName        string     `json:"first_name"`
+	LastName         string     `json:"last_name"`
+	Email            string     `json:"email"`
+	Phone            string     `json:"phone"`
+	StudentID        string     `json:"student_id"`
+	FieldOfStudy     string     `json:"field_of_study"`
+	RegistrationDate time.Time  `json:"registration_date"`
+	IsActive         bool       `json:"is_active"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	Version          int        `json:"version"`
+	DeletedAt        *time.Time `json:"deleted_at,omitempty"`
+	AnonymizedAt     *time.Time `json:"anonymized_at,omitempty"`
+	// Roles are free labels ("bureau", "robotique") that announcements can
+	// target
+	Roles []string `json:"roles"`
 }
 
 type CreateMemberRequest struct {
@@ -27,6 +34,8 @@ type CreateMemberRequest struct {
 	Phone        string `json:"phone"`
 	StudentID    string `json:"student_id"`
 	FieldOfStudy string `json:"field_of_study"`
+	// Roles are lowercased by Validate
+	Roles []string `json:"roles"`
 }
 
 // Validate checks all required fields and formats
@@ -64,6 +73,12 @@ func (r *CreateMemberRequest) Validate() error {
 		}
 	}
 
+	roles, err := NormalizeRoles(r.Roles)
+	if err != nil {
+		return err
+	}
+	r.Roles = roles
+
 	// Check length constraints
 	if len(r.FirstName) > 100 {
 		return fmt.Errorf("prénom trop long (max 100 caractères)")
@@ -77,3 +92,55 @@ func (r *CreateMemberRequest) Validate() error {
 
 	return nil
 }
+
+// Masked returns a copy of the member with the phone number and student ID
+// reduced to their last characters, for callers not allowed to see them
+func (m Member) Masked() Member {
+	m.Phone = maskValue(m.Phone, 2)
+	m.StudentID = maskValue(m.StudentID, 3)
+	return m
+}
+
+// RedactedValue replaces personal data in the audit log
+const RedactedValue = "[masqué]"
+
+// Redacted returns a copy of the member with its name, email, phone number
+// and student ID replaced by RedactedValue, for the audit log: an entry
+// records that the member changed, never the personal values
+func (m Member) Redacted() Member {
+	for _, field := range []*string{&m.FirstName, &m.LastName, &m.Email, &m.Phone, &m.StudentID} {
+		if *field != "" {
+			*field = RedactedValue
+		}
+	}
+	return m
+}
+
+func maskValue(value string, visible int) string {
+	runes := []rune(value)
+	if len(runes) <= visible {
+		return strings.Repeat("•", len(runes))
+	}
+	return strings.Repeat("•", len(runes)-visible) + string(runes[len(runes)-visible:])
+}
+
+// NormalizeRoles lowercases and deduplicates roles, dropping empty ones
+func NormalizeRoles(roles []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, role := range roles {
+		role = NormalizeTag(role)
+		if role == "" || seen[role] {
+			continue
+		}
+		if len(role) > 50 {
+			return nil, fmt.Errorf("rôle trop long (max 50 caractères): %q", role)
+		}
+		seen[role] = true
+		normalized = append(normalized, role)
+	}
+	if len(normalized) > 20 {
+		return nil, fmt.Errorf("trop de rôles (max 20)")
+	}
+	return normalized, nil
+}
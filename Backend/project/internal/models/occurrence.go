@@ -0,0 +1,212 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"beautiful-minds/backend/project/internal/recurrence"
+)
+
+// Occurrence is one instance of a recurring event
+type Occurrence struct {
+	EventID int `json:"event_id"`
+	// OccurrenceStart is the start given by the rule; it identifies the
+	// occurrence even when it is rescheduled
+	OccurrenceStart time.Time `json:"occurrence_start"`
+	Title           string    `json:"title"`
+	Description     string    `json:"description"`
+	Date            time.Time `json:"date"`
+	EndDate         time.Time `json:"end_date"`
+	Location        string    `json:"location"`
+	MaxParticipants int       `json:"max_participants"`
+	Cancelled       bool      `json:"cancelled"`
+	// Overridden is set when the occurrence differs from its series
+	Overridden bool `json:"overridden"`
+}
+
+// OccurrenceOverride changes or cancels a single occurrence; nil fields keep
+// the series value. A rescheduled occurrence keeps the series duration.
+type OccurrenceOverride struct {
+	OccurrenceStart time.Time  `json:"occurrence_start"`
+	Title           *string    `json:"title"`
+	Description     *string    `json:"description"`
+	Date            *time.Time `json:"date"`
+	Location        *string    `json:"location"`
+	MaxParticipants *int       `json:"max_participants"`
+	Cancelled       bool       `json:"cancelled"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Rule returns the parsed recurrence rule, nil for single events
+func (e *Event) Rule() *recurrence.Rule {
+	if e.RecurrenceRule == nil {
+		return nil
+	}
+	rule, err := recurrence.Parse(*e.RecurrenceRule)
+	if err != nil {
+		return nil
+	}
+	return rule
+}
+
+// IsExcluded reports whether start is one of the exception dates
+func (e *Event) IsExcluded(start time.Time) bool {
+	for _, d := range e.ExceptionDates {
+		if d.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// Occurrences expands a recurring event into the occurrences starting
+// within [from, to), skipping the exception dates and applying the
+// overrides keyed by occurrence start (Unix seconds). Cancelled occurrences
+// are included with Cancelled set. The rule is applied in the event's
+// timezone, so occurrences keep their local hour across DST changes.
+func (e *Event) Occurrences(from, to time.Time, overrides map[int64]OccurrenceOverride) []Occurrence {
+	rule := e.Rule()
+	if rule == nil {
+		return nil
+	}
+
+	var occurrences []Occurrence
+	for _, start := range rule.Between(e.Date.In(e.Zone()), from, to) {
+		start = start.UTC()
+		if e.IsExcluded(start) {
+			continue
+		}
+
+		var override *OccurrenceOverride
+		if o, ok := overrides[start.Unix()]; ok {
+			override = &o
+		}
+		occurrences = append(occurrences, e.OccurrenceAt(start, override))
+	}
+
+	return occurrences
+}
+
+// HasOccurrence reports whether start is an occurrence of the series that
+// has not been excluded
+func (e *Event) HasOccurrence(start time.Time) bool {
+	rule := e.Rule()
+	return rule != nil && rule.Contains(e.Date.In(e.Zone()), start) && !e.IsExcluded(start)
+}
+
+// SeriesEnd returns the start of the last occurrence of a series, nil for
+// single events and series that never end
+func (e *Event) SeriesEnd() *time.Time {
+	rule := e.Rule()
+	if rule == nil {
+		return nil
+	}
+	end := rule.End(e.Date.In(e.Zone()))
+	if end != nil {
+		utc := end.UTC()
+		end = &utc
+	}
+	return end
+}
+
+// OccurrenceAt builds the occurrence starting at start, applying the
+// override when there is one
+func (e *Event) OccurrenceAt(start time.Time, override *OccurrenceOverride) Occurrence {
+	o := Occurrence{
+		EventID:         e.ID,
+		OccurrenceStart: start,
+		Title:           e.Title,
+		Description:     e.Description,
+		Date:            start,
+		EndDate:         start.Add(e.Duration()),
+		Location:        e.Location,
+		MaxParticipants: e.MaxParticipants,
+	}
+	if override != nil {
+		o.apply(*override)
+		o.EndDate = o.Date.Add(e.Duration())
+	}
+	return o
+}
+
+func (o *Occurrence) apply(override OccurrenceOverride) {
+	o.Overridden = true
+	o.Cancelled = override.Cancelled
+	if override.Title != nil {
+		o.Title = *override.Title
+	}
+	if override.Description != nil {
+		o.Description = *override.Description
+	}
+	if override.Date != nil {
+		o.Date = *override.Date
+	}
+	if override.Location != nil {
+		o.Location = *override.Location
+	}
+	if override.MaxParticipants != nil {
+		o.MaxParticipants = *override.MaxParticipants
+	}
+}
+
+// AsEvent presents the occurrence as an event of the series, for listings
+func (o Occurrence) AsEvent(series Event) Event {
+	e := series
+	e.Title = o.Title
+	e.Description = o.Description
+	e.Date = o.Date
+	e.EndDate = o.EndDate
+	e.Location = o.Location
+	e.MaxParticipants = o.MaxParticipants
+	start := o.OccurrenceStart
+	e.OccurrenceStart = &start
+	return e
+}
+
+// ExpandEvents replaces the recurring events of a listing by their
+// occurrences within [from, to), dropping cancelled ones, and sorts the
+// result by date. Overrides are keyed by event ID then occurrence start.
+func ExpandEvents(events []Event, from, to time.Time, overrides map[int]map[int64]OccurrenceOverride) []Event {
+	expanded := []Event{}
+	for _, e := range events {
+		if e.RecurrenceRule == nil {
+			expanded = append(expanded, e)
+			continue
+		}
+		for _, o := range e.Occurrences(from, to, overrides[e.ID]) {
+			if !o.Cancelled {
+				expanded = append(expanded, o.AsEvent(e))
+			}
+		}
+	}
+
+	sort.SliceStable(expanded, func(i, j int) bool {
+		return expanded[i].Date.Before(expanded[j].Date)
+	})
+	return expanded
+}
+
+// Validate trims the overridden text fields and checks their values
+func (o *OccurrenceOverride) Validate() error {
+	if o.Title != nil {
+		title := strings.TrimSpace(*o.Title)
+		if title == "" {
+			return fmt.Errorf("titre ne peut pas être vide")
+		}
+		o.Title = &title
+	}
+	if o.Location != nil {
+		location := strings.TrimSpace(*o.Location)
+		o.Location = &location
+	}
+	if o.MaxParticipants != nil && *o.MaxParticipants < 0 {
+		return fmt.Errorf("max_participants doit être positif")
+	}
+	if o.Date != nil {
+		date := o.Date.UTC()
+		o.Date = &date
+	}
+	return nil
+}
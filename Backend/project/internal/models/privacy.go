@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	PrivacyExport  = "export"
+	PrivacyErasure = "erasure"
+)
+
+// PrivacyRequest concerns either a member or a guest
+type PrivacyRequest struct {
+	ID          int        `json:"id"`
+	MemberID    *int       `json:"member_id"`
+	GuestID     *int       `json:"guest_id,omitempty"`
+	Type        string     `json:"type"`
+	Status      string     `json:"status"`
+	Source      string     `json:"source"`
+	IP          string     `json:"ip"`
+	RequestedAt time.Time  `json:"requested_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// CreatePrivacyRequest is the self-service form: a confirmation link is
+// emailed to the address, which must belong to a member or a guest
+type CreatePrivacyRequest struct {
+	Email string `json:"email"`
+	Type  string `json:"type"`
+}
+
+// Validate checks the email and request type
+func (r *CreatePrivacyRequest) Validate() error {
+	r.Email = strings.TrimSpace(r.Email)
+	if r.Email == "" {
+		return fmt.Errorf("email est obligatoire")
+	}
+	if r.Type != PrivacyExport && r.Type != PrivacyErasure {
+		return fmt.Errorf("type doit être export ou erasure")
+	}
+	return nil
+}
+
+// RegistrationRecord is an event registration as seen from a member
+type RegistrationRecord struct {
+	EventID      int       `json:"event_id"`
+	EventTitle   string    `json:"event_title"`
+	EventDate    time.Time `json:"event_date"`
+	RegisteredAt time.Time `json:"registered_at"`
+	// OccurrenceStart is set for registrations to an occurrence of a series
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty"`
+	AttendedAt      *time.Time `json:"attended_at,omitempty"`
+}
+
+// SessionRegistrationRecord is a registration to a session of an event
+type SessionRegistrationRecord struct {
+	SessionID    int       `json:"session_id"`
+	SessionTitle string    `json:"session_title"`
+	EventID      int       `json:"event_id"`
+	StartTime    time.Time `json:"start_time"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// SurveyResponseRecord is a survey response as seen from a member
+type SurveyResponseRecord struct {
+	SurveyID    int            `json:"survey_id"`
+	EventID     int            `json:"event_id"`
+	EventTitle  string         `json:"event_title"`
+	SubmittedAt time.Time      `json:"submitted_at"`
+	Answers     []SurveyAnswer `json:"answers"`
+}
+
+// MemberDataExport gathers every piece of data linked to a member
+type MemberDataExport struct {
+	GeneratedAt          time.Time                   `json:"generated_at"`
+	Profile              *Member                     `json:"profile"`
+	Registrations        []RegistrationRecord        `json:"registrations"`
+	SessionRegistrations []SessionRegistrationRecord `json:"session_registrations"`
+	SurveyResponses      []SurveyResponseRecord      `json:"survey_responses"`
+	PrivacyRequests      []PrivacyRequest            `json:"privacy_requests"`
+	// Guests are the guest identities converted into the member
+	Guests []Guest `json:"guests"`
+}
+
+// GuestDataExport gathers every piece of data linked to a guest
+type GuestDataExport struct {
+	GeneratedAt     time.Time        `json:"generated_at"`
+	Profile         *Guest           `json:"profile"`
+	PrivacyRequests []PrivacyRequest `json:"privacy_requests"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// Revision is the state of an event or announcement at one of its versions
+type Revision struct {
+	ID         int64     `json:"id"`
+	EntityType string    `json:"entity_type"`
+	EntityID   int       `json:"entity_id"`
+	Version    int       `json:"version"`
+	Author     string    `json:"author"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Snapshot is the entity as the API returned it, without its derived
+	// fields; left out of listings
+	Snapshot json.RawMessage `json:"snapshot,omitempty"`
+}
+
+// derivedFields are computed when an entity is read rather than written by
+// its author, and left out of snapshots
+var derivedFields = []string{
+	"status", "description_html", "content_html", "excerpt",
+	"local_start", "local_end", "average_rating", "rating_count",
+}
+
+// Snapshot encodes the state of an entity for the audit log and revisions,
+// without its derived fields; nil for a nil entity
+func Snapshot(v any) (json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil || bytes.Equal(b, []byte("null")) {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		// Not an object: nothing to strip
+		return b, nil
+	}
+	for _, key := range derivedFields {
+		delete(fields, key)
+	}
+	return json.Marshal(fields)
+}
+
+// RevisionDiff lists the fields that differ between two revisions as
+// {"field": {"before": x, "after": y}}
+type RevisionDiff struct {
+	From    int             `json:"from"`
+	To      int             `json:"to"`
+	Changes json.RawMessage `json:"changes"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	average := 4.5
+	e := Event{
+		ID: 3, Title: "Atelier", Description: "**Bienvenue**", Version: 2,
+		Date: time.Date(2026, 5, 1, 18, 0, 0, 0, time.UTC), AverageRating: &average, RatingCount: 2,
+	}
+	a := Announcement{ID: 4, Title: "Réunion", Content: "_Ordre du jour_", Status: "published"}
+
+	for name, v := range map[string]any{"event": e, "announcement": &a} {
+		data, err := Snapshot(v)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, key := range derivedFields {
+			if _, ok := fields[key]; ok {
+				t.Errorf("%s: snapshot keeps the derived field %q", name, key)
+			}
+		}
+		if fields["title"] == nil {
+			t.Errorf("%s: snapshot = %v", name, fields)
+		}
+	}
+
+	var decoded Event
+	data, _ := Snapshot(e)
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Description != e.Description || decoded.Version != 2 {
+		t.Errorf("decoded snapshot = %+v, %v", decoded, err)
+	}
+
+	if data, err := Snapshot(nil); data != nil || err != nil {
+		t.Errorf("Snapshot(nil) = %s, %v", data, err)
+	}
+}
@@ -0,0 +1,159 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Speaker gives a talk; MemberID links speakers who belong to the club
+type Speaker struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Bio         string    `json:"bio"`
+	PhotoURL    string    `json:"photo_url"`
+	Affiliation string    `json:"affiliation"`
+	MemberID    *int      `json:"member_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type CreateSpeakerRequest struct {
+	Name        string `json:"name"`
+	Bio         string `json:"bio"`
+	PhotoURL    string `json:"photo_url"`
+	Affiliation string `json:"affiliation"`
+	MemberID    *int   `json:"member_id"`
+}
+
+// Validate checks the speaker fields
+func (r *CreateSpeakerRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Bio = strings.TrimSpace(r.Bio)
+	r.PhotoURL = strings.TrimSpace(r.PhotoURL)
+	r.Affiliation = strings.TrimSpace(r.Affiliation)
+
+	if r.Name == "" {
+		return fmt.Errorf("nom est obligatoire")
+	}
+	if len(r.Name) > 200 {
+		return fmt.Errorf("nom trop long (max 200 caractères)")
+	}
+	if len(r.Affiliation) > 200 {
+		return fmt.Errorf("affiliation trop longue (max 200 caractères)")
+	}
+	if r.PhotoURL != "" {
+		if u, err := url.Parse(r.PhotoURL); err != nil || (u.Scheme != "http" && u.Scheme != "https" && !strings.HasPrefix(r.PhotoURL, "/")) {
+			return fmt.Errorf("photo_url invalide")
+		}
+	}
+
+	return nil
+}
+
+// Session is a talk or workshop in the agenda of an event
+type Session struct {
+	ID          int       `json:"id"`
+	EventID     int       `json:"event_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	// VenueID is the room booked for the session; Room then holds its name
+	VenueID *int   `json:"venue_id"`
+	Room    string `json:"room"`
+	// RegistrationOpen enables registering to the session itself, within
+	// Capacity seats (0 means unlimited)
+	RegistrationOpen bool      `json:"registration_open"`
+	Capacity         int       `json:"capacity"`
+	Registered       int       `json:"registered"`
+	Speakers         []Speaker `json:"speakers"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// IsFull reports whether the session has no seat left
+func (s *Session) IsFull() bool {
+	return s.Capacity > 0 && s.Registered >= s.Capacity
+}
+
+// Agenda is the programme of an event
+type Agenda struct {
+	EventID  int       `json:"event_id"`
+	Title    string    `json:"title"`
+	Timezone string    `json:"timezone"`
+	Sessions []Session `json:"sessions"`
+}
+
+type CreateSessionRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// StartTime and EndTime take the formats of CreateEventRequest.Date,
+	// local times being read in the event's timezone
+	StartTime        string `json:"start_time"`
+	EndTime          string `json:"end_time"`
+	VenueID          *int   `json:"venue_id"`
+	Room             string `json:"room"`
+	RegistrationOpen bool   `json:"registration_open"`
+	Capacity         int    `json:"capacity"`
+	// SpeakerIDs lists the speakers in order of appearance
+	SpeakerIDs []int `json:"speaker_ids"`
+
+	// Start and End are resolved in UTC by Validate
+	Start time.Time `json:"-"`
+	End   time.Time `json:"-"`
+}
+
+// Validate resolves the time slot and checks that it lies within the event
+func (r *CreateSessionRequest) Validate(event *Event) error {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Description = strings.TrimSpace(r.Description)
+	r.Room = strings.TrimSpace(r.Room)
+
+	if r.Title == "" {
+		return fmt.Errorf("titre est obligatoire")
+	}
+	if len(r.Title) > 200 {
+		return fmt.Errorf("titre trop long (max 200 caractères)")
+	}
+	if r.Capacity < 0 {
+		return fmt.Errorf("capacity doit être positive")
+	}
+	if event.RecurrenceRule != nil {
+		return fmt.Errorf("les événements récurrents n'ont pas de programme")
+	}
+
+	var err error
+	zone := event.Zone()
+	if r.Start, err = parseEventTime(strings.TrimSpace(r.StartTime), zone); err != nil {
+		return err
+	}
+	if r.End, err = parseEventTime(strings.TrimSpace(r.EndTime), zone); err != nil {
+		return err
+	}
+	if !r.End.After(r.Start) {
+		return fmt.Errorf("la fin de la session doit suivre son début")
+	}
+	if r.Start.Before(event.Date) || r.End.After(event.EndDate) {
+		return fmt.Errorf("la session doit se tenir pendant l'événement")
+	}
+
+	seen := make(map[int]bool)
+	ids := []int{}
+	for _, id := range r.SpeakerIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	r.SpeakerIDs = ids
+
+	return nil
+}
+
+// RegisterSessionRequest takes a seat in a session for a member already
+// registered to its event
+type RegisterSessionRequest struct {
+	MemberID int `json:"member_id"`
+}
@@ -0,0 +1,340 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Question kinds of a feedback survey
+const (
+	QuestionRating = "rating"
+	QuestionChoice = "choice"
+	QuestionText   = "text"
+)
+
+// MaxRating is the top of the rating scale, which starts at 1
+const MaxRating = 5
+
+// Survey is the feedback survey of an event, answered by the members who
+// attended it
+type Survey struct {
+	ID          int    `json:"id"`
+	EventID     int    `json:"event_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// ClosesAt ends the collection of responses; nil keeps it open
+	ClosesAt      *time.Time       `json:"closes_at"`
+	Questions     []SurveyQuestion `json:"questions"`
+	ResponseCount int              `json:"response_count"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+type SurveyQuestion struct {
+	ID       int    `json:"id"`
+	Position int    `json:"position"`
+	Kind     string `json:"kind"`
+	Label    string `json:"label"`
+	Required bool   `json:"required"`
+	// Options lists the answers of choice questions; Multiple allows
+	// picking several of them
+	Options  []string `json:"options"`
+	Multiple bool     `json:"multiple"`
+}
+
+// IsClosed reports whether the survey no longer takes responses at t
+func (s *Survey) IsClosed(t time.Time) bool {
+	return s.ClosesAt != nil && !t.Before(*s.ClosesAt)
+}
+
+type SurveyRequest struct {
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	ClosesAt    *time.Time       `json:"closes_at"`
+	Questions   []SurveyQuestion `json:"questions"`
+}
+
+// Validate checks the questions and numbers them in order; IDs and
+// positions sent by the client are ignored
+func (r *SurveyRequest) Validate() error {
+	r.Title = strings.TrimSpace(r.Title)
+	r.Description = strings.TrimSpace(r.Description)
+
+	if r.Title == "" {
+		return fmt.Errorf("titre est obligatoire")
+	}
+	if len(r.Title) > 200 {
+		return fmt.Errorf("titre trop long (max 200 caractères)")
+	}
+	if len(r.Questions) == 0 {
+		return fmt.Errorf("le questionnaire doit avoir au moins une question")
+	}
+	if len(r.Questions) > 50 {
+		return fmt.Errorf("trop de questions (max 50)")
+	}
+	if r.ClosesAt != nil {
+		closes := r.ClosesAt.UTC()
+		r.ClosesAt = &closes
+	}
+
+	for i := range r.Questions {
+		q := &r.Questions[i]
+		q.ID = 0
+		q.Position = i + 1
+		q.Label = strings.TrimSpace(q.Label)
+		if q.Label == "" {
+			return fmt.Errorf("question %d : libellé obligatoire", q.Position)
+		}
+		if len(q.Label) > 500 {
+			return fmt.Errorf("question %d : libellé trop long (max 500 caractères)", q.Position)
+		}
+
+		switch q.Kind {
+		case QuestionRating, QuestionText:
+			if len(q.Options) > 0 || q.Multiple {
+				return fmt.Errorf("question %d : options réservées aux questions à choix", q.Position)
+			}
+			q.Options = []string{}
+		case QuestionChoice:
+			seen := make(map[string]bool)
+			options := []string{}
+			for _, o := range q.Options {
+				o = strings.TrimSpace(o)
+				if o == "" || seen[o] {
+					continue
+				}
+				seen[o] = true
+				options = append(options, o)
+			}
+			if len(options) < 2 {
+				return fmt.Errorf("question %d : au moins deux options distinctes", q.Position)
+			}
+			q.Options = options
+		default:
+			return fmt.Errorf("question %d : type doit être rating, choice ou text", q.Position)
+		}
+	}
+
+	return nil
+}
+
+// SurveyAnswer answers one question: Rating for rating questions, Choices
+// for choice questions and Text for free text
+type SurveyAnswer struct {
+	QuestionID int      `json:"question_id"`
+	Rating     *int     `json:"rating,omitempty"`
+	Choices    []string `json:"choices,omitempty"`
+	Text       string   `json:"text,omitempty"`
+}
+
+// SurveyResponseRequest answers a survey with the token emailed to the
+// member, who is never taken from the request itself
+type SurveyResponseRequest struct {
+	Token   string         `json:"token"`
+	Answers []SurveyAnswer `json:"answers"`
+}
+
+// SurveyInvitationRequest asks for the link answering a survey, emailed to
+// the address when it belongs to a member who attended the event
+type SurveyInvitationRequest struct {
+	Email string `json:"email"`
+}
+
+// Validate checks the email
+func (r *SurveyInvitationRequest) Validate() error {
+	r.Email = strings.TrimSpace(r.Email)
+	if r.Email == "" {
+		return fmt.Errorf("email est obligatoire")
+	}
+	return nil
+}
+
+// ValidateResponse checks the answers against the questions, drops the
+// empty ones and requires the mandatory questions to be answered
+func (s *Survey) ValidateResponse(r *SurveyResponseRequest) error {
+	r.Token = strings.TrimSpace(r.Token)
+	if r.Token == "" {
+		return fmt.Errorf("token est obligatoire")
+	}
+
+	questions := make(map[int]*SurveyQuestion, len(s.Questions))
+	for i := range s.Questions {
+		questions[s.Questions[i].ID] = &s.Questions[i]
+	}
+
+	answered := make(map[int]bool)
+	answers := []SurveyAnswer{}
+	for _, a := range r.Answers {
+		q, ok := questions[a.QuestionID]
+		if !ok {
+			return fmt.Errorf("question %d inconnue", a.QuestionID)
+		}
+		if answered[q.ID] {
+			return fmt.Errorf("question %d : une seule réponse", q.Position)
+		}
+
+		a.Text = strings.TrimSpace(a.Text)
+		switch q.Kind {
+		case QuestionRating:
+			if len(a.Choices) > 0 || a.Text != "" {
+				return fmt.Errorf("question %d : note attendue", q.Position)
+			}
+			if a.Rating == nil {
+				continue
+			}
+			if *a.Rating < 1 || *a.Rating > MaxRating {
+				return fmt.Errorf("question %d : note entre 1 et %d", q.Position, MaxRating)
+			}
+		case QuestionChoice:
+			if a.Rating != nil || a.Text != "" {
+				return fmt.Errorf("question %d : choix attendu", q.Position)
+			}
+			if len(a.Choices) == 0 {
+				continue
+			}
+			if len(a.Choices) > 1 && !q.Multiple {
+				return fmt.Errorf("question %d : un seul choix possible", q.Position)
+			}
+			for _, c := range a.Choices {
+				if !q.hasOption(c) {
+					return fmt.Errorf("question %d : choix inconnu %q", q.Position, c)
+				}
+			}
+		case QuestionText:
+			if a.Rating != nil || len(a.Choices) > 0 {
+				return fmt.Errorf("question %d : texte attendu", q.Position)
+			}
+			if a.Text == "" {
+				continue
+			}
+			if len(a.Text) > 5000 {
+				return fmt.Errorf("question %d : réponse trop longue (max 5000 caractères)", q.Position)
+			}
+		}
+
+		answered[q.ID] = true
+		answers = append(answers, a)
+	}
+
+	for _, q := range s.Questions {
+		if q.Required && !answered[q.ID] {
+			return fmt.Errorf("question %d : réponse obligatoire", q.Position)
+		}
+	}
+
+	r.Answers = answers
+	return nil
+}
+
+func (q *SurveyQuestion) hasOption(option string) bool {
+	for _, o := range q.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// SurveyResponse is an anonymous response as shown in results and exports
+type SurveyResponse struct {
+	ID          int            `json:"id"`
+	SubmittedAt time.Time      `json:"submitted_at"`
+	Answers     []SurveyAnswer `json:"answers"`
+}
+
+// Answer returns the answer to a question, nil when it was skipped
+func (r *SurveyResponse) Answer(questionID int) *SurveyAnswer {
+	for i := range r.Answers {
+		if r.Answers[i].QuestionID == questionID {
+			return &r.Answers[i]
+		}
+	}
+	return nil
+}
+
+// SurveyResults aggregates the responses to a survey
+type SurveyResults struct {
+	SurveyID      int `json:"survey_id"`
+	EventID       int `json:"event_id"`
+	ResponseCount int `json:"response_count"`
+	// AverageRating averages every rating given in the survey
+	AverageRating *float64         `json:"average_rating"`
+	Questions     []QuestionResult `json:"questions"`
+}
+
+// QuestionResult aggregates the answers to a question: Distribution counts
+// the ratings ("1" to "5") or the options picked, Texts lists free text
+type QuestionResult struct {
+	QuestionID    int            `json:"question_id"`
+	Label         string         `json:"label"`
+	Kind          string         `json:"kind"`
+	Answered      int            `json:"answered"`
+	AverageRating *float64       `json:"average_rating,omitempty"`
+	Distribution  map[string]int `json:"distribution,omitempty"`
+	Texts         []string       `json:"texts,omitempty"`
+}
+
+// Aggregate computes the results of the survey from its responses
+func (s *Survey) Aggregate(responses []SurveyResponse) SurveyResults {
+	results := SurveyResults{
+		SurveyID:      s.ID,
+		EventID:       s.EventID,
+		ResponseCount: len(responses),
+		Questions:     []QuestionResult{},
+	}
+
+	var ratingSum, ratingCount int
+	for _, q := range s.Questions {
+		result := QuestionResult{QuestionID: q.ID, Label: q.Label, Kind: q.Kind}
+		switch q.Kind {
+		case QuestionRating:
+			result.Distribution = make(map[string]int)
+			for i := 1; i <= MaxRating; i++ {
+				result.Distribution[strconv.Itoa(i)] = 0
+			}
+		case QuestionChoice:
+			result.Distribution = make(map[string]int)
+			for _, o := range q.Options {
+				result.Distribution[o] = 0
+			}
+		case QuestionText:
+			result.Texts = []string{}
+		}
+
+		var sum int
+		for i := range responses {
+			a := responses[i].Answer(q.ID)
+			if a == nil {
+				continue
+			}
+			result.Answered++
+			switch q.Kind {
+			case QuestionRating:
+				sum += *a.Rating
+				result.Distribution[strconv.Itoa(*a.Rating)]++
+			case QuestionChoice:
+				for _, c := range a.Choices {
+					result.Distribution[c]++
+				}
+			case QuestionText:
+				result.Texts = append(result.Texts, a.Text)
+			}
+		}
+
+		if q.Kind == QuestionRating && result.Answered > 0 {
+			avg := float64(sum) / float64(result.Answered)
+			result.AverageRating = &avg
+			ratingSum += sum
+			ratingCount += result.Answered
+		}
+		results.Questions = append(results.Questions, result)
+	}
+
+	if ratingCount > 0 {
+		avg := float64(ratingSum) / float64(ratingCount)
+		results.AverageRating = &avg
+	}
+	return results
+}
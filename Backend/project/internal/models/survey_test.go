@@ -0,0 +1,124 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func rating(n int) *int { return &n }
+
+func testSurvey() *Survey {
+	return &Survey{
+		ID:      1,
+		EventID: 7,
+		Questions: []SurveyQuestion{
+			{ID: 10, Position: 1, Kind: QuestionRating, Label: "Contenu", Required: true},
+			{ID: 11, Position: 2, Kind: QuestionRating, Label: "Organisation"},
+			{ID: 12, Position: 3, Kind: QuestionChoice, Label: "Format", Options: []string{"court", "long"}, Multiple: true},
+			{ID: 13, Position: 4, Kind: QuestionText, Label: "Remarques"},
+		},
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	s := testSurvey()
+	responses := []SurveyResponse{
+		{ID: 1, Answers: []SurveyAnswer{
+			{QuestionID: 10, Rating: rating(5)},
+			{QuestionID: 11, Rating: rating(2)},
+			{QuestionID: 12, Choices: []string{"court", "long"}},
+			{QuestionID: 13, Text: "Très bien"},
+		}},
+		{ID: 2, Answers: []SurveyAnswer{
+			{QuestionID: 10, Rating: rating(4)},
+			{QuestionID: 12, Choices: []string{"court"}},
+		}},
+		{ID: 3, Answers: []SurveyAnswer{
+			{QuestionID: 10, Rating: rating(3)},
+		}},
+	}
+
+	got := s.Aggregate(responses)
+
+	if got.SurveyID != 1 || got.EventID != 7 || got.ResponseCount != 3 {
+		t.Fatalf("header = %d/%d/%d, want 1/7/3", got.SurveyID, got.EventID, got.ResponseCount)
+	}
+	// Every rating of the survey: (5+4+3+2) / 4
+	if got.AverageRating == nil || *got.AverageRating != 3.5 {
+		t.Errorf("AverageRating = %v, want 3.5", got.AverageRating)
+	}
+	if len(got.Questions) != 4 {
+		t.Fatalf("%d question results, want 4", len(got.Questions))
+	}
+
+	content := got.Questions[0]
+	if content.Answered != 3 || content.AverageRating == nil || *content.AverageRating != 4 {
+		t.Errorf("rating question: answered %d, average %v; want 3 and 4", content.Answered, content.AverageRating)
+	}
+	wantRatings := map[string]int{"1": 0, "2": 0, "3": 1, "4": 1, "5": 1}
+	if !reflect.DeepEqual(content.Distribution, wantRatings) {
+		t.Errorf("rating distribution = %v, want %v", content.Distribution, wantRatings)
+	}
+
+	format := got.Questions[2]
+	wantChoices := map[string]int{"court": 2, "long": 1}
+	if format.Answered != 2 || !reflect.DeepEqual(format.Distribution, wantChoices) {
+		t.Errorf("choice question: answered %d, distribution %v; want 2 and %v", format.Answered, format.Distribution, wantChoices)
+	}
+	if format.AverageRating != nil {
+		t.Errorf("choice question has an average rating %v", *format.AverageRating)
+	}
+
+	remarks := got.Questions[3]
+	if remarks.Answered != 1 || !reflect.DeepEqual(remarks.Texts, []string{"Très bien"}) {
+		t.Errorf("text question: answered %d, texts %q", remarks.Answered, remarks.Texts)
+	}
+}
+
+func TestAggregateWithoutResponses(t *testing.T) {
+	got := testSurvey().Aggregate(nil)
+
+	if got.ResponseCount != 0 || got.AverageRating != nil {
+		t.Errorf("count %d, average %v; want 0 and nil", got.ResponseCount, got.AverageRating)
+	}
+	for _, q := range got.Questions {
+		if q.Answered != 0 || q.AverageRating != nil {
+			t.Errorf("question %d: answered %d, average %v", q.QuestionID, q.Answered, q.AverageRating)
+		}
+	}
+	// Unanswered options and ratings are listed with zero
+	if got.Questions[0].Distribution["5"] != 0 || len(got.Questions[0].Distribution) != MaxRating {
+		t.Errorf("rating distribution = %v", got.Questions[0].Distribution)
+	}
+	if got.Questions[3].Texts == nil {
+		t.Error("text question: Texts is nil, want an empty list")
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		answers []SurveyAnswer
+		wantErr bool
+	}{
+		{"required only", []SurveyAnswer{{QuestionID: 10, Rating: rating(4)}}, false},
+		{"required missing", []SurveyAnswer{{QuestionID: 11, Rating: rating(4)}}, true},
+		{"unknown question", []SurveyAnswer{{QuestionID: 10, Rating: rating(4)}, {QuestionID: 99, Text: "x"}}, true},
+		{"rating out of range", []SurveyAnswer{{QuestionID: 10, Rating: rating(6)}}, true},
+		{"answered twice", []SurveyAnswer{{QuestionID: 10, Rating: rating(4)}, {QuestionID: 10, Rating: rating(5)}}, true},
+		{"unknown option", []SurveyAnswer{{QuestionID: 10, Rating: rating(4)}, {QuestionID: 12, Choices: []string{"moyen"}}}, true},
+		{"text for a rating", []SurveyAnswer{{QuestionID: 10, Text: "5"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &SurveyResponseRequest{Token: "jeton", Answers: tt.answers}
+			if err := testSurvey().ValidateResponse(req); (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := testSurvey().ValidateResponse(&SurveyResponseRequest{Answers: []SurveyAnswer{{QuestionID: 10, Rating: rating(4)}}}); err == nil {
+		t.Error("response without token accepted")
+	}
+}
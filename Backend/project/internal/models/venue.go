@@ -0,0 +1,102 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Venue is a room that events book
+type Venue struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Building  string    `json:"building"`
+	Capacity  int       `json:"capacity"`
+	Equipment []string  `json:"equipment"`
+	Notes     string    `json:"notes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateVenueRequest struct {
+	Name      string   `json:"name"`
+	Building  string   `json:"building"`
+	Capacity  int      `json:"capacity"`
+	Equipment []string `json:"equipment"`
+	Notes     string   `json:"notes"`
+}
+
+// Validate checks the venue and normalizes its equipment list
+func (r *CreateVenueRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Building = strings.TrimSpace(r.Building)
+	r.Notes = strings.TrimSpace(r.Notes)
+
+	if r.Name == "" {
+		return fmt.Errorf("nom est obligatoire")
+	}
+	if len(r.Name) > 100 {
+		return fmt.Errorf("nom trop long (max 100 caractères)")
+	}
+	if len(r.Building) > 100 {
+		return fmt.Errorf("bâtiment trop long (max 100 caractères)")
+	}
+	if r.Capacity <= 0 {
+		return fmt.Errorf("capacity doit être positive")
+	}
+
+	seen := make(map[string]bool)
+	equipment := []string{}
+	for _, item := range r.Equipment {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[strings.ToLower(item)] {
+			continue
+		}
+		seen[strings.ToLower(item)] = true
+		equipment = append(equipment, item)
+	}
+	r.Equipment = equipment
+
+	return nil
+}
+
+// Booking is the time a venue is held by an event or one of its occurrences
+type Booking struct {
+	EventID int       `json:"event_id"`
+	Title   string    `json:"title"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	// OccurrenceStart identifies the occurrence of a recurring event
+	OccurrenceStart *time.Time `json:"occurrence_start,omitempty"`
+	// SessionID is set when the slot is held by a session of the event
+	SessionID *int `json:"session_id,omitempty"`
+}
+
+// Overlaps reports whether two bookings share some time
+func (b Booking) Overlaps(other Booking) bool {
+	return b.Start.Before(other.End) && other.Start.Before(b.End)
+}
+
+// Bookings returns the time slots the event holds within [from, to),
+// skipping cancelled occurrences
+func (e *Event) Bookings(from, to time.Time, overrides map[int64]OccurrenceOverride) []Booking {
+	if e.RecurrenceRule == nil {
+		b := Booking{EventID: e.ID, Title: e.Title, Start: e.Date, End: e.EndDate}
+		if b.Start.Before(to) && b.End.After(from) {
+			return []Booking{b}
+		}
+		return nil
+	}
+
+	var bookings []Booking
+	for _, o := range e.Occurrences(from.Add(-e.Duration()), to, overrides) {
+		if o.Cancelled || !o.Date.Before(to) || !o.EndDate.After(from) {
+			continue
+		}
+		start := o.OccurrenceStart
+		bookings = append(bookings, Booking{
+			EventID: e.ID, Title: o.Title, Start: o.Date, End: o.EndDate, OccurrenceStart: &start,
+		})
+	}
+	return bookings
+}
@@ -0,0 +1,198 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// maxIterations bounds the expansion of a series
+const maxIterations = 5000
+
+// icalLayout is the UTC date-time format of RFC 5545
+const icalLayout = "20060102T150405Z"
+
+// Rule is the subset of RFC 5545 RRULE supported by the club: weekly or
+// monthly series every Interval periods, bounded by Count or Until. The
+// first occurrence is the event date itself.
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// Count is the number of occurrences, 0 when unbounded
+	Count int
+	// Until is the last possible occurrence start, inclusive
+	Until *time.Time
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;INTERVAL=2;UNTIL=20270630T000000Z".
+// The "RRULE:" prefix is optional.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("règle de récurrence vide")
+	}
+
+	r := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("règle de récurrence: %q mal formé", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			if r.Freq != Weekly && r.Freq != Monthly {
+				return nil, fmt.Errorf("règle de récurrence: fréquence %q non supportée (WEEKLY ou MONTHLY)", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 52 {
+				return nil, fmt.Errorf("règle de récurrence: INTERVAL %q invalide", value)
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return nil, fmt.Errorf("règle de récurrence: COUNT %q invalide (1 à 1000)", value)
+			}
+			r.Count = n
+		case "UNTIL":
+			t, err := ParseDate(value)
+			if err != nil {
+				return nil, fmt.Errorf("règle de récurrence: UNTIL %q invalide", value)
+			}
+			// A bare date includes the whole day
+			if len(value) == len("20060102") {
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			r.Until = &t
+		default:
+			return nil, fmt.Errorf("règle de récurrence: %q non supporté", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("règle de récurrence: FREQ est obligatoire")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, errors.New("règle de récurrence: COUNT et UNTIL sont exclusifs")
+	}
+
+	return r, nil
+}
+
+// String formats the rule in its canonical RRULE form, without prefix
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+FormatDate(*r.Until))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns the starts of the occurrences of a series beginning at
+// start that fall within [from, to)
+func (r *Rule) Between(start, from, to time.Time) []time.Time {
+	var starts []time.Time
+	r.each(start, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			starts = append(starts, t)
+		}
+		return true
+	})
+	return starts
+}
+
+// Contains reports whether t is the start of an occurrence of the series
+func (r *Rule) Contains(start, t time.Time) bool {
+	found := false
+	r.each(start, func(occurrence time.Time) bool {
+		if occurrence.Equal(t) {
+			found = true
+		}
+		return occurrence.Before(t)
+	})
+	return found
+}
+
+// End returns the start of the last occurrence, or nil when the series
+// never ends
+func (r *Rule) End(start time.Time) *time.Time {
+	if r.Count == 0 && r.Until == nil {
+		return nil
+	}
+
+	var last time.Time
+	r.each(start, func(t time.Time) bool {
+		last = t
+		return true
+	})
+	return &last
+}
+
+// each calls fn on every occurrence start in order until fn returns false
+// or the series ends. Monthly occurrences falling on a day the month does
+// not have (the 31st...) are skipped, as RFC 5545 requires.
+func (r *Rule) each(start time.Time, fn func(time.Time) bool) {
+	emitted := 0
+	for n := 0; n < maxIterations; n++ {
+		var t time.Time
+		switch r.Freq {
+		case Weekly:
+			t = start.AddDate(0, 0, 7*r.Interval*n)
+		case Monthly:
+			t = start.AddDate(0, r.Interval*n, 0)
+			if t.Day() != start.Day() {
+				continue
+			}
+		}
+
+		if r.Until != nil && t.After(*r.Until) {
+			return
+		}
+		if r.Count > 0 && emitted >= r.Count {
+			return
+		}
+		emitted++
+
+		if !fn(t) {
+			return
+		}
+	}
+}
+
+// ParseDate reads an RFC 5545 date-time ("20261105T180000Z") or date
+// ("20261105"), interpreted in UTC
+func ParseDate(s string) (time.Time, error) {
+	if t, err := time.Parse(icalLayout, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102T150405", s); err == nil {
+		return t, nil
+	}
+	return time.Parse("20060102", s)
+}
+
+// FormatDate writes a time in the RFC 5545 UTC date-time format
+func FormatDate(t time.Time) string {
+	return t.UTC().Format(icalLayout)
+}
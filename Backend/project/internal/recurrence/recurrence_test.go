@@ -0,0 +1,197 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func mustParse(t *testing.T, s string) *Rule {
+	t.Helper()
+	r, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", s, err)
+	}
+	return r
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"FREQ=WEEKLY", "FREQ=WEEKLY"},
+		{"RRULE:freq=monthly;interval=2", "FREQ=MONTHLY;INTERVAL=2"},
+		{"FREQ=WEEKLY;INTERVAL=1;COUNT=10", "FREQ=WEEKLY;COUNT=10"},
+		{"FREQ=WEEKLY;UNTIL=20270630T180000Z", "FREQ=WEEKLY;UNTIL=20270630T180000Z"},
+		// A bare date covers the whole day
+		{"FREQ=WEEKLY;UNTIL=20270630", "FREQ=WEEKLY;UNTIL=20270630T235959Z"},
+	}
+	for _, tt := range tests {
+		if got := mustParse(t, tt.in).String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"RRULE:",
+		"FREQ=DAILY",
+		"INTERVAL=2",
+		"FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;INTERVAL=53",
+		"FREQ=WEEKLY;COUNT=0",
+		"FREQ=WEEKLY;COUNT=1001",
+		"FREQ=WEEKLY;UNTIL=2027-06-30",
+		"FREQ=WEEKLY;COUNT=3;UNTIL=20270630",
+		"FREQ=WEEKLY;BYDAY=MO",
+		"FREQ",
+	} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	start := date("2026-09-01T18:00:00Z")
+
+	tests := []struct {
+		name     string
+		rule     string
+		from, to time.Time
+		want     []time.Time
+	}{
+		{
+			name: "every two weeks within the window",
+			rule: "FREQ=WEEKLY;INTERVAL=2",
+			from: date("2026-09-10T00:00:00Z"),
+			to:   date("2026-10-14T00:00:00Z"),
+			want: []time.Time{date("2026-09-15T18:00:00Z"), date("2026-09-29T18:00:00Z"), date("2026-10-13T18:00:00Z")},
+		},
+		{
+			name: "to is exclusive",
+			rule: "FREQ=WEEKLY",
+			from: start,
+			to:   date("2026-09-15T18:00:00Z"),
+			want: []time.Time{start, date("2026-09-08T18:00:00Z")},
+		},
+		{
+			name: "count bounds the series",
+			rule: "FREQ=MONTHLY;COUNT=3",
+			from: start,
+			to:   date("2027-09-01T00:00:00Z"),
+			want: []time.Time{start, date("2026-10-01T18:00:00Z"), date("2026-11-01T18:00:00Z")},
+		},
+		{
+			name: "until is inclusive",
+			rule: "FREQ=WEEKLY;UNTIL=20260915T180000Z",
+			from: start,
+			to:   date("2027-01-01T00:00:00Z"),
+			want: []time.Time{start, date("2026-09-08T18:00:00Z"), date("2026-09-15T18:00:00Z")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mustParse(t, tt.rule).Between(start, tt.from, tt.to)
+			if !equalTimes(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMonthlySkipsMissingDays(t *testing.T) {
+	start := date("2027-01-31T18:00:00Z")
+	got := mustParse(t, "FREQ=MONTHLY;COUNT=3").Between(start, start, date("2028-01-01T00:00:00Z"))
+
+	// February, April and June have no 31st
+	want := []time.Time{start, date("2027-03-31T18:00:00Z"), date("2027-05-31T18:00:00Z")}
+	if !equalTimes(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestContains(t *testing.T) {
+	start := date("2026-09-01T18:00:00Z")
+	r := mustParse(t, "FREQ=WEEKLY;INTERVAL=2;COUNT=3")
+
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{start, true},
+		{date("2026-09-15T18:00:00Z"), true},
+		{date("2026-09-29T18:00:00Z"), true},
+		// Off-cycle week, wrong hour, past the count, before the start
+		{date("2026-09-08T18:00:00Z"), false},
+		{date("2026-09-15T19:00:00Z"), false},
+		{date("2026-10-13T18:00:00Z"), false},
+		{date("2026-08-18T18:00:00Z"), false},
+	}
+	for _, tt := range tests {
+		if got := r.Contains(start, tt.t); got != tt.want {
+			t.Errorf("Contains(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestEnd(t *testing.T) {
+	start := date("2026-09-01T18:00:00Z")
+
+	if end := mustParse(t, "FREQ=WEEKLY").End(start); end != nil {
+		t.Errorf("unbounded series: End = %v, want nil", end)
+	}
+	if end := mustParse(t, "FREQ=WEEKLY;COUNT=4").End(start); end == nil || !end.Equal(date("2026-09-22T18:00:00Z")) {
+		t.Errorf("COUNT=4: End = %v, want 2026-09-22T18:00:00Z", end)
+	}
+	// The last occurrence, not the UNTIL bound itself
+	if end := mustParse(t, "FREQ=WEEKLY;UNTIL=20260920").End(start); end == nil || !end.Equal(date("2026-09-15T18:00:00Z")) {
+		t.Errorf("UNTIL: End = %v, want 2026-09-15T18:00:00Z", end)
+	}
+}
+
+func TestDates(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"20261105T180000Z", date("2026-11-05T18:00:00Z")},
+		{"20261105T180000", date("2026-11-05T18:00:00Z")},
+		{"20261105", date("2026-11-05T00:00:00Z")},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.in)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseDate(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	if got := FormatDate(time.Date(2026, 11, 5, 19, 0, 0, 0, paris)); got != "20261105T180000Z" {
+		t.Errorf("FormatDate = %q, want 20261105T180000Z", got)
+	}
+}
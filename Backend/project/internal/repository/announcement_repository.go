@@ -1,10 +1,47 @@
 package repository
 
 import (
+	"beautiful-minds/backend/project/internal/metrics"
 	"beautiful-minds/backend/project/internal/models"
 	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+// ErrUnknownAudienceEvent is returned when an announcement targets the
+// registrants of a missing event
+var ErrUnknownAudienceEvent = errors.New("événement du public inconnu")
+
+const announcementColumns = `id, title, content, published_date, is_pinned,
+	created_at, updated_at, version, deleted_at, draft, expires_at, ` + announcementStatus + `,
+	audience_fields, audience_roles, audience_event_ids, notify, notified_at`
+
+// announcementStatus derives the status of an announcement at the time of
+// the query; public listings only show the published ones
+const announcementStatus = `CASE
+	WHEN draft THEN 'draft'
+	WHEN published_date > NOW() THEN 'scheduled'
+	WHEN expires_at <= NOW() THEN 'expired'
+	ELSE 'published' END`
+
+// audienceEveryone holds for the announcements aliased a that target no one
+// in particular, audienceMatch for those whose audience includes the member
+// aliased m
+const (
+	audienceEveryone = `(cardinality(a.audience_fields) = 0 AND cardinality(a.audience_roles) = 0
+		AND cardinality(a.audience_event_ids) = 0)`
+	audienceMatch = `(LOWER(m.field_of_study) IN (SELECT LOWER(f) FROM unnest(a.audience_fields) f)
+		OR m.roles && a.audience_roles
+		OR EXISTS (SELECT 1 FROM event_registrations er
+		           WHERE er.member_id = m.id AND er.event_id = ANY(a.audience_event_ids)))`
+)
+
+// announcementPublic holds for the published, unexpired announcements
+const announcementPublic = `deleted_at IS NULL AND NOT draft AND published_date <= NOW()
+	AND (expires_at IS NULL OR expires_at > NOW())`
+
 type AnnouncementRepository struct {
 	db *sql.DB
 }
@@ -13,14 +50,34 @@ func NewAnnouncementRepository(db *sql.DB) *AnnouncementRepository {
 	return &AnnouncementRepository{db: db}
 }
 
-func (r *AnnouncementRepository) GetAll() ([]models.Announcement, error) {
-	query := `
-		SELECT id, title, content, published_date, is_pinned, created_at
-		FROM announcements
-		ORDER BY is_pinned DESC, published_date DESC
-	`
+func scanAnnouncement(s scanner) (*models.Announcement, error) {
+	var a models.Announcement
+	var eventIDs []int64
+	err := s.Scan(
+		&a.ID, &a.Title, &a.Content, &a.PublishedDate,
+		&a.IsPinned, &a.CreatedAt, &a.UpdatedAt, &a.Version, &a.DeletedAt,
+		&a.Draft, &a.ExpiresAt, &a.Status,
+		pq.Array(&a.Audience.FieldsOfStudy), pq.Array(&a.Audience.Roles), pq.Array(&eventIDs),
+		&a.Notify, &a.NotifiedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if a.Audience.FieldsOfStudy == nil {
+		a.Audience.FieldsOfStudy = []string{}
+	}
+	if a.Audience.Roles == nil {
+		a.Audience.Roles = []string{}
+	}
+	a.Audience.EventIDs = make([]int, len(eventIDs))
+	for i, id := range eventIDs {
+		a.Audience.EventIDs[i] = int(id)
+	}
+	return &a, nil
+}
 
-	rows, err := r.db.Query(query)
+func (r *AnnouncementRepository) queryAnnouncements(query string, args ...any) ([]models.Announcement, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -28,95 +85,296 @@ func (r *AnnouncementRepository) GetAll() ([]models.Announcement, error) {
 
 	var announcements []models.Announcement
 	for rows.Next() {
-		var a models.Announcement
-		err := rows.Scan(
-			&a.ID, &a.Title, &a.Content, &a.PublishedDate,
-			&a.IsPinned, &a.CreatedAt,
-		)
+		a, err := scanAnnouncement(rows)
 		if err != nil {
 			return nil, err
 		}
-		announcements = append(announcements, a)
+		announcements = append(announcements, *a)
 	}
 
-	return announcements, nil
+	return announcements, rows.Err()
 }
 
-func (r *AnnouncementRepository) GetByID(id int) (*models.Announcement, error) {
+// GetAll lists the published announcements meant for everyone or for the
+// member memberID (0 for none), pinned ones first
+func (r *AnnouncementRepository) GetAll(memberID int) ([]models.Announcement, error) {
+	defer metrics.TrackQuery("announcements", "GetAll")()
+
 	query := `
-		SELECT id, title, content, published_date, is_pinned, created_at
-		FROM announcements WHERE id = $1
+		SELECT ` + announcementColumns + `
+		FROM announcements a
+		WHERE ` + announcementPublic + `
+		  AND (` + audienceEveryone + ` OR EXISTS (
+		      SELECT 1 FROM members m
+		      WHERE m.id = $1 AND m.deleted_at IS NULL AND ` + audienceMatch + `))
+		ORDER BY is_pinned DESC, published_date DESC
 	`
 
-	var a models.Announcement
-	err := r.db.QueryRow(query, id).Scan(
-		&a.ID, &a.Title, &a.Content, &a.PublishedDate,
-		&a.IsPinned, &a.CreatedAt,
-	)
+	return r.queryAnnouncements(query, memberID)
+}
 
-	if err != nil {
-		return nil, err
-	}
+// InAudience reports whether the member memberID is in the audience of the
+// announcement id
+func (r *AnnouncementRepository) InAudience(id, memberID int) (bool, error) {
+	defer metrics.TrackQuery("announcements", "InAudience")()
 
-	return &a, nil
+	var in bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS (
+		    SELECT 1 FROM announcements a
+		    WHERE a.id = $1 AND (`+audienceEveryone+` OR EXISTS (
+		        SELECT 1 FROM members m
+		        WHERE m.id = $2 AND m.deleted_at IS NULL AND `+audienceMatch+`)))`,
+		id, memberID,
+	).Scan(&in)
+	return in, err
 }
 
-func (r *AnnouncementRepository) Create(req *models.CreateAnnouncementRequest) (*models.Announcement, error) {
+// GetByStatus lists the announcements of a status, or all of them for
+// "all", latest publication first
+func (r *AnnouncementRepository) GetByStatus(status string) ([]models.Announcement, error) {
+	defer metrics.TrackQuery("announcements", "GetByStatus")()
+
 	query := `
-		INSERT INTO announcements (title, content, is_pinned)
-		VALUES ($1, $2, $3)
-		RETURNING id, title, content, published_date, is_pinned, created_at
+		SELECT ` + announcementColumns + `
+		FROM announcements
+		WHERE deleted_at IS NULL AND ($1 = 'all' OR ` + announcementStatus + ` = $1)
+		ORDER BY published_date DESC
 	`
 
-	var a models.Announcement
-	err := r.db.QueryRow(query, req.Title, req.Content, req.IsPinned).Scan(
-		&a.ID, &a.Title, &a.Content, &a.PublishedDate,
-		&a.IsPinned, &a.CreatedAt,
-	)
+	return r.queryAnnouncements(query, status)
+}
+
+// GetByID reads an announcement whatever its status
+func (r *AnnouncementRepository) GetByID(id int) (*models.Announcement, error) {
+	defer metrics.TrackQuery("announcements", "GetByID")()
+
+	query := `SELECT ` + announcementColumns + ` FROM announcements WHERE id = $1 AND deleted_at IS NULL`
+
+	return scanAnnouncement(r.db.QueryRow(query, id))
+}
+
+// checkAudienceEvents yields ErrUnknownAudienceEvent when an event of the
+// audience is missing or in the trash
+func (r *AnnouncementRepository) checkAudienceEvents(audience *models.Audience) error {
+	if len(audience.EventIDs) == 0 {
+		return nil
+	}
+
+	var n int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM events WHERE id = ANY($1) AND deleted_at IS NULL`,
+		pq.Array(audience.EventIDs)).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n != len(audience.EventIDs) {
+		return ErrUnknownAudienceEvent
+	}
+	return nil
+}
+
+// recordPreviousAnnouncement records the state of the announcement id
+// before a write when its version has no revision yet; its author is
+// unknown
+func recordPreviousAnnouncement(tx *sql.Tx, id int) error {
+	missing, err := revisionMissing(tx, "announcement", "announcements", id)
+	if err != nil || !missing {
+		return err
+	}
 
+	a, err := scanAnnouncement(tx.QueryRow(`SELECT `+announcementColumns+` FROM announcements WHERE id = $1`, id))
 	if err != nil {
+		return err
+	}
+	return recordRevision(tx, "announcement", a.ID, a.Version, a, "")
+}
+
+// commitAnnouncementRevision records the announcement written in tx as a
+// revision by author and commits
+func commitAnnouncementRevision(tx *sql.Tx, a *models.Announcement, author string) (*models.Announcement, error) {
+	if err := recordRevision(tx, "announcement", a.ID, a.Version, a, author); err != nil {
 		return nil, err
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
 
-	return &a, nil
+// Create adds an announcement, recorded as its first revision by author;
+// ErrUnknownAudienceEvent is returned when its audience names a missing
+// event
+func (r *AnnouncementRepository) Create(req *models.CreateAnnouncementRequest, author string) (*models.Announcement, error) {
+	defer metrics.TrackQuery("announcements", "Create")()
+
+	if err := r.checkAudienceEvents(&req.Audience); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO announcements (title, content, is_pinned, draft, published_date, expires_at,
+		                           audience_fields, audience_roles, audience_event_ids, notify)
+		VALUES ($1, $2, $3, $4, COALESCE($5, NOW()), $6, $7, $8, $9, $10)
+		RETURNING ` + announcementColumns
+
+	a, err := scanAnnouncement(tx.QueryRow(query, req.Title, req.Content, req.IsPinned,
+		req.Draft, req.PublishAt, req.ExpiresAt, pq.Array(req.Audience.FieldsOfStudy),
+		pq.Array(req.Audience.Roles), pq.Array(req.Audience.EventIDs), req.Notify))
+	if err != nil {
+		return nil, err
+	}
+
+	return commitAnnouncementRevision(tx, a, author)
 }
 
-func (r *AnnouncementRepository) Update(id int, req *models.CreateAnnouncementRequest) (*models.Announcement, error) {
+// Update replaces an announcement and records the new version as a
+// revision by author. Without PublishAt the publication date is kept,
+// except for a draft being published, which goes out now unless scheduled
+// later. A non-zero expectedVersion makes the update conditional and
+// yields ErrVersionConflict on mismatch; ErrUnknownAudienceEvent is
+// returned as for Create.
+func (r *AnnouncementRepository) Update(id int, req *models.CreateAnnouncementRequest, expectedVersion int, author string) (*models.Announcement, error) {
+	defer metrics.TrackQuery("announcements", "Update")()
+
+	if err := r.checkAudienceEvents(&req.Audience); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := recordPreviousAnnouncement(tx, id); err != nil {
+		return nil, err
+	}
+
 	query := `
 		UPDATE announcements
-		SET title = $1, content = $2, is_pinned = $3
-		WHERE id = $4
-		RETURNING id, title, content, published_date, is_pinned, created_at
-	`
-
-	var a models.Announcement
-	err := r.db.QueryRow(query, req.Title, req.Content, req.IsPinned, id).Scan(
-		&a.ID, &a.Title, &a.Content, &a.PublishedDate,
-		&a.IsPinned, &a.CreatedAt,
-	)
+		SET title = $1, content = $2, is_pinned = $3, draft = $4,
+		    published_date = COALESCE($5, CASE WHEN draft AND NOT $4
+		        THEN GREATEST(published_date, NOW()) ELSE published_date END),
+		    expires_at = $6, audience_fields = $7, audience_roles = $8, audience_event_ids = $9,
+		    notify = $10, updated_at = NOW(), version = version + 1
+		WHERE id = $11 AND deleted_at IS NULL AND ($12 = 0 OR version = $12)
+		RETURNING ` + announcementColumns
 
+	a, err := scanAnnouncement(tx.QueryRow(query, req.Title, req.Content, req.IsPinned,
+		req.Draft, req.PublishAt, req.ExpiresAt, pq.Array(req.Audience.FieldsOfStudy),
+		pq.Array(req.Audience.Roles), pq.Array(req.Audience.EventIDs), req.Notify, id, expectedVersion))
+	if err == sql.ErrNoRows {
+		return nil, checkVersionConflict(r.db, "announcements", id, expectedVersion)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &a, nil
+	return commitAnnouncementRevision(tx, a, author)
 }
 
-func (r *AnnouncementRepository) Delete(id int) error {
-	query := `DELETE FROM announcements WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+// Delete moves an announcement to the trash and records the trashed
+// version as a revision by author. A non-zero expectedVersion makes the
+// deletion conditional and yields ErrVersionConflict on mismatch.
+func (r *AnnouncementRepository) Delete(id, expectedVersion int, author string) error {
+	defer metrics.TrackQuery("announcements", "Delete")()
+
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	if err := recordPreviousAnnouncement(tx, id); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE announcements
+		SET deleted_at = NOW(), updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+		RETURNING ` + announcementColumns
 
-	rowsAffected, err := result.RowsAffected()
+	a, err := scanAnnouncement(tx.QueryRow(query, id, expectedVersion))
+	if err == sql.ErrNoRows {
+		return checkVersionConflict(r.db, "announcements", id, expectedVersion)
+	}
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	_, err = commitAnnouncementRevision(tx, a, author)
+	return err
+}
+
+// GetDeleted lists the announcements currently in the trash
+func (r *AnnouncementRepository) GetDeleted() ([]models.Announcement, error) {
+	defer metrics.TrackQuery("announcements", "GetDeleted")()
+
+	query := `
+		SELECT ` + announcementColumns + `
+		FROM announcements
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	return r.queryAnnouncements(query)
+}
+
+// Restore takes an announcement out of the trash and records the restored
+// version as a revision by author
+func (r *AnnouncementRepository) Restore(id int, author string) (*models.Announcement, error) {
+	defer metrics.TrackQuery("announcements", "Restore")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	return nil
+	if err := recordPreviousAnnouncement(tx, id); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE announcements
+		SET deleted_at = NULL, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING ` + announcementColumns
+
+	a, err := scanAnnouncement(tx.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return commitAnnouncementRevision(tx, a, author)
+}
+
+// ClaimNotifications marks the published announcements awaiting their
+// notification as notified and returns them. Claiming before sending keeps
+// a notification from going out twice, at the cost of losing it if the
+// sending fails.
+func (r *AnnouncementRepository) ClaimNotifications() ([]models.Announcement, error) {
+	defer metrics.TrackQuery("announcements", "ClaimNotifications")()
+
+	query := `
+		UPDATE announcements
+		SET notified_at = NOW()
+		WHERE notify AND notified_at IS NULL AND ` + announcementPublic + `
+		RETURNING ` + announcementColumns
+
+	return r.queryAnnouncements(query)
+}
+
+// Purge permanently deletes the announcements trashed for longer than retention
+func (r *AnnouncementRepository) Purge(retention time.Duration) (int64, error) {
+	defer metrics.TrackQuery("announcements", "Purge")()
+
+	return purgeDeleted(r.db, "announcements", retention)
 }
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// nullJSON stores empty documents as SQL NULL
+func nullJSON(b []byte) any {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
+
+func (r *AuditRepository) Create(e *models.AuditEntry) error {
+	defer metrics.TrackQuery("audit_log", "Create")()
+
+	query := `
+		INSERT INTO audit_log (actor, action, entity_type, entity_id, before, after, changes, ip, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRow(
+		query, e.Actor, e.Action, e.EntityType, e.EntityID,
+		nullJSON(e.Before), nullJSON(e.After), nullJSON(e.Changes), e.IP, e.RequestID,
+	).Scan(&e.ID, &e.CreatedAt)
+}
+
+// List returns the entries matching the filter, most recent first
+func (r *AuditRepository) List(f models.AuditFilter) ([]models.AuditEntry, error) {
+	defer metrics.TrackQuery("audit_log", "List")()
+
+	var conditions []string
+	var args []any
+	add := func(cond string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, strings.Replace(cond, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if f.Actor != "" {
+		add("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.EntityType != "" {
+		add("entity_type = ?", f.EntityType)
+	}
+	if f.EntityID != 0 {
+		add("entity_id = ?", f.EntityID)
+	}
+	if f.From != nil {
+		add("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		add("created_at < ?", *f.To)
+	}
+
+	query := `
+		SELECT id, actor, action, entity_type, entity_id, before, after, changes,
+		       ip, request_id, created_at
+		FROM audit_log`
+	if len(conditions) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+	query += "\n\t\tORDER BY created_at DESC, id DESC" +
+		"\n\t\tLIMIT $" + strconv.Itoa(len(args)-1) + " OFFSET $" + strconv.Itoa(len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after, changes []byte
+		err := rows.Scan(
+			&e.ID, &e.Actor, &e.Action, &e.EntityType, &e.EntityID,
+			&before, &after, &changes, &e.IP, &e.RequestID, &e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		e.Before, e.After, e.Changes = before, after, changes
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
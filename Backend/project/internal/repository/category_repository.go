@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"errors"
+)
+
+// ErrUnknownCategory is returned when an event references a missing category
+var ErrUnknownCategory = errors.New("catégorie inconnue")
+
+const categoryColumns = `id, name, slug, description, color, created_at, updated_at`
+
+type CategoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+func scanCategory(s scanner) (*models.Category, error) {
+	var c models.Category
+	err := s.Scan(&c.ID, &c.Name, &c.Slug, &c.Description, &c.Color, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetAll lists the categories with their number of upcoming events. A
+// non-empty tag only counts the events carrying it.
+func (r *CategoryRepository) GetAll(tag string) ([]models.CategoryCount, error) {
+	defer metrics.TrackQuery("categories", "GetAll")()
+
+	query := `
+		SELECT c.id, c.name, c.slug, c.description, c.color, c.created_at, c.updated_at,
+		       COUNT(e.id)
+		FROM categories c
+		LEFT JOIN event_categories ec ON ec.category_id = c.id
+		LEFT JOIN events e ON e.id = ec.event_id
+		     AND ` + upcomingEvent + ` AND e.deleted_at IS NULL
+		     AND ($1 = '' OR EXISTS (
+		         SELECT 1 FROM event_tags et JOIN tags t ON t.id = et.tag_id
+		         WHERE et.event_id = e.id AND t.name = $1))
+		GROUP BY c.id
+		ORDER BY c.name
+	`
+
+	rows, err := r.db.Query(query, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.CategoryCount{}
+	for rows.Next() {
+		var c models.CategoryCount
+		err := rows.Scan(&c.ID, &c.Name, &c.Slug, &c.Description, &c.Color,
+			&c.CreatedAt, &c.UpdatedAt, &c.EventCount)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+
+	return categories, rows.Err()
+}
+
+func (r *CategoryRepository) GetByID(id int) (*models.Category, error) {
+	defer metrics.TrackQuery("categories", "GetByID")()
+
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE id = $1`
+
+	return scanCategory(r.db.QueryRow(query, id))
+}
+
+func (r *CategoryRepository) Create(req *models.CreateCategoryRequest) (*models.Category, error) {
+	defer metrics.TrackQuery("categories", "Create")()
+
+	query := `
+		INSERT INTO categories (name, slug, description, color)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + categoryColumns
+
+	return scanCategory(r.db.QueryRow(query, req.Name, req.Slug, req.Description, req.Color))
+}
+
+func (r *CategoryRepository) Update(id int, req *models.CreateCategoryRequest) (*models.Category, error) {
+	defer metrics.TrackQuery("categories", "Update")()
+
+	query := `
+		UPDATE categories
+		SET name = $1, slug = $2, description = $3, color = $4, updated_at = NOW()
+		WHERE id = $5
+		RETURNING ` + categoryColumns
+
+	return scanCategory(r.db.QueryRow(query, req.Name, req.Slug, req.Description, req.Color, id))
+}
+
+// Delete removes a category; its events simply lose it
+func (r *CategoryRepository) Delete(id int) error {
+	defer metrics.TrackQuery("categories", "Delete")()
+
+	result, err := r.db.Exec(`DELETE FROM categories WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
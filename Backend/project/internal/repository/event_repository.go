@@ -1,10 +1,27 @@
 package repository
 
 import (
+	"beautiful-minds/backend/project/internal/metrics"
 	"beautiful-minds/backend/project/internal/models"
 	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+const eventColumns = `id, title, description, date, end_date, timezone, location, image_url,
+	max_participants, created_at, updated_at, version, deleted_at,
+	recurrence_rule, recurrence_exdates, venue_id, guest_capacity, image_id`
+
+// upcomingEvent matches events (aliased e) that are still to come: single
+// events not yet over and series with occurrences left. Timestamps are UTC,
+// the session timezone set by database.DSN.
+const upcomingEvent = `(e.end_date >= NOW() OR (e.recurrence_rule IS NOT NULL
+	AND (e.recurrence_end IS NULL OR e.recurrence_end + (e.end_date - e.date) >= NOW())))`
+
+// pgTimestampLayout parses TIMESTAMP values read as text (array elements)
+const pgTimestampLayout = "2006-01-02 15:04:05.999999999"
+
 type EventRepository struct {
 	db *sql.DB
 }
@@ -13,16 +30,47 @@ func NewEventRepository(db *sql.DB) *EventRepository {
 	return &EventRepository{db: db}
 }
 
-func (r *EventRepository) GetAll() ([]models.Event, error) {
-	query := `
-		SELECT id, title, description, date, location, image_url, 
-		       max_participants, created_at
-		FROM events
-		WHERE date >= NOW()
-		ORDER BY date ASC
-	`
+func scanEvent(s scanner) (*models.Event, error) {
+	var e models.Event
+	var exdates []string
+	err := s.Scan(
+		&e.ID, &e.Title, &e.Description, &e.Date, &e.EndDate, &e.Timezone, &e.Location,
+		&e.ImageURL, &e.MaxParticipants, &e.CreatedAt, &e.UpdatedAt, &e.Version, &e.DeletedAt,
+		&e.RecurrenceRule, pq.Array(&exdates), &e.VenueID, &e.GuestCapacity, &e.ImageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	e.ExceptionDates = []time.Time{}
+	for _, d := range exdates {
+		t, err := time.Parse(pgTimestampLayout, d)
+		if err != nil {
+			return nil, err
+		}
+		e.ExceptionDates = append(e.ExceptionDates, t)
+	}
+	return &e, nil
+}
+
+// exdatesArray formats exception dates for a TIMESTAMP[] column
+func exdatesArray(dates []time.Time) any {
+	values := make([]string, len(dates))
+	for i, d := range dates {
+		values[i] = d.UTC().Format(time.RFC3339)
+	}
+	return pq.Array(values)
+}
 
-	rows, err := r.db.Query(query)
+// setRecurrenceEnd stores the start of the last occurrence of a series so
+// that listings can tell whether it is over
+func setRecurrenceEnd(tx *sql.Tx, e *models.Event) error {
+	_, err := tx.Exec(`UPDATE events SET recurrence_end = $1 WHERE id = $2`, e.SeriesEnd(), e.ID)
+	return err
+}
+
+func (r *EventRepository) queryEvents(query string, args ...any) ([]models.Event, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -30,103 +78,459 @@ func (r *EventRepository) GetAll() ([]models.Event, error) {
 
 	var events []models.Event
 	for rows.Next() {
-		var e models.Event
-		err := rows.Scan(
-			&e.ID, &e.Title, &e.Description, &e.Date, &e.Location,
-			&e.ImageURL, &e.MaxParticipants, &e.CreatedAt,
-		)
+		e, err := scanEvent(rows)
 		if err != nil {
 			return nil, err
 		}
-		events = append(events, e)
+		events = append(events, *e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
+	if err := loadEventRelations(r.db, events); err != nil {
+		return nil, err
+	}
 	return events, nil
 }
 
-func (r *EventRepository) GetByID(id int) (*models.Event, error) {
-	query := `
-		SELECT id, title, description, date, location, image_url,
-		       max_participants, created_at
-		FROM events WHERE id = $1
-	`
+// loadEventRelations fills the categories, tags, venues, images and
+// ratings of the given events
+func loadEventRelations(q querier, events []models.Event) error {
+	if err := loadEventTaxonomy(q, events); err != nil {
+		return err
+	}
+	if err := loadEventVenues(q, events); err != nil {
+		return err
+	}
+	if err := loadEventImages(q, events); err != nil {
+		return err
+	}
+	return loadEventRatings(q, events)
+}
 
-	var e models.Event
-	err := r.db.QueryRow(query, id).Scan(
-		&e.ID, &e.Title, &e.Description, &e.Date, &e.Location,
-		&e.ImageURL, &e.MaxParticipants, &e.CreatedAt,
-	)
+// loadEventRatings fills the average rating of the given events from the
+// responses to their feedback survey
+func loadEventRatings(q querier, events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
 
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = int64(e.ID)
+	}
+
+	rows, err := q.Query(`
+		SELECT s.event_id, AVG(a.rating), COUNT(a.rating)
+		FROM surveys s
+		JOIN survey_responses sr ON sr.survey_id = s.id
+		JOIN survey_answers a ON a.response_id = sr.id
+		WHERE s.event_id = ANY($1) AND a.rating IS NOT NULL
+		GROUP BY s.event_id`, pq.Array(ids))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer rows.Close()
 
-	return &e, nil
+	type rating struct {
+		average float64
+		count   int
+	}
+	ratings := make(map[int]rating)
+	for rows.Next() {
+		var eventID int
+		var rt rating
+		if err := rows.Scan(&eventID, &rt.average, &rt.count); err != nil {
+			return err
+		}
+		ratings[eventID] = rt
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range events {
+		if rt, ok := ratings[events[i].ID]; ok {
+			average := rt.average
+			events[i].AverageRating = &average
+			events[i].RatingCount = rt.count
+		}
+	}
+	return nil
 }
 
-func (r *EventRepository) Create(req *models.CreateEventRequest) (*models.Event, error) {
-	query := `
-		INSERT INTO events (title, description, date, location, image_url, max_participants)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, title, description, date, location, image_url, 
-		          max_participants, created_at
-	`
+// loadEventVenues fills the venue of the given events that book one
+func loadEventVenues(q querier, events []models.Event) error {
+	var ids []int64
+	for _, e := range events {
+		if e.VenueID != nil {
+			ids = append(ids, int64(*e.VenueID))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
 
-	var e models.Event
-	err := r.db.QueryRow(
-		query, req.Title, req.Description, req.Date,
-		req.Location, req.ImageURL, req.MaxParticipants,
-	).Scan(
-		&e.ID, &e.Title, &e.Description, &e.Date, &e.Location,
-		&e.ImageURL, &e.MaxParticipants, &e.CreatedAt,
-	)
+	rows, err := q.Query(`SELECT `+venueColumns+` FROM venues WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	venues := make(map[int]*models.Venue)
+	for rows.Next() {
+		v, err := scanVenue(rows)
+		if err != nil {
+			return err
+		}
+		venues[v.ID] = v
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range events {
+		if events[i].VenueID != nil {
+			events[i].Venue = venues[*events[i].VenueID]
+		}
+	}
+	return nil
+}
+
+// loadEventImages fills the uploaded image of the given events that show one
+func loadEventImages(q querier, events []models.Event) error {
+	var ids []int64
+	for _, e := range events {
+		if e.ImageID != nil {
+			ids = append(ids, int64(*e.ImageID))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
 
+	rows, err := q.Query(`SELECT `+mediaColumns+` FROM media WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	images := make(map[int]*models.Media)
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return err
+		}
+		images[m.ID] = m
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range events {
+		if events[i].ImageID != nil {
+			events[i].Image = images[*events[i].ImageID]
+		}
+	}
+	return nil
+}
+
+// loadEventTaxonomy fills the categories and tags of the given events
+func loadEventTaxonomy(q querier, events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(events))
+	byID := make(map[int]*models.Event, len(events))
+	for i := range events {
+		events[i].Categories = []models.Category{}
+		events[i].Tags = []string{}
+		ids[i] = int64(events[i].ID)
+		byID[events[i].ID] = &events[i]
+	}
+
+	rows, err := q.Query(`
+		SELECT ec.event_id, c.id, c.name, c.slug, c.description, c.color, c.created_at, c.updated_at
+		FROM event_categories ec
+		JOIN categories c ON c.id = ec.category_id
+		WHERE ec.event_id = ANY($1)
+		ORDER BY c.name`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eventID int
+		var c models.Category
+		err := rows.Scan(&eventID, &c.ID, &c.Name, &c.Slug, &c.Description, &c.Color, &c.CreatedAt, &c.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		byID[eventID].Categories = append(byID[eventID].Categories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tagRows, err := q.Query(`
+		SELECT et.event_id, t.name
+		FROM event_tags et
+		JOIN tags t ON t.id = et.tag_id
+		WHERE et.event_id = ANY($1)
+		ORDER BY t.name`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var eventID int
+		var tag string
+		if err := tagRows.Scan(&eventID, &tag); err != nil {
+			return err
+		}
+		byID[eventID].Tags = append(byID[eventID].Tags, tag)
+	}
+
+	return tagRows.Err()
+}
+
+// withEventRelations completes a single scanned event with its relations,
+// read through q so that a transaction sees its own writes
+func withEventRelations(q querier, e *models.Event, err error) (*models.Event, error) {
 	if err != nil {
 		return nil, err
 	}
 
-	return &e, nil
+	events := []models.Event{*e}
+	if err := loadEventRelations(q, events); err != nil {
+		return nil, err
+	}
+	return &events[0], nil
+}
+
+// setTaxonomy replaces the categories and tags of an event when the request
+// carries them
+func setTaxonomy(tx *sql.Tx, eventID int, req *models.CreateEventRequest) error {
+	if req.CategoryIDs != nil {
+		if _, err := tx.Exec(`DELETE FROM event_categories WHERE event_id = $1`, eventID); err != nil {
+			return err
+		}
+
+		ids := make([]int64, len(*req.CategoryIDs))
+		for i, id := range *req.CategoryIDs {
+			ids[i] = int64(id)
+		}
+		result, err := tx.Exec(`
+			INSERT INTO event_categories (event_id, category_id)
+			SELECT $1, id FROM categories WHERE id = ANY($2)`, eventID, pq.Array(ids))
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if int(n) != len(ids) {
+			return ErrUnknownCategory
+		}
+	}
+
+	if req.Tags != nil {
+		if _, err := tx.Exec(`DELETE FROM event_tags WHERE event_id = $1`, eventID); err != nil {
+			return err
+		}
+
+		tags := pq.Array(*req.Tags)
+		if _, err := tx.Exec(`INSERT INTO tags (name) SELECT UNNEST($1::text[]) ON CONFLICT (name) DO NOTHING`, tags); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT INTO event_tags (event_id, tag_id)
+			SELECT $1, id FROM tags WHERE name = ANY($2)`, eventID, tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (r *EventRepository) RegisterMember(eventID, memberID int) error {
+// recordPreviousEvent records the state of the event id before a write
+// when its version has no revision yet; its author is unknown
+func recordPreviousEvent(tx *sql.Tx, id int) error {
+	missing, err := revisionMissing(tx, "event", "events", id)
+	if err != nil || !missing {
+		return err
+	}
+
+	e, err := scanEvent(tx.QueryRow(`SELECT `+eventColumns+` FROM events WHERE id = $1`, id))
+	if e, err = withEventRelations(tx, e, err); err != nil {
+		return err
+	}
+	return recordRevision(tx, "event", e.ID, e.Version, e, "")
+}
+
+// commitEventRevision completes the event written in tx with its relations,
+// records it as a revision by author and commits
+func commitEventRevision(tx *sql.Tx, e *models.Event, author string) (*models.Event, error) {
+	e, err := withEventRelations(tx, e, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordRevision(tx, "event", e.ID, e.Version, e, author); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// GetAll lists the upcoming events, or the past ones latest first,
+// optionally narrowed to a category slug and a tag
+func (r *EventRepository) GetAll(filter models.EventFilter) ([]models.Event, error) {
+	defer metrics.TrackQuery("events", "GetAll")()
+
+	period, order := upcomingEvent, "ASC"
+	if filter.Past {
+		period, order = "NOT "+upcomingEvent, "DESC"
+	}
+
 	query := `
-		INSERT INTO event_registrations (event_id, member_id)
-		VALUES ($1, $2)
+		SELECT ` + eventColumns + `
+		FROM events e
+		WHERE ` + period + ` AND deleted_at IS NULL
+		  AND ($1 = '' OR EXISTS (
+		      SELECT 1 FROM event_categories ec JOIN categories c ON c.id = ec.category_id
+		      WHERE ec.event_id = e.id AND c.slug = $1))
+		  AND ($2 = '' OR EXISTS (
+		      SELECT 1 FROM event_tags et JOIN tags t ON t.id = et.tag_id
+		      WHERE et.event_id = e.id AND t.name = $2))
+		ORDER BY date ` + order + `
 	`
 
-	_, err := r.db.Exec(query, eventID, memberID)
-	return err
+	return r.queryEvents(query, filter.Category, filter.Tag)
 }
 
-func (r *EventRepository) Update(id int, req *models.CreateEventRequest) (*models.Event, error) {
+// GetTags lists the tags of upcoming events with their number of events. A
+// non-empty category slug only counts the events in it.
+func (r *EventRepository) GetTags(category string) ([]models.TagCount, error) {
+	defer metrics.TrackQuery("events", "GetTags")()
+
 	query := `
-		UPDATE events
-		SET title = $1, description = $2, date = $3, location = $4, 
-		    image_url = $5, max_participants = $6
-		WHERE id = $7
-		RETURNING id, title, description, date, location, image_url, 
-		          max_participants, created_at
+		SELECT t.name, COUNT(*)
+		FROM tags t
+		JOIN event_tags et ON et.tag_id = t.id
+		JOIN events e ON e.id = et.event_id
+		WHERE ` + upcomingEvent + ` AND e.deleted_at IS NULL
+		  AND ($1 = '' OR EXISTS (
+		      SELECT 1 FROM event_categories ec JOIN categories c ON c.id = ec.category_id
+		      WHERE ec.event_id = e.id AND c.slug = $1))
+		GROUP BY t.name
+		ORDER BY COUNT(*) DESC, t.name
 	`
 
-	var e models.Event
-	err := r.db.QueryRow(
-		query, req.Title, req.Description, req.Date, req.Location,
-		req.ImageURL, req.MaxParticipants, id,
-	).Scan(
-		&e.ID, &e.Title, &e.Description, &e.Date, &e.Location,
-		&e.ImageURL, &e.MaxParticipants, &e.CreatedAt,
-	)
+	rows, err := r.db.Query(query, category)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.TagCount{}
+	for rows.Next() {
+		var t models.TagCount
+		if err := rows.Scan(&t.Name, &t.EventCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, rows.Err()
+}
+
+func (r *EventRepository) GetByID(id int) (*models.Event, error) {
+	defer metrics.TrackQuery("events", "GetByID")()
+
+	query := `SELECT ` + eventColumns + ` FROM events WHERE id = $1 AND deleted_at IS NULL`
+
+	e, err := scanEvent(r.db.QueryRow(query, id))
+	return withEventRelations(r.db, e, err)
+}
+
+// bookVenue locks the venue requested for an event and returns the location
+// to store: the venue name, or the free text without venue
+func bookVenue(tx *sql.Tx, req *models.CreateEventRequest) (string, error) {
+	if req.VenueID == nil {
+		return req.Location, nil
+	}
+	venue, err := lockVenue(tx, *req.VenueID)
+	if err != nil {
+		return "", err
+	}
+	return venue.Name, nil
+}
 
+// Create inserts an event along with its categories and tags, recorded as
+// its first revision by author. ErrUnknownCategory and ErrUnknownVenue are
+// returned for missing references, a *BookingConflictError when the venue
+// is already booked.
+func (r *EventRepository) Create(req *models.CreateEventRequest, author string) (*models.Event, error) {
+	defer metrics.TrackQuery("events", "Create")()
+
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return &e, nil
+	location, err := bookVenue(tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO events (title, description, date, end_date, timezone, location, venue_id,
+		                    image_url, max_participants, guest_capacity, recurrence_rule, recurrence_exdates)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING ` + eventColumns
+
+	e, err := scanEvent(tx.QueryRow(
+		query, req.Title, req.Description, req.Start, req.End, req.Timezone,
+		location, req.VenueID, req.ImageURL, req.MaxParticipants, req.GuestCapacity,
+		req.RecurrenceRule, exdatesArray(req.ExceptionDates),
+	))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBookings(tx, e); err != nil {
+		return nil, err
+	}
+	if err := setRecurrenceEnd(tx, e); err != nil {
+		return nil, err
+	}
+	if err := setTaxonomy(tx, e.ID, req); err != nil {
+		return nil, err
+	}
+
+	return commitEventRevision(tx, e, author)
 }
 
-func (r *EventRepository) Delete(id int) error {
-	query := `DELETE FROM events WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+// RegisterMember registers a member, to a given occurrence for recurring
+// events; sql.ErrNoRows means the event or the member does not exist
+func (r *EventRepository) RegisterMember(eventID, memberID int, occurrenceStart *time.Time) error {
+	defer metrics.TrackQuery("events", "RegisterMember")()
+
+	// Les événements et membres dans la corbeille n'acceptent pas d'inscription
+	query := `
+		INSERT INTO event_registrations (event_id, member_id, occurrence_start)
+		SELECT $1, $2, $3
+		WHERE EXISTS (SELECT 1 FROM events WHERE id = $1 AND deleted_at IS NULL)
+		  AND EXISTS (SELECT 1 FROM members WHERE id = $2 AND deleted_at IS NULL)
+	`
+
+	result, err := r.db.Exec(query, eventID, memberID, occurrenceStart)
 	if err != nil {
 		return err
 	}
@@ -135,10 +539,249 @@ func (r *EventRepository) Delete(id int) error {
 	if err != nil {
 		return err
 	}
-
 	if rowsAffected == 0 {
 		return sql.ErrNoRows
 	}
 
+	metrics.RecordRegistration(metrics.RegistrationMember)
 	return nil
 }
+
+// SetAttendance marks the given members as having attended the event (or
+// the occurrence of a series), or clears the mark. Members who are not
+// registered are left out of the returned count.
+func (r *EventRepository) SetAttendance(eventID int, occurrenceStart *time.Time, memberIDs []int, attended bool) (int64, error) {
+	defer metrics.TrackQuery("events", "SetAttendance")()
+
+	ids := make([]int64, len(memberIDs))
+	for i, id := range memberIDs {
+		ids[i] = int64(id)
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE event_registrations
+		SET attended_at = CASE WHEN $4 THEN COALESCE(attended_at, NOW()) END
+		WHERE event_id = $1 AND occurrence_start IS NOT DISTINCT FROM $2 AND member_id = ANY($3)`,
+		eventID, occurrenceStart, pq.Array(ids), attended)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Update replaces an event and records the new version as a revision by
+// author. A non-zero expectedVersion makes the update conditional and
+// yields ErrVersionConflict on mismatch. References and bookings are
+// checked as in Create.
+func (r *EventRepository) Update(id int, req *models.CreateEventRequest, expectedVersion int, author string) (*models.Event, error) {
+	defer metrics.TrackQuery("events", "Update")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := recordPreviousEvent(tx, id); err != nil {
+		return nil, err
+	}
+
+	location, err := bookVenue(tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE events
+		SET title = $1, description = $2, date = $3, end_date = $4, timezone = $5,
+		    location = $6, venue_id = $7, image_url = $8, max_participants = $9,
+		    image_id = CASE WHEN image_url IS NOT DISTINCT FROM $8 THEN image_id END,
+		    guest_capacity = $10, recurrence_rule = $11, recurrence_exdates = $12,
+		    updated_at = NOW(), version = version + 1
+		WHERE id = $13 AND deleted_at IS NULL AND ($14 = 0 OR version = $14)
+		RETURNING ` + eventColumns
+
+	e, err := scanEvent(tx.QueryRow(
+		query, req.Title, req.Description, req.Start, req.End, req.Timezone, location,
+		req.VenueID, req.ImageURL, req.MaxParticipants, req.GuestCapacity,
+		req.RecurrenceRule, exdatesArray(req.ExceptionDates), id, expectedVersion,
+	))
+	if err == sql.ErrNoRows {
+		return nil, checkVersionConflict(r.db, "events", id, expectedVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBookings(tx, e); err != nil {
+		return nil, err
+	}
+	if err := setRecurrenceEnd(tx, e); err != nil {
+		return nil, err
+	}
+	if err := setTaxonomy(tx, id, req); err != nil {
+		return nil, err
+	}
+
+	return commitEventRevision(tx, e, author)
+}
+
+// SetImage shows an uploaded image on an event, or none when image is nil,
+// and bumps its version and records a revision like Update
+func (r *EventRepository) SetImage(id int, image *models.Media, expectedVersion int, author string) (*models.Event, error) {
+	defer metrics.TrackQuery("events", "SetImage")()
+
+	var imageID *int
+	var imageURL *string
+	if image != nil {
+		imageID, imageURL = &image.ID, &image.URL
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := recordPreviousEvent(tx, id); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE events
+		SET image_id = $1, image_url = $2, updated_at = NOW(), version = version + 1
+		WHERE id = $3 AND deleted_at IS NULL AND ($4 = 0 OR version = $4)
+		RETURNING ` + eventColumns
+
+	e, err := scanEvent(tx.QueryRow(query, imageID, imageURL, id, expectedVersion))
+	if err == sql.ErrNoRows {
+		return nil, checkVersionConflict(r.db, "events", id, expectedVersion)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return commitEventRevision(tx, e, author)
+}
+
+// Delete moves an event to the trash and records the trashed version as a
+// revision by author. A non-zero expectedVersion makes the deletion
+// conditional and yields ErrVersionConflict on mismatch.
+func (r *EventRepository) Delete(id, expectedVersion int, author string) error {
+	defer metrics.TrackQuery("events", "Delete")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := recordPreviousEvent(tx, id); err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE events
+		SET deleted_at = NOW(), updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+		RETURNING ` + eventColumns
+
+	e, err := scanEvent(tx.QueryRow(query, id, expectedVersion))
+	if err == sql.ErrNoRows {
+		return checkVersionConflict(r.db, "events", id, expectedVersion)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = commitEventRevision(tx, e, author)
+	return err
+}
+
+// GetDeleted lists the events currently in the trash
+func (r *EventRepository) GetDeleted() ([]models.Event, error) {
+	defer metrics.TrackQuery("events", "GetDeleted")()
+
+	query := `
+		SELECT ` + eventColumns + `
+		FROM events
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	return r.queryEvents(query)
+}
+
+// Restore takes an event out of the trash and records the restored version
+// as a revision by author. Its venue may have been booked in the meantime:
+// a *BookingConflictError is returned on overlap.
+func (r *EventRepository) Restore(id int, author string) (*models.Event, error) {
+	defer metrics.TrackQuery("events", "Restore")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockEventVenue(tx, id); err != nil {
+		return nil, err
+	}
+	if err := recordPreviousEvent(tx, id); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE events
+		SET deleted_at = NULL, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING ` + eventColumns
+
+	e, err := scanEvent(tx.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBookings(tx, e); err != nil {
+		return nil, err
+	}
+
+	return commitEventRevision(tx, e, author)
+}
+
+// Purge permanently deletes the events trashed for longer than retention
+func (r *EventRepository) Purge(retention time.Duration) (int64, error) {
+	defer metrics.TrackQuery("events", "Purge")()
+
+	return purgeDeleted(r.db, "events", retention)
+}
+
+// GetRegistrationsByMember lists the registrations of a member, including
+// those for events in the trash
+func (r *EventRepository) GetRegistrationsByMember(memberID int) ([]models.RegistrationRecord, error) {
+	defer metrics.TrackQuery("events", "GetRegistrationsByMember")()
+
+	query := `
+		SELECT e.id, e.title, COALESCE(er.occurrence_start, e.date), er.registered_at, er.occurrence_start,
+		       er.attended_at
+		FROM event_registrations er
+		JOIN events e ON e.id = er.event_id
+		WHERE er.member_id = $1
+		ORDER BY er.registered_at DESC
+	`
+
+	rows, err := r.db.Query(query, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var registrations []models.RegistrationRecord
+	for rows.Next() {
+		var reg models.RegistrationRecord
+		if err := rows.Scan(&reg.EventID, &reg.EventTitle, &reg.EventDate, &reg.RegisteredAt, &reg.OccurrenceStart, &reg.AttendedAt); err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, reg)
+	}
+
+	return registrations, rows.Err()
+}
@@ -0,0 +1,440 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrGuestsClosed is returned for events without guest seats
+	ErrGuestsClosed = errors.New("événement fermé aux invités")
+	// ErrGuestCapacity is returned when every guest seat is taken
+	ErrGuestCapacity = errors.New("plus de place pour les invités")
+	// ErrAlreadyConfirmed is returned when the guest already holds a seat
+	ErrAlreadyConfirmed = errors.New("inscription déjà confirmée")
+	// ErrGuestConverted is returned for guests who have become members
+	ErrGuestConverted = errors.New("cet invité est devenu membre")
+	// ErrGuestNotVerified is returned when converting a guest whose email
+	// has never been verified
+	ErrGuestNotVerified = errors.New("email de l'invité non vérifié")
+	// ErrGuestEmailMismatch is returned when converting a guest into a
+	// member whose email differs
+	ErrGuestEmailMismatch = errors.New("l'email du membre ne correspond pas à celui de l'invité")
+)
+
+const guestColumns = `id, first_name, last_name, email, email_verified_at, member_id, converted_at,
+	created_at, updated_at`
+
+const guestRegistrationColumns = `gr.id, gr.event_id, e.title, gr.guest_id, gr.occurrence_start,
+	gr.registered_at, gr.confirmed_at`
+
+// GuestRepository stores the non-members registered to public events
+type GuestRepository struct {
+	db *sql.DB
+}
+
+func NewGuestRepository(db *sql.DB) *GuestRepository {
+	return &GuestRepository{db: db}
+}
+
+func scanGuest(s scanner) (*models.Guest, error) {
+	var g models.Guest
+	err := s.Scan(&g.ID, &g.FirstName, &g.LastName, &g.Email, &g.EmailVerifiedAt, &g.MemberID,
+		&g.ConvertedAt, &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func scanGuestRegistration(s scanner) (*models.GuestRegistration, error) {
+	var reg models.GuestRegistration
+	err := s.Scan(&reg.ID, &reg.EventID, &reg.EventTitle, &reg.GuestID, &reg.OccurrenceStart,
+		&reg.RegisteredAt, &reg.ConfirmedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &reg, nil
+}
+
+func (r *GuestRepository) GetAll() ([]models.Guest, error) {
+	defer metrics.TrackQuery("guests", "GetAll")()
+
+	rows, err := r.db.Query(`SELECT ` + guestColumns + ` FROM guests ORDER BY last_name, first_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guests := []models.Guest{}
+	for rows.Next() {
+		g, err := scanGuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		guests = append(guests, *g)
+	}
+
+	return guests, rows.Err()
+}
+
+// GetByID reads a guest with its registrations
+func (r *GuestRepository) GetByID(id int) (*models.Guest, error) {
+	defer metrics.TrackQuery("guests", "GetByID")()
+
+	g, err := scanGuest(r.db.QueryRow(`SELECT `+guestColumns+` FROM guests WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadRegistrations(g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// GetByEmail finds a guest not converted into a member by email
+func (r *GuestRepository) GetByEmail(email string) (*models.Guest, error) {
+	defer metrics.TrackQuery("guests", "GetByEmail")()
+
+	return scanGuest(r.db.QueryRow(`
+		SELECT `+guestColumns+` FROM guests
+		WHERE LOWER(email) = LOWER($1) AND member_id IS NULL`, email))
+}
+
+// GetByMember lists the guests converted into a member, with their
+// remaining registrations
+func (r *GuestRepository) GetByMember(memberID int) ([]models.Guest, error) {
+	defer metrics.TrackQuery("guests", "GetByMember")()
+
+	rows, err := r.db.Query(`SELECT `+guestColumns+` FROM guests WHERE member_id = $1 ORDER BY converted_at`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	guests := []models.Guest{}
+	for rows.Next() {
+		g, err := scanGuest(rows)
+		if err != nil {
+			return nil, err
+		}
+		guests = append(guests, *g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range guests {
+		if err := r.loadRegistrations(&guests[i]); err != nil {
+			return nil, err
+		}
+	}
+	return guests, nil
+}
+
+func (r *GuestRepository) loadRegistrations(g *models.Guest) error {
+	rows, err := r.db.Query(`
+		SELECT `+guestRegistrationColumns+`
+		FROM guest_registrations gr
+		JOIN events e ON e.id = gr.event_id
+		WHERE gr.guest_id = $1
+		ORDER BY gr.registered_at DESC`, g.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	g.Registrations = []models.GuestRegistration{}
+	for rows.Next() {
+		reg, err := scanGuestRegistration(rows)
+		if err != nil {
+			return err
+		}
+		g.Registrations = append(g.Registrations, *reg)
+	}
+
+	return rows.Err()
+}
+
+// GetByEvent lists the guest registrations of an event, pending ones
+// included, with the identity of the guests
+func (r *GuestRepository) GetByEvent(eventID int) ([]models.GuestRegistration, error) {
+	defer metrics.TrackQuery("guests", "GetByEvent")()
+
+	rows, err := r.db.Query(`
+		SELECT `+guestRegistrationColumns+`,
+		       g.id, g.first_name, g.last_name, g.email, g.email_verified_at, g.member_id,
+		       g.converted_at, g.created_at, g.updated_at
+		FROM guest_registrations gr
+		JOIN events e ON e.id = gr.event_id
+		JOIN guests g ON g.id = gr.guest_id
+		WHERE gr.event_id = $1
+		ORDER BY gr.occurrence_start NULLS FIRST, gr.registered_at`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	registrations := []models.GuestRegistration{}
+	for rows.Next() {
+		var reg models.GuestRegistration
+		var g models.Guest
+		err := rows.Scan(
+			&reg.ID, &reg.EventID, &reg.EventTitle, &reg.GuestID, &reg.OccurrenceStart,
+			&reg.RegisteredAt, &reg.ConfirmedAt,
+			&g.ID, &g.FirstName, &g.LastName, &g.Email, &g.EmailVerifiedAt, &g.MemberID,
+			&g.ConvertedAt, &g.CreatedAt, &g.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reg.Guest = &g
+		registrations = append(registrations, reg)
+	}
+
+	return registrations, rows.Err()
+}
+
+// lockGuestSeats locks the event until the transaction ends and returns
+// an error when no guest seat is left for the occurrence. The seats of
+// converted guests, now held by members, still count.
+func lockGuestSeats(tx *sql.Tx, eventID int, occurrenceStart *time.Time) error {
+	var capacity int
+	err := tx.QueryRow(`
+		SELECT guest_capacity FROM events
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE`, eventID).Scan(&capacity)
+	if err != nil {
+		return err
+	}
+	if capacity == 0 {
+		return ErrGuestsClosed
+	}
+
+	var taken int
+	err = tx.QueryRow(`
+		SELECT (SELECT COUNT(*) FROM guest_registrations
+		        WHERE event_id = $1 AND occurrence_start IS NOT DISTINCT FROM $2 AND confirmed_at IS NOT NULL)
+		     + (SELECT COUNT(*) FROM event_registrations
+		        WHERE event_id = $1 AND occurrence_start IS NOT DISTINCT FROM $2 AND guest_seat)`,
+		eventID, occurrenceStart).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken >= capacity {
+		return ErrGuestCapacity
+	}
+	return nil
+}
+
+// CreatePending records a registration awaiting the confirmation of the
+// guest's email. A pending registration to the same occurrence gets the new
+// token; a confirmed one yields ErrAlreadyConfirmed.
+func (r *GuestRepository) CreatePending(eventID int, req *models.GuestRegistrationRequest, tokenHash string, expiresAt time.Time) (*models.Guest, error) {
+	defer metrics.TrackQuery("guests", "CreatePending")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := lockGuestSeats(tx, eventID, req.OccurrenceStart); err != nil {
+		return nil, err
+	}
+
+	// Le nom d'un invité vérifié ne peut plus être changé sans son email
+	g, err := scanGuest(tx.QueryRow(`
+		INSERT INTO guests (first_name, last_name, email)
+		VALUES ($1, $2, $3)
+		ON CONFLICT ((LOWER(email))) DO UPDATE
+		SET first_name = CASE WHEN guests.email_verified_at IS NULL THEN EXCLUDED.first_name ELSE guests.first_name END,
+		    last_name = CASE WHEN guests.email_verified_at IS NULL THEN EXCLUDED.last_name ELSE guests.last_name END,
+		    updated_at = NOW()
+		RETURNING `+guestColumns, req.FirstName, req.LastName, req.Email))
+	if err != nil {
+		return nil, err
+	}
+	if g.MemberID != nil {
+		return nil, ErrGuestConverted
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO guest_registrations (event_id, guest_id, occurrence_start, token_hash, token_expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (event_id, guest_id, COALESCE(occurrence_start, 'epoch')) DO UPDATE
+		SET token_hash = EXCLUDED.token_hash, token_expires_at = EXCLUDED.token_expires_at
+		WHERE guest_registrations.confirmed_at IS NULL
+		RETURNING id`, eventID, g.ID, req.OccurrenceStart, tokenHash, expiresAt.UTC()).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrAlreadyConfirmed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Confirm takes the seat of the pending registration matching the token and
+// marks the guest's email as verified. sql.ErrNoRows means the token is
+// unknown or expired.
+func (r *GuestRepository) Confirm(tokenHash string) (*models.GuestRegistration, error) {
+	defer metrics.TrackQuery("guests", "Confirm")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reg, err := scanGuestRegistration(tx.QueryRow(`
+		SELECT `+guestRegistrationColumns+`
+		FROM guest_registrations gr
+		JOIN events e ON e.id = gr.event_id
+		WHERE gr.token_hash = $1 AND gr.token_expires_at > NOW() AND gr.confirmed_at IS NULL`, tokenHash))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockGuestSeats(tx, reg.EventID, reg.OccurrenceStart); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(`
+		UPDATE guest_registrations
+		SET confirmed_at = NOW(), token_hash = NULL, token_expires_at = NULL
+		WHERE id = $1
+		RETURNING confirmed_at`, reg.ID).Scan(&reg.ConfirmedAt)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE guests SET email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+		WHERE id = $1`, reg.GuestID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	metrics.RecordRegistration(metrics.RegistrationGuest)
+	return reg, nil
+}
+
+// DeleteRegistration frees a guest's seat at an event
+func (r *GuestRepository) DeleteRegistration(eventID, id int) error {
+	defer metrics.TrackQuery("guests", "DeleteRegistration")()
+
+	result, err := r.db.Exec(`DELETE FROM guest_registrations WHERE id = $1 AND event_id = $2`, id, eventID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Delete removes a guest and its registrations
+func (r *GuestRepository) Delete(id int) error {
+	defer metrics.TrackQuery("guests", "Delete")()
+
+	result, err := r.db.Exec(`DELETE FROM guests WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Convert links a verified guest to a member with the same email. The
+// confirmed registrations become the member's, keeping their date and their
+// guest seat; pending ones are dropped.
+func (r *GuestRepository) Convert(id, memberID int) (*models.Guest, error) {
+	defer metrics.TrackQuery("guests", "Convert")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	g, err := scanGuest(tx.QueryRow(`SELECT `+guestColumns+` FROM guests WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return nil, err
+	}
+	if g.MemberID != nil {
+		return nil, ErrGuestConverted
+	}
+	if g.EmailVerifiedAt == nil {
+		return nil, ErrGuestNotVerified
+	}
+
+	var sameEmail bool
+	err = tx.QueryRow(`
+		SELECT LOWER(email) = LOWER($2) FROM members
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR SHARE`, memberID, g.Email).Scan(&sameEmail)
+	if err == sql.ErrNoRows {
+		return nil, ErrUnknownMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !sameEmail {
+		return nil, ErrGuestEmailMismatch
+	}
+
+	// Les places passent de l'invité au membre sans changer de nombre ; une
+	// inscription déjà prise en tant que membre l'emporte et libère la place
+	// invité
+	_, err = tx.Exec(`
+		INSERT INTO event_registrations (event_id, member_id, occurrence_start, registered_at, guest_seat)
+		SELECT event_id, $2, occurrence_start, registered_at, TRUE
+		FROM guest_registrations
+		WHERE guest_id = $1 AND confirmed_at IS NOT NULL
+		ON CONFLICT DO NOTHING`, id, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM guest_registrations WHERE guest_id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	g, err = scanGuest(tx.QueryRow(`
+		UPDATE guests SET member_id = $2, converted_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+guestColumns, id, memberID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrMediaInUse is returned when deleting an image that an event shows,
+// trashed events included
+var ErrMediaInUse = errors.New("l'image est utilisée par un événement")
+
+const mediaColumns = `id, storage_key, thumbnail_key, content_type, size, width, height,
+	url, thumbnail_url, original_name, created_at`
+
+// MediaRepository stores the metadata of uploaded images; the files
+// themselves live in a storage.Store
+type MediaRepository struct {
+	db *sql.DB
+}
+
+func NewMediaRepository(db *sql.DB) *MediaRepository {
+	return &MediaRepository{db: db}
+}
+
+func scanMedia(s scanner) (*models.Media, error) {
+	var m models.Media
+	err := s.Scan(&m.ID, &m.Key, &m.ThumbnailKey, &m.ContentType, &m.Size, &m.Width, &m.Height,
+		&m.URL, &m.ThumbnailURL, &m.OriginalName, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *MediaRepository) GetAll() ([]models.Media, error) {
+	defer metrics.TrackQuery("media", "GetAll")()
+
+	rows, err := r.db.Query(`SELECT ` + mediaColumns + ` FROM media ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := []models.Media{}
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, *m)
+	}
+
+	return media, rows.Err()
+}
+
+func (r *MediaRepository) GetByID(id int) (*models.Media, error) {
+	defer metrics.TrackQuery("media", "GetByID")()
+
+	return scanMedia(r.db.QueryRow(`SELECT `+mediaColumns+` FROM media WHERE id = $1`, id))
+}
+
+// Create records an uploaded image. The same content uploaded again yields
+// the existing record and created false.
+func (r *MediaRepository) Create(m *models.Media) (media *models.Media, created bool, err error) {
+	defer metrics.TrackQuery("media", "Create")()
+
+	media, err = scanMedia(r.db.QueryRow(`
+		INSERT INTO media (storage_key, thumbnail_key, content_type, size, width, height,
+		                   url, thumbnail_url, original_name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (storage_key) DO NOTHING
+		RETURNING `+mediaColumns,
+		m.Key, m.ThumbnailKey, m.ContentType, m.Size, m.Width, m.Height,
+		m.URL, m.ThumbnailURL, m.OriginalName,
+	))
+	if err == sql.ErrNoRows {
+		media, err = scanMedia(r.db.QueryRow(`SELECT `+mediaColumns+` FROM media WHERE storage_key = $1`, m.Key))
+		return media, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return media, true, nil
+}
+
+// Delete removes the record of an image unused by events; its files are
+// left to the caller
+func (r *MediaRepository) Delete(id int) error {
+	defer metrics.TrackQuery("media", "Delete")()
+
+	// La clé étrangère events.image_id refuse la suppression d'une image
+	// utilisée, y compris quand un événement la choisit au même moment
+	result, err := r.db.Exec(`DELETE FROM media WHERE id = $1`, id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+		return ErrMediaInUse
+	}
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
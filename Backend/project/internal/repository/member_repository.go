@@ -1,27 +1,68 @@
 package repository
 
 import (
+	"beautiful-minds/backend/project/internal/encryption"
+	"beautiful-minds/backend/project/internal/metrics"
 	"beautiful-minds/backend/project/internal/models"
 	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
 )
 
+const memberColumns = `id, first_name, last_name, email, phone, student_id,
+	field_of_study, registration_date, is_active, created_at, updated_at, version, deleted_at, anonymized_at, roles`
+
+// MemberRepository stores phone numbers and student IDs encrypted with the
+// keyring; they are decrypted transparently when members are read.
 type MemberRepository struct {
-	db *sql.DB
+	db   *sql.DB
+	keys *encryption.Keyring
 }
 
-func NewMemberRepository(db *sql.DB) *MemberRepository {
-	return &MemberRepository{db: db}
+func NewMemberRepository(db *sql.DB, keys *encryption.Keyring) *MemberRepository {
+	return &MemberRepository{db: db, keys: keys}
 }
 
-func (r *MemberRepository) GetAll() ([]models.Member, error) {
-	query := `
-		SELECT id, first_name, last_name, email, phone, student_id, 
-		       field_of_study, registration_date, is_active, created_at
-		FROM members
-		ORDER BY created_at DESC
-	`
+func (r *MemberRepository) scanMember(s scanner) (*models.Member, error) {
+	var m models.Member
+	err := s.Scan(
+		&m.ID, &m.FirstName, &m.LastName, &m.Email, &m.Phone,
+		&m.StudentID, &m.FieldOfStudy, &m.RegistrationDate,
+		&m.IsActive, &m.CreatedAt, &m.UpdatedAt, &m.Version, &m.DeletedAt,
+		&m.AnonymizedAt, pq.Array(&m.Roles),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if m.Roles == nil {
+		m.Roles = []string{}
+	}
+
+	if m.Phone, err = r.keys.Decrypt(m.Phone); err != nil {
+		return nil, err
+	}
+	if m.StudentID, err = r.keys.Decrypt(m.StudentID); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// sealed returns the encrypted phone and student ID of a request along with
+// the student ID blind index
+func (r *MemberRepository) sealed(req *models.CreateMemberRequest) (phone, studentID string, index *string, err error) {
+	if phone, err = r.keys.Encrypt(req.Phone); err != nil {
+		return "", "", nil, err
+	}
+	if studentID, err = r.keys.Encrypt(req.StudentID); err != nil {
+		return "", "", nil, err
+	}
+	return phone, studentID, r.keys.BlindIndex(req.StudentID), nil
+}
 
-	rows, err := r.db.Query(query)
+func (r *MemberRepository) queryMembers(query string, args ...any) ([]models.Member, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -29,71 +70,73 @@ func (r *MemberRepository) GetAll() ([]models.Member, error) {
 
 	var members []models.Member
 	for rows.Next() {
-		var m models.Member
-		err := rows.Scan(
-			&m.ID, &m.FirstName, &m.LastName, &m.Email, &m.Phone,
-			&m.StudentID, &m.FieldOfStudy, &m.RegistrationDate,
-			&m.IsActive, &m.CreatedAt,
-		)
+		m, err := r.scanMember(rows)
 		if err != nil {
 			return nil, err
 		}
-		members = append(members, m)
+		members = append(members, *m)
 	}
 
-	return members, nil
+	return members, rows.Err()
 }
 
-func (r *MemberRepository) GetByID(id int) (*models.Member, error) {
+func (r *MemberRepository) GetAll() ([]models.Member, error) {
+	defer metrics.TrackQuery("members", "GetAll")()
+
 	query := `
-		SELECT id, first_name, last_name, email, phone, student_id,
-		       field_of_study, registration_date, is_active, created_at
-		FROM members WHERE id = $1
+		SELECT ` + memberColumns + `
+		FROM members
+		WHERE deleted_at IS NULL
+		ORDER BY created_at DESC
 	`
 
-	var m models.Member
-	err := r.db.QueryRow(query, id).Scan(
-		&m.ID, &m.FirstName, &m.LastName, &m.Email, &m.Phone,
-		&m.StudentID, &m.FieldOfStudy, &m.RegistrationDate,
-		&m.IsActive, &m.CreatedAt,
-	)
+	return r.queryMembers(query)
+}
 
-	if err != nil {
-		return nil, err
-	}
+func (r *MemberRepository) GetByID(id int) (*models.Member, error) {
+	defer metrics.TrackQuery("members", "GetByID")()
 
-	return &m, nil
+	query := `SELECT ` + memberColumns + ` FROM members WHERE id = $1 AND deleted_at IS NULL`
+
+	return r.scanMember(r.db.QueryRow(query, id))
 }
 
 func (r *MemberRepository) Create(req *models.CreateMemberRequest) (*models.Member, error) {
+	defer metrics.TrackQuery("members", "Create")()
+
+	phone, studentID, index, err := r.sealed(req)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		INSERT INTO members (first_name, last_name, email, phone, student_id, field_of_study)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, first_name, last_name, email, phone, student_id, 
-		          field_of_study, registration_date, is_active, created_at
-	`
+		INSERT INTO members (first_name, last_name, email, phone, student_id, student_id_index, field_of_study, roles)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + memberColumns
 
-	var m models.Member
-	err := r.db.QueryRow(
+	m, err := r.scanMember(r.db.QueryRow(
 		query, req.FirstName, req.LastName, req.Email,
-		req.Phone, req.StudentID, req.FieldOfStudy,
-	).Scan(
-		&m.ID, &m.FirstName, &m.LastName, &m.Email, &m.Phone,
-		&m.StudentID, &m.FieldOfStudy, &m.RegistrationDate,
-		&m.IsActive, &m.CreatedAt,
-	)
-
+		phone, studentID, index, req.FieldOfStudy, pq.Array(req.Roles),
+	))
 	if err != nil {
 		return nil, err
 	}
 
-	return &m, nil
+	metrics.MembersCreated.Inc()
+	return m, nil
 }
 
-// Delete removes a member by ID
-func (r *MemberRepository) Delete(id int) error {
-	query := `DELETE FROM members WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+// Delete moves a member to the trash. A non-zero expectedVersion makes the
+// deletion conditional and yields ErrVersionConflict on mismatch.
+func (r *MemberRepository) Delete(id, expectedVersion int) error {
+	defer metrics.TrackQuery("members", "Delete")()
+
+	query := `
+		UPDATE members
+		SET deleted_at = NOW(), updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+	`
+	result, err := r.db.Exec(query, id, expectedVersion)
 	if err != nil {
 		return err
 	}
@@ -104,71 +147,270 @@ func (r *MemberRepository) Delete(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return sql.ErrNoRows
+		return checkVersionConflict(r.db, "members", id, expectedVersion)
 	}
 
 	return nil
 }
 
-// Update updates a member
-func (r *MemberRepository) Update(id int, req *models.CreateMemberRequest) (*models.Member, error) {
+// Update updates a member. A non-zero expectedVersion makes the update
+// conditional and yields ErrVersionConflict on mismatch.
+func (r *MemberRepository) Update(id int, req *models.CreateMemberRequest, expectedVersion int) (*models.Member, error) {
+	defer metrics.TrackQuery("members", "Update")()
+
+	phone, studentID, index, err := r.sealed(req)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		UPDATE members
-		SET first_name = $1, last_name = $2, email = $3, phone = $4, 
-		    student_id = $5, field_of_study = $6
-		WHERE id = $7
-		RETURNING id, first_name, last_name, email, phone, student_id, 
-		          field_of_study, registration_date, is_active, created_at
-	`
-
-	var m models.Member
-	err := r.db.QueryRow(
-		query, req.FirstName, req.LastName, req.Email, req.Phone,
-		req.StudentID, req.FieldOfStudy, id,
-	).Scan(
-		&m.ID, &m.FirstName, &m.LastName, &m.Email, &m.Phone,
-		&m.StudentID, &m.FieldOfStudy, &m.RegistrationDate,
-		&m.IsActive, &m.CreatedAt,
-	)
+		SET first_name = $1, last_name = $2, email = $3, phone = $4,
+		    student_id = $5, student_id_index = $6, field_of_study = $7, roles = $8,
+		    updated_at = NOW(), version = version + 1
+		WHERE id = $9 AND deleted_at IS NULL AND ($10 = 0 OR version = $10)
+		RETURNING ` + memberColumns
 
+	m, err := r.scanMember(r.db.QueryRow(
+		query, req.FirstName, req.LastName, req.Email, phone,
+		studentID, index, req.FieldOfStudy, pq.Array(req.Roles), id, expectedVersion,
+	))
+	if err == sql.ErrNoRows {
+		return nil, checkVersionConflict(r.db, "members", id, expectedVersion)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &m, nil
+	return m, nil
+}
+
+// GetAudience lists the active members the announcement id is meant for;
+// everyone for an announcement without audience
+func (r *MemberRepository) GetAudience(announcementID int) ([]models.Member, error) {
+	defer metrics.TrackQuery("members", "GetAudience")()
+
+	query := `
+		SELECT ` + memberColumns + `
+		FROM members m
+		WHERE deleted_at IS NULL AND is_active AND anonymized_at IS NULL
+		  AND EXISTS (SELECT 1 FROM announcements a
+		              WHERE a.id = $1 AND (` + audienceEveryone + ` OR ` + audienceMatch + `))
+		ORDER BY id
+	`
+
+	return r.queryMembers(query, announcementID)
 }
 
 // Search filters members by name or email
 func (r *MemberRepository) Search(query string) ([]models.Member, error) {
+	defer metrics.TrackQuery("members", "Search")()
+
 	searchQuery := `
-		SELECT id, first_name, last_name, email, phone, student_id, 
-		       field_of_study, registration_date, is_active, created_at
+		SELECT ` + memberColumns + `
 		FROM members
-		WHERE LOWER(first_name) LIKE LOWER($1) 
+		WHERE deleted_at IS NULL
+		  AND (LOWER(first_name) LIKE LOWER($1)
 		   OR LOWER(last_name) LIKE LOWER($1)
-		   OR LOWER(email) LIKE LOWER($1)
+		   OR LOWER(email) LIKE LOWER($1))
 		ORDER BY created_at DESC
 	`
 
-	rows, err := r.db.Query(searchQuery, "%"+query+"%")
+	return r.queryMembers(searchQuery, "%"+query+"%")
+}
+
+// GetDeleted lists the members currently in the trash
+func (r *MemberRepository) GetDeleted() ([]models.Member, error) {
+	defer metrics.TrackQuery("members", "GetDeleted")()
+
+	query := `
+		SELECT ` + memberColumns + `
+		FROM members
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`
+
+	return r.queryMembers(query)
+}
+
+// Restore takes a member out of the trash
+func (r *MemberRepository) Restore(id int) (*models.Member, error) {
+	defer metrics.TrackQuery("members", "Restore")()
+
+	query := `
+		UPDATE members
+		SET deleted_at = NULL, updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING ` + memberColumns
+
+	return r.scanMember(r.db.QueryRow(query, id))
+}
+
+// Purge permanently deletes the members trashed for longer than retention
+func (r *MemberRepository) Purge(retention time.Duration) (int64, error) {
+	defer metrics.TrackQuery("members", "Purge")()
+
+	return purgeDeleted(r.db, "members", retention)
+}
+
+// GetByEmail finds an active member by email
+func (r *MemberRepository) GetByEmail(email string) (*models.Member, error) {
+	defer metrics.TrackQuery("members", "GetByEmail")()
+
+	query := `SELECT ` + memberColumns + ` FROM members WHERE LOWER(email) = LOWER($1) AND deleted_at IS NULL`
+
+	return r.scanMember(r.db.QueryRow(query, email))
+}
+
+// GetByIDIncludingDeleted finds a member even when it is in the trash
+func (r *MemberRepository) GetByIDIncludingDeleted(id int) (*models.Member, error) {
+	defer metrics.TrackQuery("members", "GetByIDIncludingDeleted")()
+
+	query := `SELECT ` + memberColumns + ` FROM members WHERE id = $1`
+
+	return r.scanMember(r.db.QueryRow(query, id))
+}
+
+// Anonymize erases the personal data of a member. The row itself is kept so
+// that event registrations and statistics stay consistent. In the same
+// transaction, the audit snapshots of the member and the free-text survey
+// answers it gave are redacted, and the guest identities converted into it
+// are deleted.
+func (r *MemberRepository) Anonymize(id int) (*models.Member, error) {
+	defer metrics.TrackQuery("members", "Anonymize")()
+
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var members []models.Member
+	query := `
+		UPDATE members
+		SET first_name = 'Membre', last_name = 'anonymisé',
+		    email = 'anonymise-' || id || '@invalid', phone = '', student_id = '', student_id_index = NULL,
+		    roles = '{}', is_active = FALSE, anonymized_at = NOW(),
+		    updated_at = NOW(), version = version + 1
+		WHERE id = $1 AND anonymized_at IS NULL
+		RETURNING ` + memberColumns
+
+	m, err := r.scanMember(tx.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	// Le déclencheur d'audit_log n'autorise que ce membre, le temps de la transaction
+	if _, err := tx.Exec(`SELECT set_config('audit_log.erase_member', $1, true)`, strconv.Itoa(id)); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`
+		UPDATE audit_log
+		SET before = CASE WHEN before IS NULL THEN NULL ELSE jsonb_build_object('id', entity_id, 'redacted', TRUE) END,
+		    after = CASE WHEN after IS NULL THEN NULL ELSE jsonb_build_object('id', entity_id, 'redacted', TRUE) END,
+		    changes = NULL, ip = ''
+		WHERE entity_type = 'member' AND entity_id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	// L'identité d'invité convertie en ce membre en est une copie
+	if _, err := tx.Exec(`DELETE FROM guests WHERE member_id = $1`, id); err != nil {
+		return nil, err
+	}
+
+	// Les réponses libres aux questionnaires peuvent identifier le membre ;
+	// les notes et choix restent pour les résultats agrégés
+	if _, err := tx.Exec(`
+		UPDATE survey_answers SET text = ''
+		WHERE text <> '' AND response_id IN (SELECT id FROM survey_responses WHERE member_id = $1)`, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetByStudentID finds active members by exact student ID through the blind
+// index, without decrypting the table
+func (r *MemberRepository) GetByStudentID(studentID string) ([]models.Member, error) {
+	defer metrics.TrackQuery("members", "GetByStudentID")()
+
+	index := r.keys.BlindIndex(studentID)
+	if index == nil {
+		return nil, nil
+	}
+
+	query := `
+		SELECT ` + memberColumns + `
+		FROM members
+		WHERE student_id_index = $1 AND deleted_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	return r.queryMembers(query, *index)
+}
+
+// Reencrypt encrypts the phone numbers and student IDs still stored in clear
+// and rewraps the values sealed under a retired master key. It returns the
+// number of rows rewritten.
+func (r *MemberRepository) Reencrypt() (int, error) {
+	defer metrics.TrackQuery("members", "Reencrypt")()
+
+	rows, err := r.db.Query(`SELECT id, phone, student_id FROM members`)
+	if err != nil {
+		return 0, err
+	}
+
+	type pending struct {
+		id               int
+		phone, studentID string
+	}
+	var stale []pending
 	for rows.Next() {
-		var m models.Member
-		err := rows.Scan(
-			&m.ID, &m.FirstName, &m.LastName, &m.Email, &m.Phone,
-			&m.StudentID, &m.FieldOfStudy, &m.RegistrationDate,
-			&m.IsActive, &m.CreatedAt,
+		var p pending
+		if err := rows.Scan(&p.id, &p.phone, &p.studentID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if r.keys.NeedsRewrap(p.phone) || r.keys.NeedsRewrap(p.studentID) {
+			stale = append(stale, p)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	rewritten := 0
+	for _, p := range stale {
+		studentID, err := r.keys.Decrypt(p.studentID)
+		if err != nil {
+			return rewritten, err
+		}
+		phone, err := r.keys.Rewrap(p.phone)
+		if err != nil {
+			return rewritten, err
+		}
+		sealedStudentID, err := r.keys.Rewrap(p.studentID)
+		if err != nil {
+			return rewritten, err
+		}
+
+		// The version is left untouched since the member's data did not
+		// change; rows updated in the meantime are already up to date
+		result, err := r.db.Exec(`
+			UPDATE members SET phone = $1, student_id = $2, student_id_index = $3
+			WHERE id = $4 AND phone = $5 AND student_id = $6`,
+			phone, sealedStudentID, r.keys.BlindIndex(studentID), p.id, p.phone, p.studentID,
 		)
 		if err != nil {
-			return nil, err
+			return rewritten, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			rewritten++
 		}
-		members = append(members, m)
 	}
 
-	return members, nil
+	return rewritten, nil
 }
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const occurrenceColumns = `occurrence_start, title, description, date, location,
+	max_participants, cancelled, updated_at`
+
+// OccurrenceRepository stores the per-occurrence changes of recurring events
+type OccurrenceRepository struct {
+	db *sql.DB
+}
+
+func NewOccurrenceRepository(db *sql.DB) *OccurrenceRepository {
+	return &OccurrenceRepository{db: db}
+}
+
+func scanOccurrenceOverride(s scanner) (*models.OccurrenceOverride, error) {
+	var o models.OccurrenceOverride
+	err := s.Scan(
+		&o.OccurrenceStart, &o.Title, &o.Description, &o.Date, &o.Location,
+		&o.MaxParticipants, &o.Cancelled, &o.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// GetByEvents returns the overrides of the given events, keyed by event ID
+// then occurrence start (Unix seconds)
+func (r *OccurrenceRepository) GetByEvents(eventIDs []int) (map[int]map[int64]models.OccurrenceOverride, error) {
+	defer metrics.TrackQuery("event_occurrences", "GetByEvents")()
+
+	return loadOverrides(r.db, eventIDs)
+}
+
+// loadOverrides reads the occurrence overrides of the given events, within
+// the caller's transaction when q is one
+func loadOverrides(q querier, eventIDs []int) (map[int]map[int64]models.OccurrenceOverride, error) {
+	overrides := make(map[int]map[int64]models.OccurrenceOverride)
+	if len(eventIDs) == 0 {
+		return overrides, nil
+	}
+
+	ids := make([]int64, len(eventIDs))
+	for i, id := range eventIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := q.Query(`
+		SELECT event_id, `+occurrenceColumns+`
+		FROM event_occurrences
+		WHERE event_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var eventID int
+		var o models.OccurrenceOverride
+		err := rows.Scan(
+			&eventID, &o.OccurrenceStart, &o.Title, &o.Description, &o.Date, &o.Location,
+			&o.MaxParticipants, &o.Cancelled, &o.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if overrides[eventID] == nil {
+			overrides[eventID] = make(map[int64]models.OccurrenceOverride)
+		}
+		overrides[eventID][o.OccurrenceStart.Unix()] = o
+	}
+
+	return overrides, rows.Err()
+}
+
+// Get returns the override of one occurrence; sql.ErrNoRows when the
+// occurrence follows its series
+func (r *OccurrenceRepository) Get(eventID int, start time.Time) (*models.OccurrenceOverride, error) {
+	defer metrics.TrackQuery("event_occurrences", "Get")()
+
+	query := `SELECT ` + occurrenceColumns + ` FROM event_occurrences WHERE event_id = $1 AND occurrence_start = $2`
+
+	return scanOccurrenceOverride(r.db.QueryRow(query, eventID, start.UTC()))
+}
+
+// Save creates or replaces the override of an occurrence. A moved
+// occurrence is checked against the bookings of the event's venue, as in
+// EventRepository.Update: a *BookingConflictError is returned on overlap.
+func (r *OccurrenceRepository) Save(eventID int, o *models.OccurrenceOverride) (*models.OccurrenceOverride, error) {
+	defer metrics.TrackQuery("event_occurrences", "Save")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	e, err := lockEventVenue(tx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO event_occurrences (event_id, occurrence_start, title, description, date,
+		                               location, max_participants, cancelled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (event_id, occurrence_start) DO UPDATE
+		SET title = EXCLUDED.title, description = EXCLUDED.description, date = EXCLUDED.date,
+		    location = EXCLUDED.location, max_participants = EXCLUDED.max_participants,
+		    cancelled = EXCLUDED.cancelled, updated_at = NOW()
+		RETURNING ` + occurrenceColumns
+
+	var date *time.Time
+	if o.Date != nil {
+		utc := o.Date.UTC()
+		date = &utc
+	}
+
+	saved, err := scanOccurrenceOverride(tx.QueryRow(
+		query, eventID, o.OccurrenceStart.UTC(), o.Title, o.Description, date,
+		o.Location, o.MaxParticipants, o.Cancelled,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBookings(tx, e); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// Delete drops the override so the occurrence follows its series again
+func (r *OccurrenceRepository) Delete(eventID int, start time.Time) error {
+	defer metrics.TrackQuery("event_occurrences", "Delete")()
+
+	result, err := r.db.Exec(`DELETE FROM event_occurrences WHERE event_id = $1 AND occurrence_start = $2`, eventID, start.UTC())
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"time"
+)
+
+const privacyRequestColumns = `id, member_id, guest_id, type, status, source, ip,
+	requested_at, expires_at, completed_at`
+
+type PrivacyRepository struct {
+	db *sql.DB
+}
+
+func NewPrivacyRepository(db *sql.DB) *PrivacyRepository {
+	return &PrivacyRepository{db: db}
+}
+
+func scanPrivacyRequest(s scanner) (*models.PrivacyRequest, error) {
+	var p models.PrivacyRequest
+	err := s.Scan(
+		&p.ID, &p.MemberID, &p.GuestID, &p.Type, &p.Status, &p.Source, &p.IP,
+		&p.RequestedAt, &p.ExpiresAt, &p.CompletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CreatePending records a self-service request of a member or a guest
+// awaiting email confirmation
+func (r *PrivacyRepository) CreatePending(memberID, guestID *int, requestType, tokenHash, ip string, expiresAt time.Time) (*models.PrivacyRequest, error) {
+	defer metrics.TrackQuery("privacy_requests", "CreatePending")()
+
+	query := `
+		INSERT INTO privacy_requests (member_id, guest_id, type, source, token_hash, ip, expires_at)
+		VALUES ($1, $2, $3, 'self', $4, $5, $6)
+		RETURNING ` + privacyRequestColumns
+
+	return scanPrivacyRequest(r.db.QueryRow(query, memberID, guestID, requestType, tokenHash, ip, expiresAt.UTC()))
+}
+
+// CreateCompleted records a request carried out directly by an administrator
+func (r *PrivacyRepository) CreateCompleted(memberID int, requestType, ip string) (*models.PrivacyRequest, error) {
+	defer metrics.TrackQuery("privacy_requests", "CreateCompleted")()
+
+	query := `
+		INSERT INTO privacy_requests (member_id, type, status, source, ip, completed_at)
+		VALUES ($1, $2, 'completed', 'admin', $3, NOW())
+		RETURNING ` + privacyRequestColumns
+
+	return scanPrivacyRequest(r.db.QueryRow(query, memberID, requestType, ip))
+}
+
+// GetByToken finds an unexpired request by its token hash
+func (r *PrivacyRepository) GetByToken(tokenHash, requestType string) (*models.PrivacyRequest, error) {
+	defer metrics.TrackQuery("privacy_requests", "GetByToken")()
+
+	query := `
+		SELECT ` + privacyRequestColumns + `
+		FROM privacy_requests
+		WHERE token_hash = $1 AND type = $2 AND expires_at > NOW()
+	`
+
+	return scanPrivacyRequest(r.db.QueryRow(query, tokenHash, requestType))
+}
+
+// Complete marks a request as carried out. Export links stay usable until
+// they expire; erasure links are revoked.
+func (r *PrivacyRepository) Complete(id int, revokeToken bool) error {
+	defer metrics.TrackQuery("privacy_requests", "Complete")()
+
+	query := `
+		UPDATE privacy_requests
+		SET status = 'completed', completed_at = COALESCE(completed_at, NOW()),
+		    token_hash = CASE WHEN $2 THEN NULL ELSE token_hash END
+		WHERE id = $1
+	`
+
+	_, err := r.db.Exec(query, id, revokeToken)
+	return err
+}
+
+// GetAll lists every request, most recent first
+func (r *PrivacyRepository) GetAll() ([]models.PrivacyRequest, error) {
+	defer metrics.TrackQuery("privacy_requests", "GetAll")()
+
+	return r.query(`SELECT ` + privacyRequestColumns + ` FROM privacy_requests ORDER BY requested_at DESC`)
+}
+
+// GetByMember lists the requests made for a member
+func (r *PrivacyRepository) GetByMember(memberID int) ([]models.PrivacyRequest, error) {
+	defer metrics.TrackQuery("privacy_requests", "GetByMember")()
+
+	return r.query(`
+		SELECT `+privacyRequestColumns+`
+		FROM privacy_requests
+		WHERE member_id = $1
+		ORDER BY requested_at DESC
+	`, memberID)
+}
+
+// GetByGuest lists the requests made for a guest
+func (r *PrivacyRepository) GetByGuest(guestID int) ([]models.PrivacyRequest, error) {
+	defer metrics.TrackQuery("privacy_requests", "GetByGuest")()
+
+	return r.query(`
+		SELECT `+privacyRequestColumns+`
+		FROM privacy_requests
+		WHERE guest_id = $1
+		ORDER BY requested_at DESC
+	`, guestID)
+}
+
+func (r *PrivacyRepository) query(query string, args ...any) ([]models.PrivacyRequest, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.PrivacyRequest
+	for rows.Next() {
+		p, err := scanPrivacyRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *p)
+	}
+
+	return requests, rows.Err()
+}
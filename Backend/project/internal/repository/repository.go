@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrVersionConflict is returned when a write carries an expected version
+// that no longer matches the stored row
+var ErrVersionConflict = errors.New("version conflict")
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// querier is implemented by *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// checkVersionConflict turns a missing row after a conditional write into
+// ErrVersionConflict when the row still exists under another version
+func checkVersionConflict(db *sql.DB, table string, id, expectedVersion int) error {
+	if expectedVersion == 0 {
+		return sql.ErrNoRows
+	}
+
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
+
+// purgeDeleted permanently removes rows soft-deleted for longer than
+// retention. The cutoff is computed by the database, whose NOW() wrote
+// deleted_at, so the server's local time zone plays no part.
+func purgeDeleted(db *sql.DB, table string, retention time.Duration) (int64, error) {
+	result, err := db.Exec(`DELETE FROM `+table+`
+		WHERE deleted_at IS NOT NULL AND deleted_at < NOW() - $1 * INTERVAL '1 second'`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+)
+
+// RevisionRepository keeps the successive versions of events and
+// announcements
+type RevisionRepository struct {
+	db *sql.DB
+}
+
+func NewRevisionRepository(db *sql.DB) *RevisionRepository {
+	return &RevisionRepository{db: db}
+}
+
+// recordRevision records the state of an entity at its version, in the
+// transaction that wrote it. The author is empty when unknown. A version
+// already recorded is kept as is.
+func recordRevision(tx *sql.Tx, entityType string, entityID, version int, state any, author string) error {
+	defer metrics.TrackQuery("revisions", "Create")()
+
+	snapshot, err := models.Snapshot(state)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO revisions (entity_type, entity_id, version, snapshot, author)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (entity_type, entity_id, version) DO NOTHING`,
+		entityType, entityID, version, string(snapshot), author,
+	)
+	return err
+}
+
+// revisionMissing reports whether the current version of the row id of
+// table has no revision yet, as for rows written before revisions existed.
+// A missing row has none to record.
+func revisionMissing(tx *sql.Tx, entityType, table string, id int) (bool, error) {
+	var missing bool
+	err := tx.QueryRow(`
+		SELECT NOT EXISTS (SELECT 1 FROM revisions v
+		                   WHERE v.entity_type = $1 AND v.entity_id = t.id AND v.version = t.version)
+		FROM `+table+` t
+		WHERE t.id = $2`, entityType, id).Scan(&missing)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return missing, err
+}
+
+// List returns the revisions of an entity without their snapshot, latest
+// first
+func (r *RevisionRepository) List(entityType string, entityID int) ([]models.Revision, error) {
+	defer metrics.TrackQuery("revisions", "List")()
+
+	rows, err := r.db.Query(`
+		SELECT id, entity_type, entity_id, version, author, created_at
+		FROM revisions
+		WHERE entity_type = $1 AND entity_id = $2
+		ORDER BY version DESC`, entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.Revision{}
+	for rows.Next() {
+		var rev models.Revision
+		if err := rows.Scan(&rev.ID, &rev.EntityType, &rev.EntityID, &rev.Version, &rev.Author, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+// Get reads a revision with its snapshot; sql.ErrNoRows when the version
+// was not recorded
+func (r *RevisionRepository) Get(entityType string, entityID, version int) (*models.Revision, error) {
+	defer metrics.TrackQuery("revisions", "Get")()
+
+	var rev models.Revision
+	var snapshot []byte
+	err := r.db.QueryRow(`
+		SELECT id, entity_type, entity_id, version, author, created_at, snapshot
+		FROM revisions
+		WHERE entity_type = $1 AND entity_id = $2 AND version = $3`, entityType, entityID, version,
+	).Scan(&rev.ID, &rev.EntityType, &rev.EntityID, &rev.Version, &rev.Author, &rev.CreatedAt, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+	rev.Snapshot = snapshot
+	return &rev, nil
+}
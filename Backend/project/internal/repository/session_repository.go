@@ -0,0 +1,451 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"errors"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrRegistrationClosed is returned for sessions without registration
+	ErrRegistrationClosed = errors.New("inscription à la session fermée")
+	// ErrSessionFull is returned when every seat of the session is taken
+	ErrSessionFull = errors.New("session complète")
+	// ErrNotRegistered is returned when the member is not registered to the
+	// event of the session
+	ErrNotRegistered = errors.New("le membre doit d'abord s'inscrire à l'événement")
+	// ErrCapacityBelowRegistrations is returned when a session's capacity
+	// would drop below its number of registrations
+	ErrCapacityBelowRegistrations = errors.New("la capacité ne peut être inférieure au nombre d'inscrits")
+)
+
+const sessionColumns = `s.id, s.event_id, s.title, s.description, s.start_time, s.end_time,
+	s.venue_id, s.room, s.registration_open, s.capacity,
+	(SELECT COUNT(*) FROM session_registrations sr WHERE sr.session_id = s.id),
+	s.created_at, s.updated_at`
+
+// SessionRepository stores the agenda of events and the registrations to
+// their sessions
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func scanSession(s scanner) (*models.Session, error) {
+	var session models.Session
+	err := s.Scan(
+		&session.ID, &session.EventID, &session.Title, &session.Description,
+		&session.StartTime, &session.EndTime, &session.VenueID, &session.Room,
+		&session.RegistrationOpen, &session.Capacity, &session.Registered,
+		&session.CreatedAt, &session.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// loadSpeakers fills the speakers of the given sessions, in order of
+// appearance
+func loadSpeakers(q querier, sessions []models.Session) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(sessions))
+	byID := make(map[int]*models.Session, len(sessions))
+	for i := range sessions {
+		sessions[i].Speakers = []models.Speaker{}
+		ids[i] = int64(sessions[i].ID)
+		byID[sessions[i].ID] = &sessions[i]
+	}
+
+	rows, err := q.Query(`
+		SELECT ss.session_id, sp.id, sp.name, sp.bio, sp.photo_url, sp.affiliation, sp.member_id,
+		       sp.created_at, sp.updated_at
+		FROM session_speakers ss
+		JOIN speakers sp ON sp.id = ss.speaker_id
+		WHERE ss.session_id = ANY($1)
+		ORDER BY ss.position`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionID int
+		var sp models.Speaker
+		err := rows.Scan(&sessionID, &sp.ID, &sp.Name, &sp.Bio, &sp.PhotoURL, &sp.Affiliation,
+			&sp.MemberID, &sp.CreatedAt, &sp.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		byID[sessionID].Speakers = append(byID[sessionID].Speakers, sp)
+	}
+
+	return rows.Err()
+}
+
+// getSession reads one session of an event with its speakers
+func getSession(q querier, eventID, id int) (*models.Session, error) {
+	session, err := scanSession(q.QueryRow(`
+		SELECT `+sessionColumns+`
+		FROM event_sessions s
+		WHERE s.id = $1 AND s.event_id = $2`, id, eventID))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []models.Session{*session}
+	if err := loadSpeakers(q, sessions); err != nil {
+		return nil, err
+	}
+	return &sessions[0], nil
+}
+
+// GetByEvent lists the sessions of an event in chronological order
+func (r *SessionRepository) GetByEvent(eventID int) ([]models.Session, error) {
+	defer metrics.TrackQuery("event_sessions", "GetByEvent")()
+
+	rows, err := r.db.Query(`
+		SELECT `+sessionColumns+`
+		FROM event_sessions s
+		WHERE s.event_id = $1
+		ORDER BY s.start_time, s.room, s.id`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := loadSpeakers(r.db, sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// GetByID reads a session of an event; sql.ErrNoRows when the session does
+// not belong to it
+func (r *SessionRepository) GetByID(eventID, id int) (*models.Session, error) {
+	defer metrics.TrackQuery("event_sessions", "GetByID")()
+
+	return getSession(r.db, eventID, id)
+}
+
+// sessionRoom locks the venue requested for a session and returns the room
+// to store: the venue name, or the free text without venue
+func sessionRoom(tx *sql.Tx, req *models.CreateSessionRequest) (string, error) {
+	if req.VenueID == nil {
+		return req.Room, nil
+	}
+	venue, err := lockVenue(tx, *req.VenueID)
+	if err != nil {
+		return "", err
+	}
+	return venue.Name, nil
+}
+
+// checkSessionBookings returns a *BookingConflictError when a session
+// overlaps another event or another session booked in the same
+// venue; the venue is locked by sessionRoom
+func checkSessionBookings(tx *sql.Tx, eventID, sessionID int, req *models.CreateSessionRequest) error {
+	if req.VenueID == nil {
+		return nil
+	}
+
+	conflicts, err := eventBookings(tx, *req.VenueID, eventID, req.Start, req.End)
+	if err != nil {
+		return err
+	}
+	sessions, err := sessionBookings(tx, *req.VenueID, 0, sessionID, req.Start, req.End)
+	if err != nil {
+		return err
+	}
+
+	conflicts = append(conflicts, sessions...)
+	if len(conflicts) > 0 {
+		sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Start.Before(conflicts[j].Start) })
+		return &BookingConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// setSpeakers replaces the speakers of a session
+func setSpeakers(tx *sql.Tx, sessionID int, speakerIDs []int) error {
+	if _, err := tx.Exec(`DELETE FROM session_speakers WHERE session_id = $1`, sessionID); err != nil {
+		return err
+	}
+	if len(speakerIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(speakerIDs))
+	for i, id := range speakerIDs {
+		ids[i] = int64(id)
+	}
+	result, err := tx.Exec(`
+		INSERT INTO session_speakers (session_id, speaker_id, position)
+		SELECT $1, sp.id, ids.position
+		FROM UNNEST($2::int[]) WITH ORDINALITY AS ids(id, position)
+		JOIN speakers sp ON sp.id = ids.id`, sessionID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if int(n) != len(ids) {
+		return ErrUnknownSpeaker
+	}
+	return nil
+}
+
+// Create adds a session to an event. ErrUnknownVenue and ErrUnknownSpeaker
+// are returned for missing references, a *BookingConflictError when the
+// venue is already booked.
+func (r *SessionRepository) Create(eventID int, req *models.CreateSessionRequest) (*models.Session, error) {
+	defer metrics.TrackQuery("event_sessions", "Create")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	room, err := sessionRoom(tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO event_sessions (event_id, title, description, start_time, end_time,
+		                            venue_id, room, registration_open, capacity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`,
+		eventID, req.Title, req.Description, req.Start, req.End,
+		req.VenueID, room, req.RegistrationOpen, req.Capacity,
+	).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSessionBookings(tx, eventID, id, req); err != nil {
+		return nil, err
+	}
+	if err := setSpeakers(tx, id, req.SpeakerIDs); err != nil {
+		return nil, err
+	}
+
+	session, err := getSession(tx, eventID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Update replaces a session of an event; sql.ErrNoRows when the session
+// does not belong to it. References and bookings are checked as in Create,
+// and ErrCapacityBelowRegistrations is returned when the capacity would drop
+// below the seats already taken.
+func (r *SessionRepository) Update(eventID, id int, req *models.CreateSessionRequest) (*models.Session, error) {
+	defer metrics.TrackQuery("event_sessions", "Update")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	room, err := sessionRoom(tx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE event_sessions
+		SET title = $1, description = $2, start_time = $3, end_time = $4, venue_id = $5,
+		    room = $6, registration_open = $7, capacity = $8, updated_at = NOW()
+		WHERE id = $9 AND event_id = $10`,
+		req.Title, req.Description, req.Start, req.End, req.VenueID,
+		room, req.RegistrationOpen, req.Capacity, id, eventID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	if err := checkSessionBookings(tx, eventID, id, req); err != nil {
+		return nil, err
+	}
+
+	// The updated row stays locked, so Register cannot add a seat meanwhile
+	if req.Capacity > 0 {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM session_registrations WHERE session_id = $1`, id).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count > req.Capacity {
+			return nil, ErrCapacityBelowRegistrations
+		}
+	}
+	if err := setSpeakers(tx, id, req.SpeakerIDs); err != nil {
+		return nil, err
+	}
+
+	session, err := getSession(tx, eventID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Delete removes a session and its registrations
+func (r *SessionRepository) Delete(eventID, id int) error {
+	defer metrics.TrackQuery("event_sessions", "Delete")()
+
+	result, err := r.db.Exec(`DELETE FROM event_sessions WHERE id = $1 AND event_id = $2`, id, eventID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Register registers a member to a session. The session row is locked so
+// that concurrent registrations cannot exceed its capacity.
+func (r *SessionRepository) Register(eventID, sessionID, memberID int) error {
+	defer metrics.TrackQuery("event_sessions", "Register")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var open bool
+	var capacity int
+	// Les sessions d'un événement dans la corbeille n'acceptent pas d'inscription
+	err = tx.QueryRow(`
+		SELECT s.registration_open, s.capacity
+		FROM event_sessions s
+		JOIN events e ON e.id = s.event_id
+		WHERE s.id = $1 AND s.event_id = $2 AND e.deleted_at IS NULL
+		FOR UPDATE OF s`, sessionID, eventID).Scan(&open, &capacity)
+	if err != nil {
+		return err
+	}
+	if !open {
+		return ErrRegistrationClosed
+	}
+
+	var registered bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM event_registrations WHERE event_id = $1 AND member_id = $2)`,
+		eventID, memberID).Scan(&registered)
+	if err != nil {
+		return err
+	}
+	if !registered {
+		return ErrNotRegistered
+	}
+
+	if capacity > 0 {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM session_registrations WHERE session_id = $1`, sessionID).Scan(&count); err != nil {
+			return err
+		}
+		if count >= capacity {
+			return ErrSessionFull
+		}
+	}
+
+	if _, err := tx.Exec(`INSERT INTO session_registrations (session_id, member_id) VALUES ($1, $2)`, sessionID, memberID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Unregister frees a member's seat in a session
+func (r *SessionRepository) Unregister(eventID, sessionID, memberID int) error {
+	defer metrics.TrackQuery("event_sessions", "Unregister")()
+
+	result, err := r.db.Exec(`
+		DELETE FROM session_registrations sr
+		USING event_sessions s
+		WHERE sr.session_id = s.id AND s.id = $1 AND s.event_id = $2 AND sr.member_id = $3`,
+		sessionID, eventID, memberID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetRegistrationsByMember lists the session registrations of a member
+func (r *SessionRepository) GetRegistrationsByMember(memberID int) ([]models.SessionRegistrationRecord, error) {
+	defer metrics.TrackQuery("event_sessions", "GetRegistrationsByMember")()
+
+	rows, err := r.db.Query(`
+		SELECT s.id, s.title, s.event_id, s.start_time, sr.registered_at
+		FROM session_registrations sr
+		JOIN event_sessions s ON s.id = sr.session_id
+		WHERE sr.member_id = $1
+		ORDER BY sr.registered_at DESC`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var registrations []models.SessionRegistrationRecord
+	for rows.Next() {
+		var reg models.SessionRegistrationRecord
+		if err := rows.Scan(&reg.SessionID, &reg.SessionTitle, &reg.EventID, &reg.StartTime, &reg.RegisteredAt); err != nil {
+			return nil, err
+		}
+		registrations = append(registrations, reg)
+	}
+
+	return registrations, rows.Err()
+}
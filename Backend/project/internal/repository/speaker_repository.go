@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"errors"
+)
+
+// ErrUnknownSpeaker is returned when a session references a missing speaker
+var ErrUnknownSpeaker = errors.New("intervenant inconnu")
+
+// ErrUnknownMember is returned when a speaker is linked to a missing member
+var ErrUnknownMember = errors.New("membre inconnu")
+
+const speakerColumns = `id, name, bio, photo_url, affiliation, member_id, created_at, updated_at`
+
+type SpeakerRepository struct {
+	db *sql.DB
+}
+
+func NewSpeakerRepository(db *sql.DB) *SpeakerRepository {
+	return &SpeakerRepository{db: db}
+}
+
+func scanSpeaker(s scanner) (*models.Speaker, error) {
+	var sp models.Speaker
+	err := s.Scan(&sp.ID, &sp.Name, &sp.Bio, &sp.PhotoURL, &sp.Affiliation, &sp.MemberID, &sp.CreatedAt, &sp.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &sp, nil
+}
+
+func (r *SpeakerRepository) GetAll() ([]models.Speaker, error) {
+	defer metrics.TrackQuery("speakers", "GetAll")()
+
+	rows, err := r.db.Query(`SELECT ` + speakerColumns + ` FROM speakers ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	speakers := []models.Speaker{}
+	for rows.Next() {
+		sp, err := scanSpeaker(rows)
+		if err != nil {
+			return nil, err
+		}
+		speakers = append(speakers, *sp)
+	}
+
+	return speakers, rows.Err()
+}
+
+func (r *SpeakerRepository) GetByID(id int) (*models.Speaker, error) {
+	defer metrics.TrackQuery("speakers", "GetByID")()
+
+	return scanSpeaker(r.db.QueryRow(`SELECT `+speakerColumns+` FROM speakers WHERE id = $1`, id))
+}
+
+// Create adds a speaker; ErrUnknownMember is returned when the linked
+// member does not exist
+func (r *SpeakerRepository) Create(req *models.CreateSpeakerRequest) (*models.Speaker, error) {
+	defer metrics.TrackQuery("speakers", "Create")()
+
+	query := `
+		INSERT INTO speakers (name, bio, photo_url, affiliation, member_id)
+		SELECT $1, $2, $3, $4, $5
+		WHERE $5::int IS NULL OR EXISTS (SELECT 1 FROM members WHERE id = $5 AND deleted_at IS NULL)
+		RETURNING ` + speakerColumns
+
+	sp, err := scanSpeaker(r.db.QueryRow(query, req.Name, req.Bio, req.PhotoURL, req.Affiliation, req.MemberID))
+	if err == sql.ErrNoRows {
+		return nil, ErrUnknownMember
+	}
+	return sp, err
+}
+
+// Update changes a speaker; sql.ErrNoRows when it does not exist
+func (r *SpeakerRepository) Update(id int, req *models.CreateSpeakerRequest) (*models.Speaker, error) {
+	defer metrics.TrackQuery("speakers", "Update")()
+
+	if req.MemberID != nil {
+		var exists bool
+		err := r.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM members WHERE id = $1 AND deleted_at IS NULL)`, *req.MemberID).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrUnknownMember
+		}
+	}
+
+	query := `
+		UPDATE speakers
+		SET name = $1, bio = $2, photo_url = $3, affiliation = $4, member_id = $5, updated_at = NOW()
+		WHERE id = $6
+		RETURNING ` + speakerColumns
+
+	return scanSpeaker(r.db.QueryRow(query, req.Name, req.Bio, req.PhotoURL, req.Affiliation, req.MemberID, id))
+}
+
+// Delete removes a speaker from the registry and from the sessions
+func (r *SpeakerRepository) Delete(id int) error {
+	defer metrics.TrackQuery("speakers", "Delete")()
+
+	result, err := r.db.Exec(`DELETE FROM speakers WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
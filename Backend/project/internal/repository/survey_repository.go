@@ -0,0 +1,379 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrSurveyAnswered is returned when changing the questions of a survey
+	// that already has responses
+	ErrSurveyAnswered = errors.New("le questionnaire a déjà des réponses")
+	// ErrNotAttended is returned when a member who did not attend the event
+	// answers its survey
+	ErrNotAttended = errors.New("seuls les membres présents à l'événement peuvent répondre")
+	// ErrInvalidSurveyToken is returned when answering with an unknown or
+	// expired token
+	ErrInvalidSurveyToken = errors.New("lien de réponse invalide ou expiré")
+)
+
+const surveyColumns = `id, event_id, title, description, closes_at, created_at, updated_at,
+	(SELECT COUNT(*) FROM survey_responses sr WHERE sr.survey_id = surveys.id)`
+
+// SurveyRepository stores the feedback surveys of events and their
+// responses
+type SurveyRepository struct {
+	db *sql.DB
+}
+
+func NewSurveyRepository(db *sql.DB) *SurveyRepository {
+	return &SurveyRepository{db: db}
+}
+
+func scanSurvey(s scanner) (*models.Survey, error) {
+	var sv models.Survey
+	err := s.Scan(&sv.ID, &sv.EventID, &sv.Title, &sv.Description, &sv.ClosesAt,
+		&sv.CreatedAt, &sv.UpdatedAt, &sv.ResponseCount)
+	if err != nil {
+		return nil, err
+	}
+	return &sv, nil
+}
+
+// getSurvey reads the survey of an event with its questions in order
+func getSurvey(q querier, eventID int) (*models.Survey, error) {
+	sv, err := scanSurvey(q.QueryRow(`SELECT `+surveyColumns+` FROM surveys WHERE event_id = $1`, eventID))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`
+		SELECT id, position, kind, label, required, options, multiple
+		FROM survey_questions
+		WHERE survey_id = $1
+		ORDER BY position`, sv.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sv.Questions = []models.SurveyQuestion{}
+	for rows.Next() {
+		var sq models.SurveyQuestion
+		err := rows.Scan(&sq.ID, &sq.Position, &sq.Kind, &sq.Label, &sq.Required, pq.Array(&sq.Options), &sq.Multiple)
+		if err != nil {
+			return nil, err
+		}
+		if sq.Options == nil {
+			sq.Options = []string{}
+		}
+		sv.Questions = append(sv.Questions, sq)
+	}
+
+	return sv, rows.Err()
+}
+
+// GetByEvent reads the survey of an event; sql.ErrNoRows when it has none
+func (r *SurveyRepository) GetByEvent(eventID int) (*models.Survey, error) {
+	defer metrics.TrackQuery("surveys", "GetByEvent")()
+
+	return getSurvey(r.db, eventID)
+}
+
+// Save creates or replaces the survey of an event. The questions of a
+// survey with responses cannot change: ErrSurveyAnswered is returned.
+func (r *SurveyRepository) Save(eventID int, req *models.SurveyRequest) (*models.Survey, error) {
+	defer metrics.TrackQuery("surveys", "Save")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO surveys (event_id, title, description, closes_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (event_id) DO UPDATE
+		SET title = EXCLUDED.title, description = EXCLUDED.description,
+		    closes_at = EXCLUDED.closes_at, updated_at = NOW()
+		RETURNING id`, eventID, req.Title, req.Description, req.ClosesAt).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+
+	var answered bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM survey_responses WHERE survey_id = $1)`, id).Scan(&answered); err != nil {
+		return nil, err
+	}
+	if answered {
+		return nil, ErrSurveyAnswered
+	}
+
+	if _, err := tx.Exec(`DELETE FROM survey_questions WHERE survey_id = $1`, id); err != nil {
+		return nil, err
+	}
+	for _, q := range req.Questions {
+		_, err := tx.Exec(`
+			INSERT INTO survey_questions (survey_id, position, kind, label, required, options, multiple)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			id, q.Position, q.Kind, q.Label, q.Required, pq.Array(q.Options), q.Multiple)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sv, err := getSurvey(tx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return sv, nil
+}
+
+// Delete removes the survey of an event along with its responses
+func (r *SurveyRepository) Delete(eventID int) error {
+	defer metrics.TrackQuery("surveys", "Delete")()
+
+	result, err := r.db.Exec(`DELETE FROM surveys WHERE event_id = $1`, eventID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Submit records the response of the member whose link holds the token
+// hash, who must have attended the event (any occurrence for a series).
+// ErrInvalidSurveyToken is returned for an unknown or expired token; a
+// second response fails on the unique key.
+func (r *SurveyRepository) Submit(sv *models.Survey, tokenHash string, req *models.SurveyResponseRequest) error {
+	defer metrics.TrackQuery("surveys", "Submit")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var memberID int
+	err = tx.QueryRow(`
+		SELECT member_id FROM survey_invitations
+		WHERE survey_id = $1 AND token_hash = $2 AND expires_at > NOW()
+		FOR UPDATE`, sv.ID, tokenHash).Scan(&memberID)
+	if err == sql.ErrNoRows {
+		return ErrInvalidSurveyToken
+	}
+	if err != nil {
+		return err
+	}
+
+	attended, err := attended(tx, sv.EventID, memberID)
+	if err != nil {
+		return err
+	}
+	if !attended {
+		return ErrNotAttended
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO survey_responses (survey_id, member_id) VALUES ($1, $2)
+		RETURNING id`, sv.ID, memberID).Scan(&id)
+	if err != nil {
+		return err
+	}
+	for _, a := range req.Answers {
+		choices := a.Choices
+		if choices == nil {
+			choices = []string{}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO survey_answers (response_id, question_id, rating, choices, text)
+			VALUES ($1, $2, $3, $4, $5)`,
+			id, a.QuestionID, a.Rating, pq.Array(choices), a.Text)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Les autres liens du membre ne servent plus
+	if _, err := tx.Exec(`DELETE FROM survey_invitations WHERE survey_id = $1 AND member_id = $2`, sv.ID, memberID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// attended reports whether the member attended the event
+func attended(q querier, eventID, memberID int) (bool, error) {
+	var ok bool
+	err := q.QueryRow(`
+		SELECT EXISTS(
+		    SELECT 1 FROM event_registrations
+		    WHERE event_id = $1 AND member_id = $2 AND attended_at IS NOT NULL)`,
+		eventID, memberID).Scan(&ok)
+	return ok, err
+}
+
+// Invite records the link of a member who attended the event of the
+// survey; ErrNotAttended is returned for other members
+func (r *SurveyRepository) Invite(sv *models.Survey, memberID int, tokenHash string, expiresAt time.Time) error {
+	defer metrics.TrackQuery("surveys", "Invite")()
+
+	attended, err := attended(r.db, sv.EventID, memberID)
+	if err != nil {
+		return err
+	}
+	if !attended {
+		return ErrNotAttended
+	}
+
+	_, err = r.db.Exec(`
+		INSERT INTO survey_invitations (survey_id, member_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`, sv.ID, memberID, tokenHash, expiresAt.UTC())
+	return err
+}
+
+// scanAnswers reads rows of (response ID, answer) and appends each answer
+// to the slice that answers returns for its response
+func scanAnswers(rows *sql.Rows, answers func(responseID int) *[]models.SurveyAnswer) error {
+	defer rows.Close()
+
+	for rows.Next() {
+		var responseID int
+		var a models.SurveyAnswer
+		if err := rows.Scan(&responseID, &a.QuestionID, &a.Rating, pq.Array(&a.Choices), &a.Text); err != nil {
+			return err
+		}
+		if len(a.Choices) == 0 {
+			a.Choices = nil
+		}
+		list := answers(responseID)
+		*list = append(*list, a)
+	}
+
+	return rows.Err()
+}
+
+// Responses lists the anonymous responses to a survey, oldest first
+func (r *SurveyRepository) Responses(surveyID int) ([]models.SurveyResponse, error) {
+	defer metrics.TrackQuery("surveys", "Responses")()
+
+	responses := []models.SurveyResponse{}
+	index := make(map[int]int)
+
+	rows, err := r.db.Query(`
+		SELECT sr.id, sr.submitted_at
+		FROM survey_responses sr
+		WHERE sr.survey_id = $1
+		ORDER BY sr.submitted_at, sr.id`, surveyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var resp models.SurveyResponse
+		if err := rows.Scan(&resp.ID, &resp.SubmittedAt); err != nil {
+			return nil, err
+		}
+		resp.Answers = []models.SurveyAnswer{}
+		index[resp.ID] = len(responses)
+		responses = append(responses, resp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	answerRows, err := r.db.Query(`
+		SELECT a.response_id, a.question_id, a.rating, a.choices, a.text
+		FROM survey_answers a
+		JOIN survey_responses sr ON sr.id = a.response_id
+		JOIN survey_questions q ON q.id = a.question_id
+		WHERE sr.survey_id = $1
+		ORDER BY q.position`, surveyID)
+	if err != nil {
+		return nil, err
+	}
+	err = scanAnswers(answerRows, func(responseID int) *[]models.SurveyAnswer {
+		return &responses[index[responseID]].Answers
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// GetResponsesByMember lists the survey responses of a member
+func (r *SurveyRepository) GetResponsesByMember(memberID int) ([]models.SurveyResponseRecord, error) {
+	defer metrics.TrackQuery("surveys", "GetResponsesByMember")()
+
+	var records []models.SurveyResponseRecord
+	index := make(map[int]int)
+
+	rows, err := r.db.Query(`
+		SELECT sr.id, s.id, e.id, e.title, sr.submitted_at
+		FROM survey_responses sr
+		JOIN surveys s ON s.id = sr.survey_id
+		JOIN events e ON e.id = s.event_id
+		WHERE sr.member_id = $1
+		ORDER BY sr.submitted_at DESC`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var rec models.SurveyResponseRecord
+		if err := rows.Scan(&id, &rec.SurveyID, &rec.EventID, &rec.EventTitle, &rec.SubmittedAt); err != nil {
+			return nil, err
+		}
+		rec.Answers = []models.SurveyAnswer{}
+		index[id] = len(records)
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	answerRows, err := r.db.Query(`
+		SELECT a.response_id, a.question_id, a.rating, a.choices, a.text
+		FROM survey_answers a
+		JOIN survey_responses sr ON sr.id = a.response_id
+		JOIN survey_questions q ON q.id = a.question_id
+		WHERE sr.member_id = $1
+		ORDER BY q.position`, memberID)
+	if err != nil {
+		return nil, err
+	}
+	err = scanAnswers(answerRows, func(responseID int) *[]models.SurveyAnswer {
+		return &records[index[responseID]].Answers
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"beautiful-minds/backend/project/internal/metrics"
+	"beautiful-minds/backend/project/internal/models"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrUnknownVenue is returned when an event references a missing venue
+var ErrUnknownVenue = errors.New("salle inconnue")
+
+// BookingConflictError is returned when an event or a session would overlap
+// the other bookings of its venue
+type BookingConflictError struct {
+	Conflicts []models.Booking
+}
+
+func (e *BookingConflictError) Error() string {
+	return "la salle est déjà réservée sur ce créneau"
+}
+
+// bookingHorizon bounds the conflict check of series that never end
+const bookingHorizon = 366 * 24 * time.Hour
+
+const venueColumns = `id, name, building, capacity, equipment, notes, created_at, updated_at`
+
+type VenueRepository struct {
+	db *sql.DB
+}
+
+func NewVenueRepository(db *sql.DB) *VenueRepository {
+	return &VenueRepository{db: db}
+}
+
+func scanVenue(s scanner) (*models.Venue, error) {
+	var v models.Venue
+	err := s.Scan(&v.ID, &v.Name, &v.Building, &v.Capacity, pq.Array(&v.Equipment),
+		&v.Notes, &v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if v.Equipment == nil {
+		v.Equipment = []string{}
+	}
+	return &v, nil
+}
+
+func (r *VenueRepository) GetAll() ([]models.Venue, error) {
+	defer metrics.TrackQuery("venues", "GetAll")()
+
+	rows, err := r.db.Query(`SELECT ` + venueColumns + ` FROM venues ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	venues := []models.Venue{}
+	for rows.Next() {
+		v, err := scanVenue(rows)
+		if err != nil {
+			return nil, err
+		}
+		venues = append(venues, *v)
+	}
+
+	return venues, rows.Err()
+}
+
+func (r *VenueRepository) GetByID(id int) (*models.Venue, error) {
+	defer metrics.TrackQuery("venues", "GetByID")()
+
+	return scanVenue(r.db.QueryRow(`SELECT `+venueColumns+` FROM venues WHERE id = $1`, id))
+}
+
+func (r *VenueRepository) Create(req *models.CreateVenueRequest) (*models.Venue, error) {
+	defer metrics.TrackQuery("venues", "Create")()
+
+	query := `
+		INSERT INTO venues (name, building, capacity, equipment, notes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + venueColumns
+
+	return scanVenue(r.db.QueryRow(query, req.Name, req.Building, req.Capacity, pq.Array(req.Equipment), req.Notes))
+}
+
+// Update changes a venue; the location of its events follows a rename
+func (r *VenueRepository) Update(id int, req *models.CreateVenueRequest) (*models.Venue, error) {
+	defer metrics.TrackQuery("venues", "Update")()
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE venues
+		SET name = $1, building = $2, capacity = $3, equipment = $4, notes = $5, updated_at = NOW()
+		WHERE id = $6
+		RETURNING ` + venueColumns
+
+	v, err := scanVenue(tx.QueryRow(query, req.Name, req.Building, req.Capacity, pq.Array(req.Equipment), req.Notes, id))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE events SET location = $1 WHERE venue_id = $2`, v.Name, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Delete removes a venue; its events keep their location text
+func (r *VenueRepository) Delete(id int) error {
+	defer metrics.TrackQuery("venues", "Delete")()
+
+	result, err := r.db.Exec(`DELETE FROM venues WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// Bookings lists the time slots held in a venue within [from, to)
+func (r *VenueRepository) Bookings(id int, from, to time.Time) ([]models.Booking, error) {
+	defer metrics.TrackQuery("venues", "Bookings")()
+
+	bookings, err := venueBookings(r.db, id, 0, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if bookings == nil {
+		bookings = []models.Booking{}
+	}
+	return bookings, nil
+}
+
+// venueBookings collects the bookings of the events and sessions held in a
+// venue within [from, to), leaving out the event being written
+// (excludeEventID) and its sessions
+func venueBookings(q querier, venueID, excludeEventID int, from, to time.Time) ([]models.Booking, error) {
+	bookings, err := eventBookings(q, venueID, excludeEventID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := sessionBookings(q, venueID, excludeEventID, 0, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	bookings = append(bookings, sessions...)
+	sort.Slice(bookings, func(i, j int) bool { return bookings[i].Start.Before(bookings[j].Start) })
+	return bookings, nil
+}
+
+// eventBookings collects the bookings of the events held in a venue within
+// [from, to), leaving out excludeEventID
+func eventBookings(q querier, venueID, excludeEventID int, from, to time.Time) ([]models.Booking, error) {
+	rows, err := q.Query(`
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE venue_id = $1 AND id <> $2 AND deleted_at IS NULL AND date < $4
+		  AND (end_date > $3 OR (recurrence_rule IS NOT NULL
+		       AND (recurrence_end IS NULL OR recurrence_end + (end_date - date) > $3)))`,
+		venueID, excludeEventID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	var events []models.Event
+	var series []int
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		events = append(events, *e)
+		if e.RecurrenceRule != nil {
+			series = append(series, e.ID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	overrides, err := loadOverrides(q, series)
+	if err != nil {
+		return nil, err
+	}
+
+	var bookings []models.Booking
+	for _, e := range events {
+		bookings = append(bookings, e.Bookings(from, to, overrides[e.ID])...)
+	}
+	return bookings, nil
+}
+
+// sessionBookings collects the sessions held in a venue within [from, to),
+// leaving out the sessions of excludeEventID and the session
+// excludeSessionID. Sessions of events in the trash free their venue.
+func sessionBookings(q querier, venueID, excludeEventID, excludeSessionID int, from, to time.Time) ([]models.Booking, error) {
+	rows, err := q.Query(`
+		SELECT s.id, s.event_id, s.title, s.start_time, s.end_time
+		FROM event_sessions s
+		JOIN events e ON e.id = s.event_id
+		WHERE s.venue_id = $1 AND s.event_id <> $2 AND s.id <> $3 AND e.deleted_at IS NULL
+		  AND s.start_time < $5 AND s.end_time > $4`,
+		venueID, excludeEventID, excludeSessionID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookings []models.Booking
+	for rows.Next() {
+		var b models.Booking
+		var sessionID int
+		if err := rows.Scan(&sessionID, &b.EventID, &b.Title, &b.Start, &b.End); err != nil {
+			return nil, err
+		}
+		b.SessionID = &sessionID
+		bookings = append(bookings, b)
+	}
+	return bookings, rows.Err()
+}
+
+// lockVenue reads the venue booked by an event or a session being written
+// and locks it until the transaction ends, so that concurrent bookings are checked one
+// after the other
+func lockVenue(tx *sql.Tx, id int) (*models.Venue, error) {
+	v, err := scanVenue(tx.QueryRow(`SELECT `+venueColumns+` FROM venues WHERE id = $1 FOR UPDATE`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrUnknownVenue
+	}
+	return v, err
+}
+
+// lockEventVenue reads an event, in the trash or not, and locks its venue
+// like lockVenue, for a write that changes when the event takes place
+func lockEventVenue(tx *sql.Tx, eventID int) (*models.Event, error) {
+	e, err := scanEvent(tx.QueryRow(`SELECT `+eventColumns+` FROM events WHERE id = $1`, eventID))
+	if err != nil || e.VenueID == nil {
+		return e, err
+	}
+	if _, err := lockVenue(tx, *e.VenueID); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// checkBookings returns a *BookingConflictError when the event overlaps
+// another event booked in the same venue
+func checkBookings(tx *sql.Tx, e *models.Event) error {
+	if e.VenueID == nil {
+		return nil
+	}
+
+	from, to := e.Date, e.EndDate
+	if e.RecurrenceRule != nil {
+		if end := e.SeriesEnd(); end != nil {
+			to = end.Add(e.Duration())
+		} else {
+			to = from.Add(bookingHorizon)
+		}
+	}
+
+	others, err := venueBookings(tx, *e.VenueID, e.ID, from, to)
+	if err != nil || len(others) == 0 {
+		return err
+	}
+
+	overrides, err := loadOverrides(tx, []int{e.ID})
+	if err != nil {
+		return err
+	}
+
+	var conflicts []models.Booking
+	own := e.Bookings(from, to, overrides[e.ID])
+	for _, other := range others {
+		for _, b := range own {
+			if b.Overlaps(other) {
+				conflicts = append(conflicts, other)
+				break
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return &BookingConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
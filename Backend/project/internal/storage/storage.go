@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"beautiful-minds/backend/project/config"
+)
+
+// ErrNotFound is returned when reading a key that holds no file
+var ErrNotFound = errors.New("fichier introuvable")
+
+// Store keeps uploaded files under flat keys such as "3f2a….jpg". A remote
+// implementation (S3-compatible bucket) only has to satisfy this interface;
+// the local one, pointed at a temporary directory, stands in for it in
+// tests.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the file of key; ErrNotFound when there is none
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file of key; deleting a missing file succeeds
+	Delete(ctx context.Context, key string) error
+	// URL returns the address the file of key is served at
+	URL(key string) string
+}
+
+// New returns the store selected by the configuration. Files of the local
+// store are served by the API under baseURL.
+func New(cfg *config.MediaConfig, baseURL string) (Store, error) {
+	switch cfg.Driver {
+	case "local":
+		return NewLocal(cfg.Dir, baseURL)
+	default:
+		return nil, fmt.Errorf("stockage %q inconnu", cfg.Driver)
+	}
+}
+
+// Local stores the files in a directory of the server
+type Local struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocal creates dir if needed and returns a store whose files are
+// served under baseURL
+func NewLocal(dir, baseURL string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+// path maps a key into the directory, refusing keys that would escape it
+func (s *Local) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("clé de stockage invalide %q", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+// Put writes to a temporary file renamed once complete, so that readers
+// never see a partial file
+func (s *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *Local) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Local) URL(key string) string {
+	return s.baseURL + "/" + key
+}
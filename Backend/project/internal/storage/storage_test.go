@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	s, err := NewLocal(t.TempDir(), "http://localhost:8080/api/v1/media/files/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	s := newTestLocal(t)
+
+	if err := s.Put(ctx, "photo.jpg", strings.NewReader("contenu"), 7, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	f, err := s.Get(ctx, "photo.jpg")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(data) != "contenu" {
+		t.Fatalf("read %q, %v; want %q", data, err, "contenu")
+	}
+
+	if got := s.URL("photo.jpg"); got != "http://localhost:8080/api/v1/media/files/photo.jpg" {
+		t.Errorf("URL = %q", got)
+	}
+
+	// No temporary file is left behind
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("%d files in the directory, want 1", len(entries))
+	}
+
+	if err := s.Delete(ctx, "photo.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "photo.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: got %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "photo.jpg"); err != nil {
+		t.Errorf("deleting a missing file: %v", err)
+	}
+}
+
+func TestLocalRejectsKeys(t *testing.T) {
+	ctx := context.Background()
+	s := newTestLocal(t)
+
+	// A file next to the store that escaping keys would reach
+	outside := filepath.Join(filepath.Dir(s.dir), "secret.txt")
+	if err := os.WriteFile(outside, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"", "../secret.txt", "sub/photo.jpg", `sub\photo.jpg`, ".upload-123", ".."} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, "image/png"); err == nil {
+			t.Errorf("Put(%q) succeeded", key)
+		}
+		if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q): got %v, want ErrNotFound", key, err)
+		}
+		if err := s.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded", key)
+		}
+	}
+
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the store: %v", err)
+	}
+}
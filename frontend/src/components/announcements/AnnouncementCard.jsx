@@ -16,7 +16,12 @@ const AnnouncementCard = ({ announcement }) => {
       {announcement.is_pinned && <span className="pin-badge">📌 Épinglé</span>}
       <h3>{announcement.title}</h3>
       <p className="announcement-date">{formatDate(announcement.published_date)}</p>
-      <p className="announcement-content">{announcement.content}</p>
+      {announcement.content_html ? (
+        // HTML assaini par le serveur
+        <div className="announcement-content" dangerouslySetInnerHTML={{ __html: announcement.content_html }} />
+      ) : (
+        <p className="announcement-content">{announcement.content}</p>
+      )}
     </div>
   );
 };
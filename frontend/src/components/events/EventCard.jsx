@@ -1,37 +1,65 @@
 import React from 'react';
 import './EventCard.css';
 
-const EventCard = ({ event }) => {
+const EventCard = ({ event, onTagClick }) => {
+  // Horaires affichés dans le fuseau de l'événement
   const formatDate = (dateString) => {
     const options = { 
       year: 'numeric', 
       month: 'long', 
       day: 'numeric',
       hour: '2-digit',
-      minute: '2-digit'
+      minute: '2-digit',
+      timeZone: event.timezone
     };
     return new Date(dateString).toLocaleDateString('fr-FR', options);
   };
 
+  const formatTime = (dateString) =>
+    new Date(dateString).toLocaleTimeString('fr-FR', { hour: '2-digit', minute: '2-digit', timeZone: event.timezone });
+
   return (
     <div className="event-card">
       <div className="event-image">
         {event.image_url ? (
-          <img src={event.image_url} alt={event.title} />
+          <img src={event.image ? event.image.thumbnail_url : event.image_url} alt={event.title} />
         ) : (
           <div className="event-image-placeholder">📅</div>
         )}
       </div>
       <div className="event-content">
+        {event.categories && event.categories.length > 0 && (
+          <div className="event-categories">
+            {event.categories.map((c) => (
+              <span key={c.id} className="event-category" style={c.color ? { background: c.color } : undefined}>
+                {c.name}
+              </span>
+            ))}
+          </div>
+        )}
         <h3>{event.title}</h3>
-        <p className="event-date">📆 {formatDate(event.date)}</p>
+        <p className="event-date">📆 {formatDate(event.date)}{event.end_date && ` – ${formatTime(event.end_date)}`}</p>
         <p className="event-location">📍 {event.location}</p>
-        <p className="event-description">{event.description}</p>
+        <p className="event-description">{event.excerpt ?? event.description}</p>
+        {event.tags && event.tags.length > 0 && (
+          <div className="event-tags">
+            {event.tags.map((t) => (
+              <button key={t} className="event-tag" onClick={() => onTagClick && onTagClick(t)}>
+                #{t}
+              </button>
+            ))}
+          </div>
+        )}
         {event.max_participants && (
           <p className="event-participants">
             👥 Places disponibles: {event.max_participants}
           </p>
         )}
+        {event.average_rating != null && (
+          <p className="event-rating">
+            ⭐ {event.average_rating.toFixed(1)} / 5 ({event.rating_count} avis)
+          </p>
+        )}
         <button className="btn btn-primary">S'inscrire à l'événement</button>
       </div>
     </div>
@@ -1,18 +1,29 @@
 import React, { useState, useEffect } from 'react';
 import EventCard from './EventCard';
-import { eventAPI } from '../../services/api';
+import { eventAPI, categoryAPI } from '../../services/api';
 import './EventList.css';
 
 const EventList = () => {
   const [events, setEvents] = useState([]);
+  const [categories, setCategories] = useState([]);
+  const [category, setCategory] = useState('');
+  const [tag, setTag] = useState('');
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState(null);
 
+  // Les compteurs des pastilles suivent l'étiquette sélectionnée
+  useEffect(() => {
+    categoryAPI.getAll(tag)
+      .then((data) => setCategories(data || []))
+      .catch((err) => console.error(err));
+  }, [tag]);
+
   useEffect(() => {
     const fetchEvents = async () => {
       try {
-        const data = await eventAPI.getAll();
+        const data = await eventAPI.getAll({ category, tag });
         setEvents(data || []);
+        setError(null);
       } catch (err) {
         setError('Impossible de charger les événements. Vérifiez que le serveur backend est démarré.');
         console.error(err);
@@ -22,7 +33,7 @@ const EventList = () => {
     };
 
     fetchEvents();
-  }, []);
+  }, [category, tag]);
 
   if (loading) return <div className="loading">Chargement...</div>;
   if (error) return <div className="error">{error}</div>;
@@ -30,12 +41,38 @@ const EventList = () => {
   return (
     <div className="event-list">
       <h2>Événements à venir</h2>
+      {categories.length > 0 && (
+        <div className="category-chips">
+          <button
+            className={`chip ${category === '' ? 'active' : ''}`}
+            onClick={() => setCategory('')}
+          >
+            Tous
+          </button>
+          {categories.map((c) => (
+            <button
+              key={c.id}
+              className={`chip ${category === c.slug ? 'active' : ''}`}
+              style={c.color ? { borderColor: c.color } : undefined}
+              onClick={() => setCategory(c.slug)}
+              disabled={c.event_count === 0 && category !== c.slug}
+            >
+              {c.name} <span className="chip-count">{c.event_count}</span>
+            </button>
+          ))}
+        </div>
+      )}
+      {tag && (
+        <p className="active-tag">
+          Étiquette : #{tag} <button className="chip" onClick={() => setTag('')}>✕</button>
+        </p>
+      )}
       {events.length === 0 ? (
         <p className="no-events">Aucun événement programmé pour le moment.</p>
       ) : (
         <div className="event-grid">
           {events.map((event) => (
-            <EventCard key={event.id} event={event} />
+            <EventCard key={`${event.id}-${event.occurrence_start || ""}`} event={event} onTagClick={setTag} />
           ))}
         </div>
       )}
@@ -43,4 +80,4 @@ const EventList = () => {
   );
 };
 
-export default EventList;
\ No newline at end of file
+export default EventList;
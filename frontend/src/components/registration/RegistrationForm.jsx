@@ -1,5 +1,5 @@
-import React, { useState } from 'react';
-import { memberAPI } from '../../services/api';
+import React, { useState, useEffect } from 'react';
+import { memberAPI, formAPI } from '../../services/api';
 import './RegistrationForm.css';
 
 const RegistrationForm = () => {
@@ -15,6 +15,16 @@ const RegistrationForm = () => {
   const [loading, setLoading] = useState(false);
   const [success, setSuccess] = useState(false);
   const [error, setError] = useState(null);
+  // Jeton anti-spam, renouvelé à chaque affichage du formulaire
+  const [formToken, setFormToken] = useState(null);
+  const [honeypot, setHoneypot] = useState('');
+
+  useEffect(() => {
+    if (success) return;
+    formAPI.getToken()
+      .then(setFormToken)
+      .catch((err) => console.error('Form token error:', err));
+  }, [success]);
 
   const handleChange = (e) => {
     setFormData({
@@ -29,7 +39,8 @@ const RegistrationForm = () => {
     setError(null);
 
     try {
-      await memberAPI.create(formData);
+      const payload = formToken ? { ...formData, [formToken.honeypot]: honeypot } : formData;
+      await memberAPI.create(payload, formToken);
       setSuccess(true);
       setFormData({
         first_name: '',
@@ -74,6 +85,20 @@ const RegistrationForm = () => {
       <h2>Inscription au Club Scientifique</h2>
       
       {error && <div className="error">{error}</div>}
+
+      {/* Champ piège invisible : seuls les robots le remplissent */}
+      <div className="form-group" aria-hidden="true" style={{ position: 'absolute', left: '-10000px' }}>
+        <label htmlFor="website">Site web</label>
+        <input
+          type="text"
+          id="website"
+          name="website"
+          value={honeypot}
+          onChange={(e) => setHoneypot(e.target.value)}
+          tabIndex="-1"
+          autoComplete="off"
+        />
+      </div>
       
       <div className="form-row">
         <div className="form-group">
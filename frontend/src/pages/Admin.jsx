@@ -1,21 +1,45 @@
 import React, { useState, useEffect } from 'react';
-import { memberAPI, eventAPI, announcementAPI } from '../services/api';
+import { memberAPI, eventAPI, announcementAPI, formAPI, venueAPI } from '../services/api';
 import * as XLSX from 'xlsx';
 import './Admin.css';
 
+// Comma separated input to list ("Info, Maths" -> ["Info", "Maths"])
+const splitList = (value) => value.split(',').map((v) => v.trim()).filter(Boolean);
+
+const audienceLabel = (audience) => {
+  if (!audience) return 'Tous';
+  const parts = [
+    ...(audience.fields_of_study || []),
+    ...(audience.roles || []).map((r) => `@${r}`),
+    ...(audience.event_ids || []).map((id) => `inscrits #${id}`),
+  ];
+  return parts.length ? parts.join(', ') : 'Tous';
+};
+
 const Admin = () => {
   const [activeTab, setActiveTab] = useState('members');
   const [members, setMembers] = useState([]);
   const [events, setEvents] = useState([]);
   const [announcements, setAnnouncements] = useState([]);
+  const [venues, setVenues] = useState([]);
   const [loading, setLoading] = useState(false);
   const [editingId, setEditingId] = useState(null);
   const [editForm, setEditForm] = useState({});
   const [showAddForm, setShowAddForm] = useState(false);
   const [newForm, setNewForm] = useState({});
+  const [formToken, setFormToken] = useState(null);
   const [error, setError] = useState('');
   const [success, setSuccess] = useState('');
 
+  // Member creation goes through the anti-spam check, fetch a token when the form opens
+  useEffect(() => {
+    if (showAddForm && activeTab === 'members') {
+      formAPI.getToken()
+        .then(setFormToken)
+        .catch((err) => console.error('Form token error:', err));
+    }
+  }, [showAddForm, activeTab]);
+
   // Load data on tab change
   useEffect(() => {
     loadData();
@@ -30,10 +54,12 @@ const Admin = () => {
         const data = await memberAPI.getAll();
         setMembers(data || []);
       } else if (activeTab === 'events') {
-        const data = await eventAPI.getAll();
+        const [data, rooms] = await Promise.all([eventAPI.getAll({ expand: 'false' }), venueAPI.getAll()]);
         setEvents(data || []);
+        setVenues(rooms || []);
       } else if (activeTab === 'announcements') {
-        const data = await announcementAPI.getAll();
+        // Brouillons, programmées et expirées comprises (jeton administrateur)
+        const data = await announcementAPI.getAll('all');
         setAnnouncements(data || []);
       }
     } catch (error) {
@@ -45,7 +71,12 @@ const Admin = () => {
 
   const handleEdit = (item) => {
     setEditingId(item.id);
-    setEditForm({...item});
+    if (activeTab === 'events') {
+      // Heures locales de l'événement, relues dans son fuseau par l'API
+      setEditForm({...item, date: item.local_start?.slice(0, 16), end_date: item.local_end?.slice(0, 16)});
+    } else {
+      setEditForm({...item});
+    }
   };
 
   const handleSave = async () => {
@@ -53,23 +84,12 @@ const Admin = () => {
       setError('');
       setSuccess('');
       if (activeTab === 'members') {
-        await fetch(`http://localhost:8080/api/members/${editingId}`, {
-          method: 'PUT',
-          headers: { 'Content-Type': 'application/json' },
-          body: JSON.stringify(editForm)
-        });
+        await memberAPI.update(editingId, editForm);
       } else if (activeTab === 'events') {
-        await fetch(`http://localhost:8080/api/events/${editingId}`, {
-          method: 'PUT',
-          headers: { 'Content-Type': 'application/json' },
-          body: JSON.stringify(editForm)
-        });
+        const saved = await eventAPI.update(editingId, editForm);
+        if (saved.warnings) setError(saved.warnings.join(' — '));
       } else if (activeTab === 'announcements') {
-        await fetch(`http://localhost:8080/api/announcements/${editingId}`, {
-          method: 'PUT',
-          headers: { 'Content-Type': 'application/json' },
-          body: JSON.stringify(editForm)
-        });
+        await announcementAPI.update(editingId, editForm);
       }
       setEditingId(null);
       setSuccess('Élément modifié avec succès');
@@ -86,9 +106,10 @@ const Admin = () => {
       setError('');
       setSuccess('');
       if (activeTab === 'members') {
-        await memberAPI.create(newForm);
+        await memberAPI.create(newForm, formToken);
       } else if (activeTab === 'events') {
-        await eventAPI.create(newForm);
+        const saved = await eventAPI.create(newForm);
+        if (saved.warnings) setError(saved.warnings.join(' — '));
       } else if (activeTab === 'announcements') {
         await announcementAPI.create(newForm);
       }
@@ -103,18 +124,18 @@ const Admin = () => {
     }
   };
 
-  const handleDelete = async (id) => {
+  const handleDelete = async ({ id, version }) => {
     if (!window.confirm('Êtes-vous sûr de vouloir supprimer ?')) return;
     
     try {
       setError('');
       setSuccess('');
       if (activeTab === 'members') {
-        await fetch(`http://localhost:8080/api/members/${id}`, { method: 'DELETE' });
+        await memberAPI.delete(id, version);
       } else if (activeTab === 'events') {
-        await fetch(`http://localhost:8080/api/events/${id}`, { method: 'DELETE' });
+        await eventAPI.delete(id, version);
       } else if (activeTab === 'announcements') {
-        await fetch(`http://localhost:8080/api/announcements/${id}`, { method: 'DELETE' });
+        await announcementAPI.delete(id, version);
       }
       setSuccess('Élément supprimé avec succès');
       setTimeout(() => setSuccess(''), 3000);
@@ -211,6 +232,10 @@ const Admin = () => {
                   <label>Filière</label>
                   <input type="text" value={newForm.field_of_study || ''} onChange={(e) => setNewForm({...newForm, field_of_study: e.target.value})} placeholder="Filière" />
                 </div>
+                <div className="form-group">
+                  <label>Rôles</label>
+                  <input type="text" onChange={(e) => setNewForm({...newForm, roles: splitList(e.target.value)})} placeholder="bureau, robotique" />
+                </div>
               </div>
               <button onClick={handleAdd} className="btn-save">✓ Ajouter</button>
             </div>
@@ -224,6 +249,7 @@ const Admin = () => {
                 <th>Nom</th>
                 <th>Email</th>
                 <th>Filière</th>
+                <th>Rôles</th>
                 <th>Actions</th>
               </tr>
             </thead>
@@ -236,6 +262,7 @@ const Admin = () => {
                     <td><input value={editForm.last_name || ''} onChange={(e) => setEditForm({...editForm, last_name: e.target.value})} /></td>
                     <td><input value={editForm.email || ''} onChange={(e) => setEditForm({...editForm, email: e.target.value})} /></td>
                     <td><input value={editForm.field_of_study || ''} onChange={(e) => setEditForm({...editForm, field_of_study: e.target.value})} /></td>
+                    <td><input defaultValue={(member.roles || []).join(', ')} onChange={(e) => setEditForm({...editForm, roles: splitList(e.target.value)})} /></td>
                     <td>
                       <button onClick={handleSave} className="btn-save">✓ Sauvegarder</button>
                       <button onClick={() => setEditingId(null)} className="btn-cancel">✕ Annuler</button>
@@ -248,9 +275,10 @@ const Admin = () => {
                     <td>{member.last_name}</td>
                     <td>{member.email}</td>
                     <td>{member.field_of_study}</td>
+                    <td>{(member.roles || []).join(', ')}</td>
                     <td>
                       <button onClick={() => handleEdit(member)} className="btn-edit">Éditer</button>
-                      <button onClick={() => handleDelete(member.id)} className="btn-delete">Supprimer</button>
+                      <button onClick={() => handleDelete(member)} className="btn-delete">Supprimer</button>
                     </td>
                   </tr>
                 )
@@ -283,13 +311,32 @@ const Admin = () => {
                   <textarea value={newForm.description || ''} onChange={(e) => setNewForm({...newForm, description: e.target.value})} placeholder="Description" />
                 </div>
                 <div className="form-group">
-                  <label>Date *</label>
+                  <label>Début *</label>
                   <input type="datetime-local" value={newForm.date || ''} onChange={(e) => setNewForm({...newForm, date: e.target.value})} />
                 </div>
                 <div className="form-group">
-                  <label>Lieu *</label>
-                  <input type="text" value={newForm.location || ''} onChange={(e) => setNewForm({...newForm, location: e.target.value})} placeholder="Lieu" />
+                  <label>Fin</label>
+                  <input type="datetime-local" value={newForm.end_date || ''} onChange={(e) => setNewForm({...newForm, end_date: e.target.value})} />
                 </div>
+                <div className="form-group">
+                  <label>Fuseau horaire</label>
+                  <input type="text" value={newForm.timezone || ''} onChange={(e) => setNewForm({...newForm, timezone: e.target.value})} placeholder="Europe/Paris" />
+                </div>
+                <div className="form-group">
+                  <label>Salle</label>
+                  <select value={newForm.venue_id || ''} onChange={(e) => setNewForm({...newForm, venue_id: e.target.value ? Number(e.target.value) : null})}>
+                    <option value="">Autre lieu</option>
+                    {venues.map(v => (
+                      <option key={v.id} value={v.id}>{v.name} ({v.capacity} places)</option>
+                    ))}
+                  </select>
+                </div>
+                {!newForm.venue_id && (
+                  <div className="form-group">
+                    <label>Lieu *</label>
+                    <input type="text" value={newForm.location || ''} onChange={(e) => setNewForm({...newForm, location: e.target.value})} placeholder="Lieu" />
+                  </div>
+                )}
                 <div className="form-group">
                   <label>URL Image</label>
                   <input type="url" value={newForm.image_url || ''} onChange={(e) => setNewForm({...newForm, image_url: e.target.value})} placeholder="URL de l'image" />
@@ -298,6 +345,10 @@ const Admin = () => {
                   <label>Max Participants</label>
                   <input type="number" value={newForm.max_participants || ''} onChange={(e) => setNewForm({...newForm, max_participants: e.target.value})} placeholder="Nombre max" />
                 </div>
+                <div className="form-group">
+                  <label>Places invités</label>
+                  <input type="number" min="0" value={newForm.guest_capacity || ''} onChange={(e) => setNewForm({...newForm, guest_capacity: Number(e.target.value)})} placeholder="0 : réservé aux membres" />
+                </div>
               </div>
               <button onClick={handleAdd} className="btn-save">✓ Ajouter</button>
             </div>
@@ -320,8 +371,21 @@ const Admin = () => {
                   <tr key={event.id} className="edit-row">
                     <td>{event.id}</td>
                     <td><input value={editForm.title || ''} onChange={(e) => setEditForm({...editForm, title: e.target.value})} /></td>
-                    <td><input type="datetime-local" value={editForm.date || ''} onChange={(e) => setEditForm({...editForm, date: e.target.value})} /></td>
-                    <td><input value={editForm.location || ''} onChange={(e) => setEditForm({...editForm, location: e.target.value})} /></td>
+                    <td>
+                      <input type="datetime-local" value={editForm.date || ''} onChange={(e) => setEditForm({...editForm, date: e.target.value})} />
+                      <input type="datetime-local" value={editForm.end_date || ''} onChange={(e) => setEditForm({...editForm, end_date: e.target.value})} />
+                    </td>
+                    <td>
+                      <select value={editForm.venue_id || ''} onChange={(e) => setEditForm({...editForm, venue_id: e.target.value ? Number(e.target.value) : null})}>
+                        <option value="">Autre lieu</option>
+                        {venues.map(v => (
+                          <option key={v.id} value={v.id}>{v.name}</option>
+                        ))}
+                      </select>
+                      {!editForm.venue_id && (
+                        <input value={editForm.location || ''} onChange={(e) => setEditForm({...editForm, location: e.target.value})} />
+                      )}
+                    </td>
                     <td><input type="number" value={editForm.max_participants || ''} onChange={(e) => setEditForm({...editForm, max_participants: e.target.value})} /></td>
                     <td>
                       <button onClick={handleSave} className="btn-save">✓ Sauvegarder</button>
@@ -332,12 +396,12 @@ const Admin = () => {
                   <tr key={event.id}>
                     <td>{event.id}</td>
                     <td>{event.title}</td>
-                    <td>{new Date(event.date).toLocaleDateString('fr-FR')}</td>
+                    <td>{new Date(event.date).toLocaleDateString('fr-FR', { timeZone: event.timezone })}</td>
                     <td>{event.location}</td>
                     <td>{event.max_participants}</td>
                     <td>
                       <button onClick={() => handleEdit(event)} className="btn-edit">Éditer</button>
-                      <button onClick={() => handleDelete(event.id)} className="btn-delete">Supprimer</button>
+                      <button onClick={() => handleDelete(event)} className="btn-delete">Supprimer</button>
                     </td>
                   </tr>
                 )
@@ -373,6 +437,34 @@ const Admin = () => {
                   <input type="checkbox" checked={newForm.is_pinned || false} onChange={(e) => setNewForm({...newForm, is_pinned: e.target.checked})} id="is_pinned" />
                   <label htmlFor="is_pinned">Épingler cette annonce</label>
                 </div>
+                <div className="form-group checkbox">
+                  <input type="checkbox" checked={newForm.draft || false} onChange={(e) => setNewForm({...newForm, draft: e.target.checked})} id="draft" />
+                  <label htmlFor="draft">Enregistrer comme brouillon</label>
+                </div>
+                <div className="form-group">
+                  <label>Publier le</label>
+                  <input type="datetime-local" onChange={(e) => setNewForm({...newForm, publish_at: e.target.value ? new Date(e.target.value).toISOString() : null})} />
+                </div>
+                <div className="form-group">
+                  <label>Expire le</label>
+                  <input type="datetime-local" onChange={(e) => setNewForm({...newForm, expires_at: e.target.value ? new Date(e.target.value).toISOString() : null})} />
+                </div>
+                <div className="form-group">
+                  <label>Public : filières</label>
+                  <input type="text" onChange={(e) => setNewForm({...newForm, audience: {...newForm.audience, fields_of_study: splitList(e.target.value)}})} placeholder="Tous si vide" />
+                </div>
+                <div className="form-group">
+                  <label>Public : rôles</label>
+                  <input type="text" onChange={(e) => setNewForm({...newForm, audience: {...newForm.audience, roles: splitList(e.target.value)}})} placeholder="robotique, bureau" />
+                </div>
+                <div className="form-group">
+                  <label>Public : inscrits aux événements (IDs)</label>
+                  <input type="text" onChange={(e) => setNewForm({...newForm, audience: {...newForm.audience, event_ids: splitList(e.target.value).map(Number)}})} placeholder="12, 15" />
+                </div>
+                <div className="form-group checkbox">
+                  <input type="checkbox" checked={newForm.notify || false} onChange={(e) => setNewForm({...newForm, notify: e.target.checked})} id="notify" />
+                  <label htmlFor="notify">Envoyer par email au public à la publication</label>
+                </div>
               </div>
               <button onClick={handleAdd} className="btn-save">✓ Ajouter</button>
             </div>
@@ -385,6 +477,8 @@ const Admin = () => {
                 <th>Titre</th>
                 <th>Contenu (aperçu)</th>
                 <th>Épinglée</th>
+                <th>Statut</th>
+                <th>Public</th>
                 <th>Actions</th>
               </tr>
             </thead>
@@ -396,6 +490,8 @@ const Admin = () => {
                     <td><input value={editForm.title || ''} onChange={(e) => setEditForm({...editForm, title: e.target.value})} /></td>
                     <td><textarea value={editForm.content || ''} onChange={(e) => setEditForm({...editForm, content: e.target.value})} rows="3" /></td>
                     <td><input type="checkbox" checked={editForm.is_pinned || false} onChange={(e) => setEditForm({...editForm, is_pinned: e.target.checked})} /></td>
+                    <td>{ann.status}</td>
+                    <td>{audienceLabel(ann.audience)}</td>
                     <td>
                       <button onClick={handleSave} className="btn-save">✓ Sauvegarder</button>
                       <button onClick={() => setEditingId(null)} className="btn-cancel">✕ Annuler</button>
@@ -407,9 +503,11 @@ const Admin = () => {
                     <td>{ann.title}</td>
                     <td>{ann.content.substring(0, 50)}...</td>
                     <td>{ann.is_pinned ? '✅' : '❌'}</td>
+                    <td>{ann.status}</td>
+                    <td>{audienceLabel(ann.audience)}</td>
                     <td>
                       <button onClick={() => handleEdit(ann)} className="btn-edit">Éditer</button>
-                      <button onClick={() => handleDelete(ann.id)} className="btn-delete">Supprimer</button>
+                      <button onClick={() => handleDelete(ann)} className="btn-delete">Supprimer</button>
                     </td>
                   </tr>
                 )
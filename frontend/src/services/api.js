@@ -1,14 +1,19 @@
-const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:8080/api';
+const API_BASE_URL = process.env.REACT_APP_API_URL || 'http://localhost:8080/api/v1';
+
+// Jeton administrateur envoyé en Bearer : il débloque les brouillons, la
+// corbeille, l'historique, les rôles... Sans lui, l'API répond en anonyme.
+const ADMIN_TOKEN = process.env.REACT_APP_ADMIN_TOKEN;
+const authHeaders = () => (ADMIN_TOKEN ? { Authorization: `Bearer ${ADMIN_TOKEN}` } : {});
 
 // Fonction générique pour les requêtes GET
 export const get = async (endpoint) => {
   try {
-    const response = await fetch(`${API_BASE_URL}${endpoint}`);
+    const response = await fetch(`${API_BASE_URL}${endpoint}`, { headers: authHeaders() });
     if (!response.ok) {
       let text = await response.text();
       try {
         const body = JSON.parse(text || '{}');
-        text = body.message || JSON.stringify(body) || text;
+        text = body.message || body.error || JSON.stringify(body) || text;
       } catch (e) {
         // not JSON, keep text
       }
@@ -22,12 +27,14 @@ export const get = async (endpoint) => {
 };
 
 // Fonction générique pour les requêtes POST
-export const post = async (endpoint, data) => {
+export const post = async (endpoint, data, headers = {}) => {
   try {
     const response = await fetch(`${API_BASE_URL}${endpoint}`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...authHeaders(),
+        ...headers,
       },
       body: JSON.stringify(data),
     });
@@ -35,7 +42,7 @@ export const post = async (endpoint, data) => {
       let text = await response.text();
       try {
         const body = JSON.parse(text || '{}');
-        text = body.message || JSON.stringify(body) || text;
+        text = body.message || body.error || JSON.stringify(body) || text;
       } catch (e) {
         // not JSON
       }
@@ -48,24 +55,189 @@ export const post = async (endpoint, data) => {
   }
 };
 
+// Fonction générique pour les requêtes avec corps JSON (PUT, DELETE...)
+const send = async (method, endpoint, data, headers = {}) => {
+  try {
+    const options = { method, headers: { ...authHeaders(), ...headers } };
+    if (data !== undefined) {
+      options.headers['Content-Type'] = 'application/json';
+      options.body = JSON.stringify(data);
+    }
+    const response = await fetch(`${API_BASE_URL}${endpoint}`, options);
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(`Erreur ${response.status}: ${text}`);
+    }
+    return await response.json();
+  } catch (error) {
+    console.error(`Erreur ${method}:`, error);
+    throw error;
+  }
+};
+
+// Le champ version renvoyé par l'API protège des modifications concurrentes (412)
+const ifMatch = (data) => (data && data.version ? { 'If-Match': `"${data.version}"` } : {});
+
+export const put = (endpoint, data) => send('PUT', endpoint, data, ifMatch(data));
+// version : obligatoire pour les membres, événements et annonces (428 sans If-Match)
+export const del = (endpoint, version) => send('DELETE', endpoint, undefined, ifMatch({ version }));
+
+// Envoi de fichiers (multipart/form-data) ; le navigateur fixe le Content-Type
+const upload = async (endpoint, fields) => {
+  const body = new FormData();
+  Object.entries(fields).forEach(([name, value]) => body.append(name, value));
+  const response = await fetch(`${API_BASE_URL}${endpoint}`, { method: 'POST', headers: authHeaders(), body });
+  if (!response.ok) {
+    const text = await response.text();
+    throw new Error(`Erreur ${response.status}: ${text}`);
+  }
+  return response.json();
+};
+
 // API Membres
 export const memberAPI = {
   getAll: () => get('/members'),
   getById: (id) => get(`/members/${id}`),
-  create: (data) => post('/members', data),
+  // Le jeton vient de formAPI.getToken(), demandé à l'affichage du formulaire
+  create: (data, form) => post('/members', data, form ? { [form.header]: form.token } : {}),
+  update: (id, data) => put(`/members/${id}`, data),
+  delete: (id, version) => del(`/members/${id}`, version),
+  restore: (id) => post(`/members/${id}/restore`),
+  search: (q) => get(`/members/search?q=${encodeURIComponent(q)}`),
+};
+
+// Jeton anti-spam du formulaire d'inscription
+export const formAPI = {
+  getToken: () => get('/forms/token'),
 };
 
 // API Événements
 export const eventAPI = {
-  getAll: () => get('/events'),
+  // filters : { category: slug, tag, expand: 'false' pour les séries récurrentes }
+  getAll: (filters = {}) => {
+    const params = new URLSearchParams(Object.entries(filters).filter(([, v]) => v));
+    return get(`/events${params.toString() ? `?${params}` : ''}`);
+  },
   getById: (id) => get(`/events/${id}`),
   create: (data) => post('/events', data),
-  register: (eventId, memberId) => post(`/events/${eventId}/register`, { member_id: memberId }),
+  update: (id, data) => put(`/events/${id}`, data),
+  delete: (id, version) => del(`/events/${id}`, version),
+  restore: (id) => post(`/events/${id}/restore`),
+  // occurrenceStart : début d'origine de l'occurrence d'un événement récurrent
+  // form : jeton anti-spam de formAPI.getToken()
+  register: (eventId, memberId, occurrenceStart, form) =>
+    post(`/events/${eventId}/register`, { member_id: memberId, occurrence_start: occurrenceStart },
+      form ? { [form.header]: form.token } : {}),
+  // Inscription d'un non-membre, confirmée par le lien envoyé à son email
+  registerGuest: (eventId, guest, form) =>
+    post(`/events/${eventId}/guests`, guest, form ? { [form.header]: form.token } : {}),
+  getGuests: (eventId) => get(`/events/${eventId}/guests`),
+  // file : File d'un <input type="file"> ; mediaId : image déjà envoyée
+  uploadImage: (eventId, file) => upload(`/events/${eventId}/image`, { image: file }),
+  setImage: (eventId, mediaId) => upload(`/events/${eventId}/image`, { media_id: mediaId }),
+  removeImage: (eventId, version) => del(`/events/${eventId}/image`, version),
+  // Historique des versions ; rollback enregistre une nouvelle version
+  revisions: (id) => get(`/events/${id}/revisions`),
+  revision: (id, version) => get(`/events/${id}/revisions/${version}`),
+  diffRevisions: (id, from, to) => get(`/events/${id}/revisions/diff?from=${from}&to=${to}`),
+  rollback: (id, version) => post(`/events/${id}/revisions/${version}/rollback`),
+};
+
+// API Médiathèque (images envoyées et miniatures)
+export const mediaAPI = {
+  getAll: () => get('/media'),
+  getById: (id) => get(`/media/${id}`),
+  upload: (file) => upload('/media', { image: file }),
+  delete: (id) => del(`/media/${id}`),
+};
+
+// API Questionnaires de satisfaction
+export const surveyAPI = {
+  get: (eventId) => get(`/events/${eventId}/survey`),
+  save: (eventId, data) => put(`/events/${eventId}/survey`, data),
+  delete: (eventId) => del(`/events/${eventId}/survey`),
+  // Le jeton de réponse est envoyé par email aux membres présents
+  requestLink: (eventId, email) => post(`/events/${eventId}/survey/invitations`, { email }),
+  // answers : [{ question_id, rating | choices | text }]
+  respond: (eventId, token, answers) =>
+    post(`/events/${eventId}/survey/responses`, { token, answers }),
+  getResults: (eventId) => get(`/events/${eventId}/survey/results`),
+  exportUrl: (eventId) => `${API_BASE_URL}/events/${eventId}/survey/responses.csv`,
+  markAttendance: (eventId, memberIds, occurrenceStart) =>
+    post(`/events/${eventId}/attendance`, { member_ids: memberIds, occurrence_start: occurrenceStart }),
+};
+
+// API Invités
+export const guestAPI = {
+  getAll: () => get('/guests'),
+  getById: (id) => get(`/guests/${id}`),
+  delete: (id) => del(`/guests/${id}`),
+  // data : { member_id } pour un membre existant, sinon phone, student_id, field_of_study
+  convert: (id, data) => post(`/guests/${id}/convert`, data),
+};
+
+// API Catégories et étiquettes
+export const categoryAPI = {
+  getAll: (tag) => get(`/categories${tag ? `?tag=${encodeURIComponent(tag)}` : ''}`),
+  create: (data) => post('/categories', data),
+  update: (id, data) => put(`/categories/${id}`, data),
+  delete: (id) => del(`/categories/${id}`),
+};
+
+export const tagAPI = {
+  getAll: (category) => get(`/tags${category ? `?category=${encodeURIComponent(category)}` : ''}`),
+};
+
+// API Salles
+export const venueAPI = {
+  getAll: () => get('/venues'),
+  create: (data) => post('/venues', data),
+  update: (id, data) => put(`/venues/${id}`, data),
+  delete: (id) => del(`/venues/${id}`),
+  getBookings: (id) => get(`/venues/${id}/bookings`),
+};
+
+// API Programme des événements
+export const sessionAPI = {
+  getAgenda: (eventId) => get(`/events/${eventId}/agenda`),
+  create: (eventId, data) => post(`/events/${eventId}/sessions`, data),
+  update: (eventId, id, data) => put(`/events/${eventId}/sessions/${id}`, data),
+  delete: (eventId, id) => del(`/events/${eventId}/sessions/${id}`),
+  register: (eventId, id, memberId) =>
+    post(`/events/${eventId}/sessions/${id}/register`, { member_id: memberId }),
+};
+
+export const speakerAPI = {
+  getAll: () => get('/speakers'),
+  create: (data) => post('/speakers', data),
+  update: (id, data) => put(`/speakers/${id}`, data),
+  delete: (id) => del(`/speakers/${id}`),
 };
 
 // API Annonces
 export const announcementAPI = {
-  getAll: () => get('/announcements'),
+  // status (jeton administrateur) : draft, scheduled, published, expired ou all
+  getAll: (status) => get(status ? `/announcements?status=${status}` : '/announcements'),
+  // Annonces destinées à tous et celles dont le membre fait partie du public
+  getForMember: (memberId) => get(`/announcements?member_id=${memberId}`),
   getById: (id) => get(`/announcements/${id}`),
   create: (data) => post('/announcements', data),
-};
\ No newline at end of file
+  update: (id, data) => put(`/announcements/${id}`, data),
+  delete: (id, version) => del(`/announcements/${id}`, version),
+  restore: (id) => post(`/announcements/${id}/restore`),
+  // Historique des versions (jeton administrateur)
+  revisions: (id) => get(`/announcements/${id}/revisions`),
+  revision: (id, version) => get(`/announcements/${id}/revisions/${version}`),
+  diffRevisions: (id, from, to) => get(`/announcements/${id}/revisions/diff?from=${from}&to=${to}`),
+  rollback: (id, version) => post(`/announcements/${id}/revisions/${version}/rollback`),
+};
+
+// API Corbeille
+export const trashAPI = {
+  getAll: () => get('/trash'),
+};
+
+// API Journal d'audit
+export const auditAPI = {
+  list: (filters = {}) => get(`/audit?${new URLSearchParams(filters)}`),
+};